@@ -1,16 +1,54 @@
 package api
 
+import "context"
+
 // MeasurementType represents the base type for all measurement types
 type MeasurementType string
 
 const (
 	// SWCTemperature represents the current temperatures returned by the SWC PAC system
 	SWCTemperature MeasurementType = "SWCTemperature"
+
+	// SWCDerived represents the values computed out of the SWC temperatures (temperature spreads, thermal power)
+	SWCDerived MeasurementType = "SWCDerived"
+
+	// SWCHeatQuantity represents the heat meter counters of the SWC PAC system
+	SWCHeatQuantity MeasurementType = "SWCHeatQuantity"
+
+	// SWCOutputs represents the state of the outputs (compressor, pumps, valves) of the SWC PAC system
+	SWCOutputs MeasurementType = "SWCOutputs"
+
+	// SWCStatus represents the operating status of the SWC PAC system
+	SWCStatus MeasurementType = "SWCStatus"
+
+	// SWCFault represents an entry of the error or shutdown history of the SWC PAC system
+	SWCFault MeasurementType = "SWCFault"
+
+	// CompressorCycle represents compressor and defrost start/stop events
+	CompressorCycle MeasurementType = "CompressorCycle"
+
+	// ShortCycling represents the short-cycling warning raised and cleared by the cycle analyzer
+	ShortCycling MeasurementType = "ShortCycling"
+
+	// Anomaly represents a sensor anomaly appearing or clearing
+	Anomaly MeasurementType = "Anomaly"
+
+	// Alert represents an alert firing or being resolved
+	Alert MeasurementType = "Alert"
+
+	// ElectricalPower represents the readings of an electrical power meter
+	ElectricalPower MeasurementType = "ElectricalPower"
+
+	// COP represents the coefficient of performance of the heat pump
+	COP MeasurementType = "COP"
 )
 
 // Measurement holds a given measure at a specific time
 type Measurement struct {
 	MeasurementType MeasurementType
+	Device          string // name of the device which produced the measurement
 	Timestamp       int64
 	Value           []byte
+	Tags            map[string]string // metadata attached by processors, e.g. quality flags
+	Context         context.Context   `json:"-"` // trace of the source which produced the measurement, nil when unknown
 }
@@ -0,0 +1,4 @@
+// Package pacmonpb holds the gRPC service exposing the collector pipeline, generated from pacmon.proto
+package pacmonpb
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative pacmon.proto
@@ -0,0 +1,173 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.5.1
+// - protoc             v5.27.2
+// source: pacmon.proto
+
+// Streams and queries the measurements collected by pacmon.
+
+package pacmonpb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	Pacmon_Subscribe_FullMethodName = "/pacmon.v1.Pacmon/Subscribe"
+	Pacmon_GetLatest_FullMethodName = "/pacmon.v1.Pacmon/GetLatest"
+)
+
+// PacmonClient is the client API for Pacmon service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// Pacmon exposes the collector pipeline.
+type PacmonClient interface {
+	// Subscribe streams the measurements matching the filter as they are collected.
+	Subscribe(ctx context.Context, in *SubscribeRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[Measurement], error)
+	// GetLatest returns the latest measurement of every type and device matching the filter.
+	GetLatest(ctx context.Context, in *GetLatestRequest, opts ...grpc.CallOption) (*GetLatestResponse, error)
+}
+
+type pacmonClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewPacmonClient(cc grpc.ClientConnInterface) PacmonClient {
+	return &pacmonClient{cc}
+}
+
+func (c *pacmonClient) Subscribe(ctx context.Context, in *SubscribeRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[Measurement], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &Pacmon_ServiceDesc.Streams[0], Pacmon_Subscribe_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[SubscribeRequest, Measurement]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Pacmon_SubscribeClient = grpc.ServerStreamingClient[Measurement]
+
+func (c *pacmonClient) GetLatest(ctx context.Context, in *GetLatestRequest, opts ...grpc.CallOption) (*GetLatestResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(GetLatestResponse)
+	err := c.cc.Invoke(ctx, Pacmon_GetLatest_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// PacmonServer is the server API for Pacmon service.
+// All implementations must embed UnimplementedPacmonServer
+// for forward compatibility.
+//
+// Pacmon exposes the collector pipeline.
+type PacmonServer interface {
+	// Subscribe streams the measurements matching the filter as they are collected.
+	Subscribe(*SubscribeRequest, grpc.ServerStreamingServer[Measurement]) error
+	// GetLatest returns the latest measurement of every type and device matching the filter.
+	GetLatest(context.Context, *GetLatestRequest) (*GetLatestResponse, error)
+	mustEmbedUnimplementedPacmonServer()
+}
+
+// UnimplementedPacmonServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedPacmonServer struct{}
+
+func (UnimplementedPacmonServer) Subscribe(*SubscribeRequest, grpc.ServerStreamingServer[Measurement]) error {
+	return status.Errorf(codes.Unimplemented, "method Subscribe not implemented")
+}
+func (UnimplementedPacmonServer) GetLatest(context.Context, *GetLatestRequest) (*GetLatestResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetLatest not implemented")
+}
+func (UnimplementedPacmonServer) mustEmbedUnimplementedPacmonServer() {}
+func (UnimplementedPacmonServer) testEmbeddedByValue()                {}
+
+// UnsafePacmonServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to PacmonServer will
+// result in compilation errors.
+type UnsafePacmonServer interface {
+	mustEmbedUnimplementedPacmonServer()
+}
+
+func RegisterPacmonServer(s grpc.ServiceRegistrar, srv PacmonServer) {
+	// If the following call pancis, it indicates UnimplementedPacmonServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&Pacmon_ServiceDesc, srv)
+}
+
+func _Pacmon_Subscribe_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(SubscribeRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(PacmonServer).Subscribe(m, &grpc.GenericServerStream[SubscribeRequest, Measurement]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Pacmon_SubscribeServer = grpc.ServerStreamingServer[Measurement]
+
+func _Pacmon_GetLatest_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetLatestRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PacmonServer).GetLatest(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Pacmon_GetLatest_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PacmonServer).GetLatest(ctx, req.(*GetLatestRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// Pacmon_ServiceDesc is the grpc.ServiceDesc for Pacmon service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Pacmon_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "pacmon.v1.Pacmon",
+	HandlerType: (*PacmonServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "GetLatest",
+			Handler:    _Pacmon_GetLatest_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "Subscribe",
+			Handler:       _Pacmon_Subscribe_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "pacmon.proto",
+}
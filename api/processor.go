@@ -0,0 +1,13 @@
+package api
+
+// Processor transforms the measurements flowing from a source to a sink.
+// A processor can forward, drop or emit additional measurements.
+type Processor interface {
+	Process(m Measurement) []Measurement
+}
+
+// Flusher is implemented by processors buffering measurements. Flush returns the buffered
+// measurements and is called when the collection process stops.
+type Flusher interface {
+	Flush() []Measurement
+}
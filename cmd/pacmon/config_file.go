@@ -0,0 +1,251 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/renajohn/pac_collector/internal/configfile"
+	"github.com/renajohn/pac_collector/internal/cycleprocessor"
+	"github.com/renajohn/pac_collector/internal/filesink"
+	"github.com/renajohn/pac_collector/internal/healthcheck"
+	"github.com/renajohn/pac_collector/internal/parquetsink"
+	"github.com/renajohn/pac_collector/internal/powermetersource"
+	"github.com/renajohn/pac_collector/internal/remotewritesink"
+	"github.com/renajohn/pac_collector/internal/streamhub"
+)
+
+// loadConfigFile reads a configuration file, the flags defaults applying to the values it omits
+func loadConfigFile(path string) (*pacMonConfig, error) {
+	document, err := configfile.Load(path)
+	if err != nil {
+		return nil, err
+	}
+
+	config, err := fromConfigFile(document)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return config, nil
+}
+
+// runConfigCommand runs the config subcommand, e.g. pacmon config validate pacmon.yaml, and returns the exit code
+func runConfigCommand(args []string, stdout io.Writer, stderr io.Writer) int {
+	if len(args) != 4 || args[2] != "validate" {
+		fmt.Fprintf(stderr, "usage: %s config validate <file.yaml|file.toml|file.json>\n", args[0])
+		return 2
+	}
+
+	if _, err := loadConfigFile(args[3]); err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+
+	fmt.Fprintf(stdout, "%s is valid\n", args[3])
+	return 0
+}
+
+// fromConfigFile converts a validated configuration file
+func fromConfigFile(document *configfile.Config) (*pacMonConfig, error) {
+	config := pacMonConfig{
+		sourceURL:       document.Sources.SWC.URL,
+		device:          document.Sources.SWC.Device,
+		pollingInterval: nonZeroOr(document.Sources.SWC.PollingInterval, defaultPollingInterval),
+		kafkaTopic:      defaultKafkaTopic,
+	}
+
+	sinks := document.Sinks
+	if sinks.Kafka != nil {
+		config.sinkURL = sinks.Kafka.URL
+		if sinks.Kafka.Topic != "" {
+			config.kafkaTopic = sinks.Kafka.Topic
+		}
+	}
+	if sinks.MQTT != nil {
+		config.mqttURL = sinks.MQTT.URL
+	}
+	if sinks.Influx != nil {
+		config.influxURL = sinks.Influx.URL
+	}
+	if sinks.Postgres != nil {
+		config.postgresURL = sinks.Postgres.URL
+	}
+	if remoteWrite := sinks.RemoteWrite; remoteWrite != nil {
+		labels := remoteWrite.Labels
+		if labels == nil {
+			labels = make(map[string]string)
+		}
+		if err := remotewritesink.CheckLabels(labels); err != nil {
+			return nil, &configfile.FieldError{Field: "sinks.remoteWrite.labels", Message: err.Error()}
+		}
+		config.remoteWrite = &remoteWriteConfig{url: remoteWrite.URL, externalLabels: labels, bearerToken: remoteWrite.Token}
+	}
+	if sinks.OTLP != nil {
+		config.otlp = &otlpConfig{endpoint: sinks.OTLP.Endpoint, site: sinks.OTLP.Site}
+	}
+	if file := sinks.File; file != nil {
+		compression := file.Compression
+		if compression == "" {
+			compression = defaultFileCompression
+		}
+		sync := file.Sync
+		if sync == "" {
+			sync = filesink.SyncInterval
+		}
+		fileSink, err := parseFileConfig(file.Path, intOr(file.RotateSize, defaultFileRotateSize), int(durationOr(file.RotateInterval, defaultFileRotateInterval)/time.Second),
+			compression, intOr(file.MaxFiles, defaultFileMaxFiles), 0, sync)
+		if err != nil {
+			return nil, err
+		}
+		fileSink.retention = time.Duration(file.Retention)
+		config.file = fileSink
+	}
+	if parquet := sinks.Parquet; parquet != nil {
+		config.parquet = &parquetConfig{
+			root:           parquet.Root,
+			rotateInterval: nonZeroOr(parquet.RotateInterval, parquetsink.DefaultRotateInterval),
+			rowGroupSize:   int64(parquet.RowGroupSize),
+		}
+		if config.parquet.rowGroupSize == 0 {
+			config.parquet.rowGroupSize = parquetsink.DefaultRowGroupSize
+		}
+	}
+	if sqlite := sinks.SQLite; sqlite != nil {
+		config.sqlite = &sqliteConfig{
+			path:               sqlite.Path,
+			retention:          time.Duration(sqlite.Retention),
+			downsampleAfter:    durationOr(sqlite.DownsampleAfter, defaultDownsampleAfter),
+			downsampleInterval: nonZeroOr(sqlite.DownsampleInterval, defaultDownsampleInterval),
+		}
+	}
+
+	servers := document.Servers
+	if http := servers.HTTP; http != nil {
+		config.httpAddr = http.Addr
+		config.dashboard = http.Dashboard
+		config.stream = http.Stream
+		config.controlAPI = http.ControlAPI
+		config.health = &healthConfig{
+			disconnectedAfter: healthcheck.DefaultDisconnectedAfter,
+			sinkFailingAfter:  healthcheck.DefaultSinkFailingAfter,
+		}
+		if health := http.Health; health != nil {
+			config.health.staleAfter = time.Duration(health.StaleAfter)
+			config.health.disconnectedAfter = durationOr(health.DisconnectedAfter, healthcheck.DefaultDisconnectedAfter)
+			config.health.sinkFailingAfter = durationOr(health.SinkFailingAfter, healthcheck.DefaultSinkFailingAfter)
+		}
+	}
+	if servers.GRPC != nil {
+		config.grpcAddr = servers.GRPC.Addr
+	}
+	if config.dashboard || config.stream || config.controlAPI || len(config.grpcAddr) > 0 {
+		config.streamBuffer = servers.StreamBuffer
+		if config.streamBuffer == 0 {
+			config.streamBuffer = streamhub.DefaultBufferSize
+		}
+	}
+
+	processors := document.Processors
+	if processors.Aggregate != nil {
+		config.aggregateWindow = time.Duration(processors.Aggregate.Window)
+	}
+	if processors.Derived != nil {
+		config.derived = &derivedConfig{heatingFlowRate: processors.Derived.HeatingFlow, drillFlowRate: processors.Derived.DrillFlow}
+	}
+	if meter := document.Sources.PowerMeter; meter != nil {
+		config.cop = &copConfig{
+			powerMeterURL: meter.URL,
+			powerPath:     stringOr(meter.Power, powermetersource.DefaultPowerPath),
+			energyPath:    stringOr(meter.Energy, powermetersource.DefaultEnergyPath),
+			energyScale:   powermetersource.DefaultEnergyScale,
+			window:        defaultCOPWindow,
+		}
+		if meter.EnergyScale != nil {
+			config.cop.energyScale = *meter.EnergyScale
+		}
+		if processors.COP != nil {
+			config.cop.window = nonZeroOr(processors.COP.Window, defaultCOPWindow)
+		}
+	}
+	if processors.Cycles != nil {
+		config.cycles = &cycleConfig{maxStartsPerHour: processors.Cycles.MaxStartsPerHour}
+		if config.cycles.maxStartsPerHour == 0 {
+			config.cycles.maxStartsPerHour = cycleprocessor.DefaultMaxStartsPerHour
+		}
+	}
+	if processors.Faults != nil {
+		config.faults = &faultsConfig{statePath: stringOr(processors.Faults.StateFile, defaultFaultState)}
+	}
+	if alerting := processors.Alerting; alerting != nil {
+		specs := alerting.Notifiers
+		if len(specs) == 0 {
+			specs = []string{"sink"}
+		}
+		var notifiers []string
+		for index, spec := range specs {
+			parsed, err := parseNotifiers(spec)
+			if err != nil {
+				return nil, &configfile.FieldError{Field: fmt.Sprintf("processors.alerting.notifiers[%d]", index), Message: err.Error()}
+			}
+			notifiers = append(notifiers, parsed...)
+		}
+		config.alerting = &alertingConfig{
+			rulesFile:       alerting.Rules,
+			notifiers:       notifiers,
+			webhookSecret:   alerting.WebhookSecret,
+			webhookTemplate: alerting.WebhookTemplate,
+			minInterval:     durationOr(alerting.Interval, defaultAlertInterval),
+		}
+	}
+	if deadband := processors.Deadband; deadband != nil {
+		config.deadband = &deadbandConfig{
+			defaultDeadband: deadband.Default,
+			fieldDeadbands:  make(map[string]float64),
+			heartbeat:       durationOr(deadband.Heartbeat, defaultHeartbeat),
+		}
+		for name, value := range deadband.Fields {
+			config.deadband.fieldDeadbands[name] = value
+		}
+	}
+	if quality := processors.Quality; quality != nil {
+		config.quality = &qualityConfig{
+			stuckAfter: durationOr(quality.StuckAfter, defaultStuckAfter),
+			ranges:     make(map[string]qualityRange),
+		}
+		for name, bounds := range quality.Ranges {
+			config.quality.ranges[name] = qualityRange{min: bounds.Min, max: bounds.Max}
+		}
+	}
+
+	return &config, nil
+}
+
+// durationOr returns the configured duration, or fallback when it is omitted, zero being a valid value
+func durationOr(value *configfile.Duration, fallback time.Duration) time.Duration {
+	if value == nil {
+		return fallback
+	}
+	return time.Duration(*value)
+}
+
+// nonZeroOr returns the configured duration, or fallback when it is omitted or zero
+func nonZeroOr(value configfile.Duration, fallback time.Duration) time.Duration {
+	if value == 0 {
+		return fallback
+	}
+	return time.Duration(value)
+}
+
+func intOr(value *int, fallback int) int {
+	if value == nil {
+		return fallback
+	}
+	return *value
+}
+
+func stringOr(value string, fallback string) string {
+	if value == "" {
+		return fallback
+	}
+	return value
+}
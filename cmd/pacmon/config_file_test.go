@@ -0,0 +1,165 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeConfigFile(t *testing.T, name string, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestConfigFile(t *testing.T) {
+	t.Run("A configuration file is equivalent to the flags", func(t *testing.T) {
+		t.Setenv("PACMON_SWC_HOST", "192.168.0.29")
+		path := writeConfigFile(t, "pacmon.yaml", `
+sources:
+  swc:
+    url: ws://${PACMON_SWC_HOST}:8214/
+    pollingInterval: 30s
+  powerMeter:
+    url: http://shelly/status
+processors:
+  aggregate:
+    window: 5m
+  derived:
+    heatingFlow: 1200
+  cycles: {}
+  deadband:
+    default: 0.2
+    fields:
+      OutsideTemperature: 0.5
+  quality:
+    ranges:
+      TankTemperature: {min: 0, max: 95}
+sinks:
+  kafka:
+    url: kafka:9092
+  remoteWrite:
+    url: https://mimir/api/v1/push
+    labels: {site: home}
+  file:
+    path: /data/pacmon.jsonl
+    compression: none
+    retention: 7d
+  sqlite:
+    path: /data/pacmon.db
+    retention: 30d
+servers:
+  http:
+    addr: :9100
+    stream: true
+    health:
+      staleAfter: 10m
+  grpc:
+    addr: :9090
+`)
+
+		fromFile, err := parseCmdParams([]string{"pacmon", "-config=" + path, "-configWatch=10"})
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if fromFile.configPath != path || fromFile.configWatch != 10*time.Second {
+			t.Errorf("Expected the file to be watched every 10s, got %q every %v", fromFile.configPath, fromFile.configWatch)
+		}
+		fromFile.configPath, fromFile.configWatch = "", 0
+
+		fromFlags, err := parseCmdParams([]string{"pacmon", "-sourceURL=ws://192.168.0.29:8214/", "-pollingInterval=30",
+			"-powerMeterURL=http://shelly/status", "-aggregate=300", "-heatingFlow=1200", "-cycles",
+			"-deadband=0.2,OutsideTemperature=0.5", "-qualityRanges=TankTemperature=0:95",
+			"-sinkURL=kafka:9092", "-remoteWriteURL=https://mimir/api/v1/push", "-remoteWriteLabels=site=home",
+			"-filePath=/data/pacmon.jsonl", "-fileCompression=none", "-fileRetention=7",
+			"-sqlitePath=/data/pacmon.db", "-sqliteRetention=30",
+			"-httpAddr=:9100", "-stream", "-healthStaleAfter=600", "-grpcAddr=:9090"})
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		if !reflect.DeepEqual(fromFile, fromFlags) {
+			t.Errorf("Expected %+v, got %+v", fromFlags, fromFile)
+		}
+	})
+
+	t.Run("-config cannot be combined with other flags", func(t *testing.T) {
+		path := writeConfigFile(t, "pacmon.json", `{"sources": {"swc": {"url": "ws://pac"}}, "servers": {"grpc": {"addr": ":9090"}}}`)
+
+		_, err := parseCmdParams([]string{"pacmon", "-config=" + path, "-pollingInterval=30"})
+		if err == nil {
+			t.Error("Expected an error")
+		}
+	})
+
+	t.Run("Invalid notifiers are reported with their path", func(t *testing.T) {
+		path := writeConfigFile(t, "pacmon.toml", `
+[sources.swc]
+url = "ws://pac"
+
+[processors.alerting]
+rules = "rules.txt"
+notifiers = ["log", "ftp://example"]
+
+[servers.grpc]
+addr = ":9090"
+`)
+
+		_, err := loadConfigFile(path)
+		if err == nil || err.Error() != path+`: processors.alerting.notifiers[1]: unknown notifier "ftp://example"` {
+			t.Errorf("Unexpected error %v", err)
+		}
+	})
+}
+
+func TestConfigCommand(t *testing.T) {
+	t.Run("A valid file is reported as such", func(t *testing.T) {
+		path := writeConfigFile(t, "pacmon.yaml", "sources:\n  swc:\n    url: ws://pac\nsinks:\n  mqtt:\n    url: mqtt://broker:1883\n")
+		var stdout, stderr bytes.Buffer
+
+		code := runConfigCommand([]string{"pacmon", "config", "validate", path}, &stdout, &stderr)
+		if code != 0 || stdout.String() != path+" is valid\n" {
+			t.Errorf("Unexpected exit code %d: %s%s", code, stdout.String(), stderr.String())
+		}
+	})
+
+	t.Run("The problems of an invalid file are listed", func(t *testing.T) {
+		path := writeConfigFile(t, "pacmon.yaml", "sources:\n  swc:\n    url: ws://pac\nsinks:\n  mqtt: {}\n  influxdb: {}\n")
+		var stdout, stderr bytes.Buffer
+
+		code := runConfigCommand([]string{"pacmon", "config", "validate", path}, &stdout, &stderr)
+		if code != 1 {
+			t.Errorf("Expected exit code 1, got %d", code)
+		}
+		expected := path + `: sinks.influxdb: unknown field, did you mean "influx"?` + "\n"
+		if stderr.String() != expected {
+			t.Errorf("Expected %q, got %q", expected, stderr.String())
+		}
+	})
+
+	t.Run("The example configuration is valid", func(t *testing.T) {
+		t.Setenv("INFLUX_TOKEN", "token")
+		var stdout, stderr bytes.Buffer
+
+		code := runConfigCommand([]string{"pacmon", "config", "validate", "../../pacmon.example.yaml"}, &stdout, &stderr)
+		if code != 0 {
+			t.Errorf("Unexpected exit code %d: %s", code, stderr.String())
+		}
+	})
+
+	t.Run("Usage is printed when the arguments are missing", func(t *testing.T) {
+		var stdout, stderr bytes.Buffer
+
+		code := runConfigCommand([]string{"pacmon", "config", "check"}, &stdout, &stderr)
+		if code != 2 || !strings.HasPrefix(stderr.String(), "usage: pacmon config validate") {
+			t.Errorf("Unexpected exit code %d: %s", code, stderr.String())
+		}
+	})
+}
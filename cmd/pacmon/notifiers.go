@@ -0,0 +1,108 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/renajohn/pac_collector/api"
+	"github.com/renajohn/pac_collector/internal/alerting"
+	"github.com/renajohn/pac_collector/internal/pushnotifier"
+	"github.com/renajohn/pac_collector/internal/smtpnotifier"
+	"github.com/renajohn/pac_collector/internal/webhooknotifier"
+)
+
+// parseNotifiers splits and validates a comma separated list of notifiers
+func parseNotifiers(spec string) ([]string, error) {
+	var notifiers []string
+
+	for _, notifier := range strings.Split(spec, ",") {
+		notifier = strings.TrimSpace(notifier)
+		if notifier == "sink" || notifier == "log" {
+			notifiers = append(notifiers, notifier)
+			continue
+		}
+
+		notifierURL, err := url.Parse(notifier)
+		if err != nil {
+			return nil, fmt.Errorf("invalid notifier %q: %v", notifier, err)
+		}
+		switch notifierURL.Scheme {
+		case "http", "https", "ntfy+http", "ntfy+https", "gotify+http", "gotify+https":
+		case "smtp":
+			query := notifierURL.Query()
+			if len(query.Get("from")) == 0 || len(query["to"]) == 0 {
+				return nil, fmt.Errorf("smtp notifier %q requires a from and at least one to parameter", notifierURL.Redacted())
+			}
+		default:
+			return nil, fmt.Errorf("unknown notifier %q", notifierURL.Redacted())
+		}
+		notifiers = append(notifiers, notifier)
+	}
+
+	return notifiers, nil
+}
+
+// buildNotifier creates the notifiers out of their validated specification
+func buildNotifier(config *alertingConfig, sink api.Sink) (alerting.Notifier, error) {
+	var notifiers alerting.MultiNotifier
+
+	for _, spec := range config.notifiers {
+		notifier, err := newNotifier(spec, config, sink)
+		if err != nil {
+			notifiers.Close()
+			return nil, err
+		}
+		if spec != "sink" && spec != "log" {
+			// remote notifiers deliver from their own goroutine, not to hold up the collector
+			notifier = alerting.NewQueuedNotifier(notifier, alerting.DefaultQueueSize)
+		}
+		notifiers = append(notifiers, notifier)
+	}
+
+	if config.minInterval > 0 {
+		return alerting.NewThrottledNotifier(notifiers, config.minInterval), nil
+	}
+	return notifiers, nil
+}
+
+func newNotifier(spec string, config *alertingConfig, sink api.Sink) (alerting.Notifier, error) {
+	switch spec {
+	case "sink":
+		return alerting.NewSinkNotifier(sink), nil
+	case "log":
+		return &alerting.LogNotifier{}, nil
+	}
+
+	notifierURL, err := url.Parse(spec)
+	if err != nil {
+		return nil, err
+	}
+
+	switch notifierURL.Scheme {
+	case "http", "https":
+		bodyTemplate := ""
+		if len(config.webhookTemplate) > 0 {
+			data, err := os.ReadFile(config.webhookTemplate)
+			if err != nil {
+				return nil, err
+			}
+			bodyTemplate = string(data)
+		}
+		return webhooknotifier.NewWebhookNotifier(spec, config.webhookSecret, bodyTemplate)
+	case "smtp":
+		password, _ := notifierURL.User.Password()
+		query := notifierURL.Query()
+		return smtpnotifier.NewSMTPNotifier(notifierURL.Host, notifierURL.User.Username(), password, query.Get("from"), query["to"]), nil
+	default:
+		// ntfy+https://server/topic?token=... or gotify+https://server?token=...
+		service := strings.SplitN(notifierURL.Scheme, "+", 2)
+		query := notifierURL.Query()
+		token := query.Get("token")
+		query.Del("token")
+		notifierURL.Scheme = service[1]
+		notifierURL.RawQuery = query.Encode()
+		return pushnotifier.NewPushNotifier(service[0], notifierURL.String(), token)
+	}
+}
@@ -0,0 +1,307 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/renajohn/pac_collector/api"
+	"github.com/renajohn/pac_collector/collector"
+	"github.com/renajohn/pac_collector/internal/multisink"
+	"github.com/renajohn/pac_collector/internal/swcsource"
+)
+
+// pipeline keeps the running components along with the configuration they were built from,
+// so that a reload only rebuilds the components whose configuration changed
+type pipeline struct {
+	mutex         sync.Mutex
+	config        *pacMonConfig
+	swc           *swcsource.SWCSource
+	sink          *multisink.MultiSink
+	sinks         map[string]api.Sink
+	collector     *collector.Collector
+	collectorSink api.Sink // sink of the alerts
+	processors    map[string]api.Processor
+	observers     []api.Processor
+	history       *handlerSwitch         // /history of the SQLite sink
+	health        *handlerSwitch         // /healthz and /readyz probes
+	started       time.Time              // start of the health checks, kept when the checker is rebuilt
+	builtFrom     map[string]interface{} // settings of the sinks and processors, e.g. "sink influx"
+}
+
+func newPipeline(config *pacMonConfig, swc *swcsource.SWCSource, sink *multisink.MultiSink, sinks map[string]api.Sink) *pipeline {
+	p := pipeline{
+		config:    config,
+		swc:       swc,
+		sink:      sink,
+		sinks:     sinks,
+		history:   &handlerSwitch{},
+		health:    &handlerSwitch{},
+		builtFrom: make(map[string]interface{}),
+		started:   time.Now(),
+	}
+	for _, name := range sinkNames {
+		p.builtFrom["sink "+name] = sinkSettings(name, config)
+	}
+	p.setHistory()
+	if config.health != nil {
+		p.health.set(buildChecker(config.health, swc, sink, p.started))
+	}
+
+	return &p
+}
+
+// start records the processing chain of the collector
+func (p *pipeline) start(c *collector.Collector, collectorSink api.Sink, processors map[string]api.Processor, observers []api.Processor) {
+	p.collector = c
+	p.collectorSink = collectorSink
+	p.processors = processors
+	p.observers = observers
+	for _, name := range processorNames {
+		p.builtFrom["processor "+name] = processorSettings(name, p.config)
+	}
+}
+
+// reload applies a new configuration. The sinks and processors whose settings changed are rebuilt,
+// the polling interval is changed in place and the SWC session is only replaced when its settings changed.
+// Nothing is applied when a component cannot be built.
+func (p *pipeline) reload(config *pacMonConfig) error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	previous := p.config
+	keepRestartSettings(previous, config)
+
+	// build the new components first, so that a failure leaves the running ones untouched
+	builtFrom := make(map[string]interface{})
+	sinks := make(map[string]api.Sink)
+	var rebuilt []string
+	for _, name := range sinkNames {
+		sink, found := p.sinks[name]
+		settings := sinkSettings(name, config)
+		builtFrom["sink "+name] = settings
+		if !reflect.DeepEqual(p.builtFrom["sink "+name], settings) {
+			var err error
+			sink, err = buildSink(name, config)
+			if err != nil {
+				closeSinks(pick(sinks, rebuilt))
+				return fmt.Errorf("%s sink: %v", name, err)
+			}
+			found = sink != nil
+			rebuilt = append(rebuilt, name)
+		}
+		if found {
+			sinks[name] = sink
+		}
+	}
+
+	processors := make(map[string]api.Processor)
+	processorsChanged := false
+	for _, name := range processorNames {
+		processor, found := p.processors[name]
+		settings := processorSettings(name, config)
+		builtFrom["processor "+name] = settings
+		if !reflect.DeepEqual(p.builtFrom["processor "+name], settings) {
+			var err error
+			processor, err = buildProcessor(name, config, p.collectorSink)
+			if err != nil {
+				closeSinks(pick(sinks, rebuilt))
+				return fmt.Errorf("%s processor: %v", name, err)
+			}
+			found = processor != nil
+			processorsChanged = true
+			log.Printf("Reloading the %s processor", name)
+		}
+		if found {
+			processors[name] = processor
+		}
+	}
+
+	reconnect := !reflect.DeepEqual(sessionSettings(previous), sessionSettings(config))
+	var pages []string
+	var faults *swcsource.FaultTracker
+	if reconnect {
+		var err error
+		if pages, faults, err = swcPages(config); err != nil {
+			closeSinks(pick(sinks, rebuilt))
+			return err
+		}
+	}
+
+	// then swap them in
+	if len(rebuilt) > 0 {
+		// Replace waits for the ongoing Puts, the replaced sinks are no longer written to once closed
+		p.sink.Replace(orderedSinks(sinks)...)
+		installTracerProvider(sinks)
+		closeSinks(pick(p.sinks, rebuilt))
+		log.Printf("Reloaded the sinks: %v", rebuilt)
+	}
+	p.sinks = sinks
+	p.setHistory()
+
+	if processorsChanged {
+		p.collector.SetProcessors(chainProcessors(processors, p.observers))
+	}
+	p.processors = processors
+
+	if config.pollingInterval != previous.pollingInterval {
+		if err := p.swc.SetPollInterval(config.pollingInterval); err != nil {
+			log.Println(err)
+		}
+	}
+	if reconnect {
+		if faults == nil {
+			faults, _ = swcsource.NewFaultTracker("")
+		}
+		p.swc.Reconfigure(config.sourceURL, config.device, pages, faults)
+	}
+
+	if config.health != nil && !reflect.DeepEqual(previous.health, config.health) {
+		p.health.set(buildChecker(config.health, p.swc, p.sink, p.started))
+	}
+
+	p.config = config
+	p.builtFrom = builtFrom
+	return nil
+}
+
+func (p *pipeline) setHistory() {
+	if sqliteSink, found := p.sinks["sqlite"]; found {
+		p.history.set(sqliteSink.(http.Handler))
+	} else {
+		p.history.set(nil)
+	}
+}
+
+// keepRestartSettings reverts the settings which cannot be changed while running, so that they
+// are reported again by the following reloads
+func keepRestartSettings(previous *pacMonConfig, config *pacMonConfig) {
+	if previous.httpAddr != config.httpAddr || previous.dashboard != config.dashboard || previous.stream != config.stream ||
+		previous.controlAPI != config.controlAPI || previous.grpcAddr != config.grpcAddr || previous.streamBuffer != config.streamBuffer {
+		log.Println("The HTTP or gRPC servers changed, restart pacmon to apply it")
+		config.httpAddr, config.dashboard, config.stream = previous.httpAddr, previous.dashboard, previous.stream
+		config.controlAPI, config.grpcAddr, config.streamBuffer = previous.controlAPI, previous.grpcAddr, previous.streamBuffer
+		if config.httpAddr == "" || config.health == nil {
+			config.health = previous.health
+		}
+	}
+
+	if !reflect.DeepEqual(powerMeterSettings(previous), powerMeterSettings(config)) {
+		log.Println("The power meter changed, restart pacmon to apply it")
+		if previous.cop == nil || config.cop == nil {
+			config.cop = previous.cop
+		} else {
+			cop := *previous.cop
+			cop.window = config.cop.window
+			config.cop = &cop
+		}
+	}
+}
+
+// sinkSettings returns the part of the configuration a sink is built from
+func sinkSettings(name string, config *pacMonConfig) interface{} {
+	switch name {
+	case "kafka":
+		return []string{config.sinkURL, config.kafkaTopic}
+	case "mqtt":
+		return config.mqttURL
+	case "file":
+		return config.file
+	case "parquet":
+		return config.parquet
+	case "sqlite":
+		return config.sqlite
+	case "otlp":
+		return []interface{}{config.otlp, config.device}
+	case "remoteWrite":
+		return config.remoteWrite
+	case "influx":
+		return config.influxURL
+	case "postgres":
+		return config.postgresURL
+	}
+
+	return nil
+}
+
+// processorSettings returns the part of the configuration a processor is built from
+func processorSettings(name string, config *pacMonConfig) interface{} {
+	switch name {
+	case "quality":
+		return config.quality
+	case "derived":
+		return config.derived
+	case "cop":
+		return config.cop
+	case "cycles":
+		return config.cycles
+	case "alerting":
+		// the rules file and the webhook template are read again on reload
+		var rules, template []byte
+		if config.alerting != nil {
+			rules, _ = os.ReadFile(config.alerting.rulesFile)
+			if len(config.alerting.webhookTemplate) > 0 {
+				template, _ = os.ReadFile(config.alerting.webhookTemplate)
+			}
+		}
+		return []interface{}{config.alerting, rules, template}
+	case "aggregate":
+		return config.aggregateWindow
+	case "deadband":
+		return config.deadband
+	}
+
+	return nil
+}
+
+// sessionSettings returns the part of the configuration requiring a new SWC session when changed
+func sessionSettings(config *pacMonConfig) interface{} {
+	return []interface{}{config.sourceURL, config.device, config.cycles != nil, config.faults, config.cop != nil}
+}
+
+// powerMeterSettings returns the part of the configuration the power meter is built from
+func powerMeterSettings(config *pacMonConfig) interface{} {
+	if config.cop == nil {
+		return nil
+	}
+	return []interface{}{config.cop.powerMeterURL, config.cop.powerPath, config.cop.energyPath, config.cop.energyScale, config.pollingInterval}
+}
+
+func pick(sinks map[string]api.Sink, names []string) map[string]api.Sink {
+	picked := make(map[string]api.Sink)
+	for _, name := range names {
+		if sink, found := sinks[name]; found {
+			picked[name] = sink
+		}
+	}
+	return picked
+}
+
+// handlerSwitch serves a handler which can be replaced while serving, replying 404 when there is none
+type handlerSwitch struct {
+	mutex   sync.RWMutex
+	handler http.Handler
+}
+
+func (hs *handlerSwitch) set(handler http.Handler) {
+	hs.mutex.Lock()
+	defer hs.mutex.Unlock()
+
+	hs.handler = handler
+}
+
+func (hs *handlerSwitch) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	hs.mutex.RLock()
+	handler := hs.handler
+	hs.mutex.RUnlock()
+
+	if handler == nil {
+		http.NotFound(w, r)
+		return
+	}
+	handler.ServeHTTP(w, r)
+}
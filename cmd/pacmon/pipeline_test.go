@@ -0,0 +1,170 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/renajohn/pac_collector/collector"
+	"github.com/renajohn/pac_collector/internal/multisink"
+	"github.com/renajohn/pac_collector/internal/swcsource"
+)
+
+func startPipeline(t *testing.T, config *pacMonConfig) *pipeline {
+	t.Helper()
+	sinks, err := buildSinks(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	processors, err := buildProcessors(config, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	swc := swcsource.NewSWCSource(config.sourceURL, config.pollingInterval)
+	sink := multisink.NewMultiSink(orderedSinks(sinks)...)
+	p := newPipeline(config, swc, sink, sinks)
+	c := collector.Collector{Source: swc, Processors: chainProcessors(processors, nil), Sink: sink}
+	// the collector is not running, the processors being replaced right away
+	c.Stop()
+	p.start(&c, nil, processors, nil)
+	t.Cleanup(func() { closeSinks(p.sinks) })
+
+	return p
+}
+
+func TestPipelineReload(t *testing.T) {
+	dir := t.TempDir()
+	newConfig := func() *pacMonConfig {
+		return &pacMonConfig{
+			sourceURL:       "ws://localhost:1",
+			pollingInterval: time.Minute,
+			httpAddr:        ":9100",
+			file:            &fileConfig{path: filepath.Join(dir, "pacmon.jsonl")},
+			sqlite:          &sqliteConfig{path: filepath.Join(dir, "pacmon.db")},
+			derived:         &derivedConfig{heatingFlowRate: 1200},
+			aggregateWindow: 5 * time.Minute,
+		}
+	}
+
+	t.Run("Only the changed sinks and processors are rebuilt", func(t *testing.T) {
+		p := startPipeline(t, newConfig())
+		fileSink, sqliteSink := p.sinks["file"], p.sinks["sqlite"]
+		derived, aggregate := p.processors["derived"], p.processors["aggregate"]
+
+		config := newConfig()
+		config.file.path = filepath.Join(dir, "other.jsonl")
+		config.aggregateWindow = 10 * time.Minute
+		config.pollingInterval = 2 * time.Minute
+		if err := p.reload(config); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		if p.sinks["file"] == fileSink || p.sinks["sqlite"] != sqliteSink {
+			t.Error("Expected only the file sink to be rebuilt")
+		}
+		if p.processors["aggregate"] == aggregate || p.processors["derived"] != derived {
+			t.Error("Expected only the aggregate processor to be rebuilt")
+		}
+		if len(p.sink.Health()) != 2 {
+			t.Errorf("Expected 2 sinks, got %v", p.sink.Health())
+		}
+		if p.swc.Status().PollInterval != 120 {
+			t.Errorf("Expected the polling interval to be changed in place, got %v", p.swc.Status().PollInterval)
+		}
+	})
+
+	t.Run("Removed components are dropped", func(t *testing.T) {
+		p := startPipeline(t, newConfig())
+
+		config := newConfig()
+		config.sqlite = nil
+		config.derived = nil
+		if err := p.reload(config); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		if _, found := p.sinks["sqlite"]; found {
+			t.Error("Expected the SQLite sink to be removed")
+		}
+		if _, found := p.processors["derived"]; found {
+			t.Error("Expected the derived processor to be removed")
+		}
+
+		recorder := httptest.NewRecorder()
+		p.history.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/history", nil))
+		if recorder.Code != http.StatusNotFound {
+			t.Errorf("Expected /history to be gone, got %d", recorder.Code)
+		}
+	})
+
+	t.Run("Nothing is applied when a component cannot be built", func(t *testing.T) {
+		p := startPipeline(t, newConfig())
+		running, fileSink := p.config, p.sinks["file"]
+
+		config := newConfig()
+		config.file.path = filepath.Join(dir, "other.jsonl")
+		config.alerting = &alertingConfig{rulesFile: filepath.Join(dir, "missing.rules")}
+		if err := p.reload(config); err == nil {
+			t.Fatal("Expected an error")
+		}
+
+		if p.config != running {
+			t.Error("Expected the running configuration to be kept")
+		}
+		if p.sinks["file"] != fileSink {
+			t.Error("Expected the file sink to be kept")
+		}
+	})
+
+	t.Run("Edited alerting files rebuild the alerting processor", func(t *testing.T) {
+		rulesFile := writeConfigFile(t, "pacmon.rules", "TankTemperature < 40\n")
+		templateFile := writeConfigFile(t, "webhook.tmpl", `{"text": "{{.Rule}}"}`)
+		alertingConfig := func() *pacMonConfig {
+			config := newConfig()
+			config.alerting = &alertingConfig{
+				rulesFile:       rulesFile,
+				notifiers:       []string{"https://localhost:1/hook"},
+				webhookTemplate: templateFile,
+			}
+			return config
+		}
+		p := startPipeline(t, alertingConfig())
+		alerting := p.processors["alerting"]
+
+		if err := p.reload(alertingConfig()); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if p.processors["alerting"] != alerting {
+			t.Error("Expected the alerting processor to be kept")
+		}
+
+		if err := os.WriteFile(templateFile, []byte(`{"body": "{{.Rule}}"}`), 0600); err != nil {
+			t.Fatal(err)
+		}
+		if err := p.reload(alertingConfig()); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if p.processors["alerting"] == alerting {
+			t.Error("Expected the alerting processor to be rebuilt after the template was edited")
+		}
+	})
+
+	t.Run("Settings requiring a restart are kept", func(t *testing.T) {
+		p := startPipeline(t, newConfig())
+
+		config := newConfig()
+		config.httpAddr = ":9200"
+		config.grpcAddr = ":9090"
+		if err := p.reload(config); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		if p.config.httpAddr != ":9100" || p.config.grpcAddr != "" {
+			t.Errorf("Expected the servers to be kept, got %q and %q", p.config.httpAddr, p.config.grpcAddr)
+		}
+	})
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// reloadOnSignal reloads the configuration file on SIGHUP
+func reloadOnSignal(p *pipeline, path string) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGHUP)
+
+	for range signals {
+		reloadFile(p, path)
+	}
+}
+
+// watchConfig reloads the configuration file when its modification time or size changes
+func watchConfig(p *pipeline, path string, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	last, _ := os.Stat(path)
+	for range ticker.C {
+		info, err := os.Stat(path)
+		if err != nil {
+			continue
+		}
+		if last != nil && info.ModTime().Equal(last.ModTime()) && info.Size() == last.Size() {
+			continue
+		}
+		last = info
+		reloadFile(p, path)
+	}
+}
+
+// reloadFile applies the configuration file, the running configuration being kept when it is invalid
+func reloadFile(p *pipeline, path string) error {
+	log.Printf("Reloading %s", path)
+	config, err := loadConfigFile(path)
+	if err == nil {
+		err = p.reload(config)
+	}
+	if err != nil {
+		log.Printf("Failed to reload %s, keeping the running configuration: %v", path, err)
+	}
+	return err
+}
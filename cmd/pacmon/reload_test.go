@@ -0,0 +1,49 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestWatchConfig(t *testing.T) {
+	dir := t.TempDir()
+	path := writeConfigFile(t, "pacmon.yaml", "sources:\n  swc:\n    url: ws://localhost:1\nsinks:\n  file:\n    path: "+filepath.Join(dir, "pacmon.jsonl")+"\n")
+	config, err := loadConfigFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := startPipeline(t, config)
+
+	t.Run("Invalid files are not applied", func(t *testing.T) {
+		os.WriteFile(path, []byte("sources:\n  swc:\n    url: http://localhost:1\n"), 0600)
+
+		if err := reloadFile(p, path); err == nil {
+			t.Error("Expected an error")
+		}
+		if p.config != config {
+			t.Error("Expected the running configuration to be kept")
+		}
+	})
+
+	t.Run("Changes are reloaded", func(t *testing.T) {
+		go watchConfig(p, path, 10*time.Millisecond)
+		time.Sleep(50 * time.Millisecond)
+		os.WriteFile(path, []byte("sources:\n  swc:\n    url: ws://localhost:1\n    pollingInterval: 2m\nsinks:\n  file:\n    path: "+filepath.Join(dir, "pacmon.jsonl")+"\n"), 0600)
+
+		deadline := time.Now().Add(5 * time.Second)
+		for {
+			p.mutex.Lock()
+			interval := p.config.pollingInterval
+			p.mutex.Unlock()
+			if interval == 2*time.Minute {
+				break
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("Expected the change to be reloaded, got a polling interval of %v", interval)
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	})
+}
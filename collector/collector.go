@@ -1,23 +1,134 @@
 package collector
 
 import (
+	"sync"
+
 	"github.com/renajohn/pac_collector/api"
 )
 
-// Collector bind a source to a store
+// Collector bind a source to a store, optionally running measurements through a chain of processors
 type Collector struct {
-	Source api.Source
-	Sink   api.Sink
+	Source     api.Source
+	Processors []api.Processor
+	Sink       api.Sink
+
+	mutex    sync.Mutex
+	stop     chan struct{}
+	replace  chan []api.Processor
+	stopOnce sync.Once
 }
 
-// Start initiate the collection process
+// Start initiate the collection process. It returns once the source is exhausted or Stop is called,
+// after buffered measurements have been flushed to the sink.
 func (c *Collector) Start() {
 	go c.Source.Start()
 	c.collect()
+	c.flush()
+}
+
+// Stop ends the collection process
+func (c *Collector) Stop() {
+	c.stopOnce.Do(func() {
+		close(c.stopChannel())
+	})
+}
+
+func (c *Collector) stopChannel() chan struct{} {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.stop == nil {
+		c.stop = make(chan struct{})
+	}
+	return c.stop
+}
+
+// SetProcessors replaces the chain of processors while collecting. The buffering processors
+// which are not part of the new chain are flushed first.
+func (c *Collector) SetProcessors(processors []api.Processor) {
+	select {
+	case c.replaceChannel() <- processors:
+	case <-c.stopChannel():
+	}
+}
+
+func (c *Collector) replaceChannel() chan []api.Processor {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.replace == nil {
+		c.replace = make(chan []api.Processor)
+	}
+	return c.replace
 }
 
 func (c *Collector) collect() {
-	for measure := range c.Source.MeasurementsChannel() {
+	stop := c.stopChannel()
+	replace := c.replaceChannel()
+	for {
+		select {
+		case measure, ok := <-c.Source.MeasurementsChannel():
+			if !ok {
+				return
+			}
+			c.put(c.process(measure, c.Processors))
+		case processors := <-replace:
+			c.flushRemoved(processors)
+			c.Processors = processors
+		case <-stop:
+			return
+		}
+	}
+}
+
+func (c *Collector) put(measurements []api.Measurement) {
+	for _, measure := range measurements {
 		c.Sink.Put(measure)
 	}
 }
+
+// process runs a measurement through each processor, in order
+func (c *Collector) process(measure api.Measurement, processors []api.Processor) []api.Measurement {
+	measurements := []api.Measurement{measure}
+	for _, processor := range processors {
+		var next []api.Measurement
+		for _, m := range measurements {
+			next = append(next, processor.Process(m)...)
+		}
+		measurements = next
+	}
+	return measurements
+}
+
+// flushRemoved empties the buffering processors missing from the new chain, flushed measurements
+// going through the remaining processors of the current chain
+func (c *Collector) flushRemoved(processors []api.Processor) {
+	kept := make(map[api.Processor]bool, len(processors))
+	for _, processor := range processors {
+		kept[processor] = true
+	}
+
+	for index, processor := range c.Processors {
+		flusher, ok := processor.(api.Flusher)
+		if !ok || kept[processor] {
+			continue
+		}
+		for _, flushed := range flusher.Flush() {
+			c.put(c.process(flushed, c.Processors[index+1:]))
+		}
+	}
+}
+
+// flush empties the buffering processors, flushed measurements go through the remaining processors
+func (c *Collector) flush() {
+	for index, processor := range c.Processors {
+		flusher, ok := processor.(api.Flusher)
+		if !ok {
+			continue
+		}
+
+		for _, flushed := range flusher.Flush() {
+			c.put(c.process(flushed, c.Processors[index+1:]))
+		}
+	}
+}
@@ -3,6 +3,7 @@ package collector
 import (
 	"reflect"
 	"testing"
+	"time"
 
 	"github.com/renajohn/pac_collector/api"
 	"github.com/renajohn/pac_collector/internal/mocksink"
@@ -76,3 +77,129 @@ func TestStart(t *testing.T) {
 		assertMeasurements(measurements, mockSink.Values)
 	})
 }
+
+type dropOddProcessor struct{}
+
+func (p *dropOddProcessor) Process(m api.Measurement) []api.Measurement {
+	if m.Timestamp%2 == 1 {
+		return nil
+	}
+	return []api.Measurement{m}
+}
+
+type duplicateProcessor struct{}
+
+func (p *duplicateProcessor) Process(m api.Measurement) []api.Measurement {
+	return []api.Measurement{m, m}
+}
+
+func TestProcessors(t *testing.T) {
+	t.Run("Measurements go through processors in order", func(t *testing.T) {
+		source := MockSource{make(chan api.Measurement, 2), make(chan error, 1)}
+		mockSink := mocksink.MockSink{}
+		odd := api.Measurement{MeasurementType: api.SWCTemperature, Timestamp: 1, Value: []byte("44")}
+		even := api.Measurement{MeasurementType: api.SWCTemperature, Timestamp: 2, Value: []byte("10")}
+
+		sendMeasurements(source.measurementsChannel, []api.Measurement{odd, even})
+
+		collector := Collector{
+			Sink:       &mockSink,
+			Source:     &source,
+			Processors: []api.Processor{&dropOddProcessor{}, &duplicateProcessor{}},
+		}
+		collector.Start()
+
+		expected := []api.Measurement{even, even}
+		if !reflect.DeepEqual(expected, mockSink.Values) {
+			t.Errorf("Expected value of %+v got %+v", expected, mockSink.Values)
+		}
+	})
+}
+
+type bufferingProcessor struct {
+	buffer []api.Measurement
+}
+
+func (p *bufferingProcessor) Process(m api.Measurement) []api.Measurement {
+	p.buffer = append(p.buffer, m)
+	return nil
+}
+
+func (p *bufferingProcessor) Flush() []api.Measurement {
+	flushed := p.buffer
+	p.buffer = nil
+	return flushed
+}
+
+func TestStop(t *testing.T) {
+	t.Run("Stop flushes buffered measurements through the remaining processors", func(t *testing.T) {
+		source := MockSource{make(chan api.Measurement, 1), make(chan error, 1)}
+		mockSink := mocksink.MockSink{}
+		measurement := api.Measurement{MeasurementType: api.SWCTemperature, Timestamp: 2, Value: []byte("44")}
+		source.measurementsChannel <- measurement
+
+		collector := Collector{
+			Sink:       &mockSink,
+			Source:     &source,
+			Processors: []api.Processor{&bufferingProcessor{}, &duplicateProcessor{}},
+		}
+
+		done := make(chan bool)
+		go func() {
+			collector.Start()
+			done <- true
+		}()
+
+		// make sure the measurement has been buffered
+		for len(source.measurementsChannel) > 0 {
+			time.Sleep(time.Millisecond)
+		}
+		collector.Stop()
+		<-done
+
+		expected := []api.Measurement{measurement, measurement}
+		if !reflect.DeepEqual(expected, mockSink.Values) {
+			t.Errorf("Expected value of %+v got %+v", expected, mockSink.Values)
+		}
+	})
+}
+
+func TestSetProcessors(t *testing.T) {
+	t.Run("Measurements go through the new chain once the removed processors are flushed", func(t *testing.T) {
+		source := MockSource{make(chan api.Measurement), make(chan error, 1)}
+		mockSink := mocksink.MockSink{}
+		buffered := api.Measurement{MeasurementType: api.SWCTemperature, Timestamp: 2, Value: []byte("44")}
+		odd := api.Measurement{MeasurementType: api.SWCTemperature, Timestamp: 3, Value: []byte("45")}
+		even := api.Measurement{MeasurementType: api.SWCTemperature, Timestamp: 4, Value: []byte("46")}
+
+		collector := Collector{
+			Sink:       &mockSink,
+			Source:     &source,
+			Processors: []api.Processor{&bufferingProcessor{}, &duplicateProcessor{}},
+		}
+
+		done := make(chan bool)
+		go func() {
+			collector.Start()
+			done <- true
+		}()
+
+		source.measurementsChannel <- buffered
+		collector.SetProcessors([]api.Processor{&dropOddProcessor{}})
+		source.measurementsChannel <- odd
+		source.measurementsChannel <- even
+		close(source.measurementsChannel)
+		<-done
+
+		expected := []api.Measurement{buffered, buffered, even}
+		if !reflect.DeepEqual(expected, mockSink.Values) {
+			t.Errorf("Expected value of %+v got %+v", expected, mockSink.Values)
+		}
+	})
+
+	t.Run("Setting the processors of a stopped collector does not block", func(t *testing.T) {
+		collector := Collector{}
+		collector.Stop()
+		collector.SetProcessors(nil)
+	})
+}
@@ -0,0 +1,28 @@
+#!/bin/sh
+# Runs pacmon out of the CONFIG file, or out of the environment variables of the Dockerfile otherwise.
+# The configuration file is reloaded on SIGHUP, or every CONFIG_WATCH seconds when it changed.
+# Extra arguments are passed on, e.g. -healthcheck to probe the running pacmon with the same configuration.
+
+if [ -n "${CONFIG}" ]; then
+	exec /app/pacmon -config="${CONFIG}" -configWatch="${CONFIG_WATCH:-0}" "$@"
+fi
+
+exec /app/pacmon \
+	-sourceURL="${SOURCE_URL:-ws://192.168.086.29:8214/}" \
+	-pollingInterval="${POLLING_INTERVAL:-60}" \
+	-sinkURL="${SINK_URL:-localhost:29092}" \
+	-topic="${TOPIC:-SWCTemperature}" \
+	-mqttURL="${MQTT_URL}" \
+	-influxURL="${INFLUX_URL}" \
+	-postgresURL="${POSTGRES_URL}" \
+	-remoteWriteURL="${REMOTE_WRITE_URL}" \
+	-otlpEndpoint="${OTLP_ENDPOINT}" \
+	-filePath="${FILE_PATH}" \
+	-parquetRoot="${PARQUET_ROOT}" \
+	-sqlitePath="${SQLITE_PATH}" \
+	-httpAddr="${HTTP_ADDR}" \
+	-dashboard="${DASHBOARD}" \
+	-stream="${STREAM}" \
+	-controlAPI="${CONTROL_API}" \
+	-grpcAddr="${GRPC_ADDR}" \
+	"$@"
@@ -0,0 +1,161 @@
+package aggregateprocessor
+
+import (
+	"encoding/json"
+	"sort"
+	"time"
+
+	"github.com/renajohn/pac_collector/api"
+	"github.com/renajohn/pac_collector/internal/fields"
+)
+
+// AggregateProcessor buffers measurements per type and device in tumbling windows aligned to
+// wall-clock boundaries, and emits min, max, mean, last and sample count of every numeric field.
+// A window is emitted when the first measurement of the next window arrives, or on Flush.
+type AggregateProcessor struct {
+	Window           time.Duration
+	MeasurementTypes map[api.MeasurementType]bool // types to aggregate, others are forwarded untouched
+
+	windows map[seriesKey]*window
+}
+
+type seriesKey struct {
+	measurementType api.MeasurementType
+	device          string
+}
+
+type window struct {
+	start int64
+	stats map[string]*stats
+}
+
+type stats struct {
+	min   float64
+	max   float64
+	sum   float64
+	last  float64
+	count int
+}
+
+// AggregateType returns the measurement type of the aggregates of a given type
+func AggregateType(measurementType api.MeasurementType) api.MeasurementType {
+	return measurementType + "Aggregate"
+}
+
+// NewAggregateProcessor creates a new AggregateProcessor aggregating the given measurement types
+func NewAggregateProcessor(windowSize time.Duration, measurementTypes ...api.MeasurementType) *AggregateProcessor {
+	processor := AggregateProcessor{
+		Window:           windowSize,
+		MeasurementTypes: make(map[api.MeasurementType]bool),
+		windows:          make(map[seriesKey]*window),
+	}
+	for _, measurementType := range measurementTypes {
+		processor.MeasurementTypes[measurementType] = true
+	}
+
+	return &processor
+}
+
+// Process satisfies the api.Processor interface
+func (ap *AggregateProcessor) Process(m api.Measurement) []api.Measurement {
+	if !ap.MeasurementTypes[m.MeasurementType] {
+		return []api.Measurement{m}
+	}
+
+	values, err := fields.Numeric(m)
+	if err != nil {
+		return []api.Measurement{m}
+	}
+
+	var emitted []api.Measurement
+	key := seriesKey{measurementType: m.MeasurementType, device: m.Device}
+	start := ap.windowStart(m.Timestamp)
+
+	current, found := ap.windows[key]
+	if found && current.start != start {
+		emitted = append(emitted, current.summary(key))
+		found = false
+	}
+	if !found {
+		current = &window{start: start, stats: make(map[string]*stats)}
+		ap.windows[key] = current
+	}
+	current.add(values)
+
+	return emitted
+}
+
+// Flush satisfies the api.Flusher interface, emitting all open windows
+func (ap *AggregateProcessor) Flush() []api.Measurement {
+	keys := make([]seriesKey, 0, len(ap.windows))
+	for key := range ap.windows {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].measurementType != keys[j].measurementType {
+			return keys[i].measurementType < keys[j].measurementType
+		}
+		return keys[i].device < keys[j].device
+	})
+
+	var emitted []api.Measurement
+	for _, key := range keys {
+		emitted = append(emitted, ap.windows[key].summary(key))
+		delete(ap.windows, key)
+	}
+
+	return emitted
+}
+
+func (ap *AggregateProcessor) windowStart(timestamp int64) int64 {
+	size := int64(ap.Window / time.Second)
+	if size <= 0 {
+		return timestamp
+	}
+
+	start := timestamp - timestamp%size
+	if timestamp < 0 && timestamp%size != 0 {
+		start -= size
+	}
+	return start
+}
+
+func (w *window) add(values map[string]float64) {
+	for name, value := range values {
+		s, found := w.stats[name]
+		if !found {
+			w.stats[name] = &stats{min: value, max: value, sum: value, last: value, count: 1}
+			continue
+		}
+
+		if value < s.min {
+			s.min = value
+		}
+		if value > s.max {
+			s.max = value
+		}
+		s.sum += value
+		s.last = value
+		s.count++
+	}
+}
+
+// summary flattens the statistics so that every field of the aggregate remains a plain number
+func (w *window) summary(key seriesKey) api.Measurement {
+	values := make(map[string]float64, len(w.stats)*5)
+	for name, s := range w.stats {
+		values[name+"Min"] = s.min
+		values[name+"Max"] = s.max
+		values[name+"Mean"] = s.sum / float64(s.count)
+		values[name+"Last"] = s.last
+		values[name+"Count"] = float64(s.count)
+	}
+
+	data, _ := json.Marshal(values)
+	return api.Measurement{
+		MeasurementType: AggregateType(key.measurementType),
+		Device:          key.device,
+		Timestamp:       w.start,
+		Value:           data,
+	}
+}
@@ -0,0 +1,99 @@
+package aggregateprocessor
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/renajohn/pac_collector/api"
+	"github.com/renajohn/pac_collector/internal/fields"
+)
+
+func makeMeasurement(timestamp int64, value string) api.Measurement {
+	return api.Measurement{
+		MeasurementType: api.SWCTemperature,
+		Device:          "swc",
+		Timestamp:       timestamp,
+		Value:           []byte(value),
+	}
+}
+
+func assertSummary(t *testing.T, expect map[string]float64, got api.Measurement) {
+	t.Helper()
+	values, err := fields.Numeric(got)
+	if err != nil {
+		t.Fatalf("Failed to decode aggregate: %v", err)
+	}
+	if !reflect.DeepEqual(expect, values) {
+		t.Errorf("Expected value of %v got %v", expect, values)
+	}
+}
+
+func TestProcess(t *testing.T) {
+	t.Run("Window is emitted when the next one starts", func(t *testing.T) {
+		processor := NewAggregateProcessor(5*time.Minute, api.SWCTemperature)
+
+		emitted := processor.Process(makeMeasurement(1200, `{"TankTemperature":50}`))
+		emitted = append(emitted, processor.Process(makeMeasurement(1260, `{"TankTemperature":54}`))...)
+		emitted = append(emitted, processor.Process(makeMeasurement(1380, `{"TankTemperature":52}`))...)
+		if len(emitted) != 0 {
+			t.Fatalf("Expected no aggregate within the window, got %d", len(emitted))
+		}
+
+		emitted = processor.Process(makeMeasurement(1500, `{"TankTemperature":40}`))
+
+		if len(emitted) != 1 {
+			t.Fatalf("Expected 1 aggregate, got %d", len(emitted))
+		}
+		if emitted[0].MeasurementType != "SWCTemperatureAggregate" || emitted[0].Timestamp != 1200 || emitted[0].Device != "swc" {
+			t.Errorf("Unexpected aggregate %+v", emitted[0])
+		}
+		assertSummary(t, map[string]float64{
+			"TankTemperatureMin":   50,
+			"TankTemperatureMax":   54,
+			"TankTemperatureMean":  52,
+			"TankTemperatureLast":  52,
+			"TankTemperatureCount": 3,
+		}, emitted[0])
+	})
+
+	t.Run("Windows are aligned on wall-clock boundaries", func(t *testing.T) {
+		processor := NewAggregateProcessor(5*time.Minute, api.SWCTemperature)
+
+		processor.Process(makeMeasurement(1390, `{"TankTemperature":50}`))
+		emitted := processor.Process(makeMeasurement(1510, `{"TankTemperature":50}`))
+
+		if len(emitted) != 1 || emitted[0].Timestamp != 1200 {
+			t.Errorf("Expected a window starting at 1200, got %+v", emitted)
+		}
+	})
+
+	t.Run("Other measurement types are forwarded", func(t *testing.T) {
+		processor := NewAggregateProcessor(5*time.Minute, api.SWCTemperature)
+		other := makeMeasurement(1200, `{"Foo":1}`)
+		other.MeasurementType = "Other"
+
+		emitted := processor.Process(other)
+
+		if !reflect.DeepEqual([]api.Measurement{other}, emitted) {
+			t.Errorf("Expected measurement to be forwarded, got %+v", emitted)
+		}
+	})
+
+	t.Run("Flush emits all open windows", func(t *testing.T) {
+		processor := NewAggregateProcessor(5*time.Minute, api.SWCTemperature)
+		other := makeMeasurement(1200, `{"TankTemperature":42}`)
+		other.Device = "other"
+
+		processor.Process(makeMeasurement(1200, `{"TankTemperature":50}`))
+		processor.Process(other)
+		emitted := processor.Flush()
+
+		if len(emitted) != 2 || emitted[0].Device != "other" || emitted[1].Device != "swc" {
+			t.Fatalf("Expected one aggregate per device, got %+v", emitted)
+		}
+		if len(processor.Flush()) != 0 {
+			t.Error("Expected windows to be emptied by Flush")
+		}
+	})
+}
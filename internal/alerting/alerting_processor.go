@@ -0,0 +1,172 @@
+package alerting
+
+import (
+	"log"
+	"time"
+
+	"github.com/renajohn/pac_collector/api"
+	"github.com/renajohn/pac_collector/internal/fields"
+)
+
+// AlertingProcessor evaluates rules over the measurements flowing through it, and notifies every time
+// a rule starts firing or is resolved. Rules are evaluated per device, measurements are forwarded untouched.
+type AlertingProcessor struct {
+	Rules    []Rule
+	Notifier Notifier
+
+	states map[stateKey]*ruleState
+}
+
+type stateKey struct {
+	rule   int
+	device string
+}
+
+type ruleState struct {
+	pending      bool
+	pendingSince int64
+	firing       bool
+	firingSince  int64
+	history      []point // rise and drop rules only
+}
+
+type point struct {
+	timestamp int64
+	value     float64
+}
+
+// deferringNotifier is implemented by the notifiers holding alerts back, which are delivered while
+// measurements flow so that the sinks are only written to from the collector
+type deferringNotifier interface {
+	DeliverDue()
+	DeliverAll()
+}
+
+// NewAlertingProcessor creates a new AlertingProcessor
+func NewAlertingProcessor(rules []Rule, notifier Notifier) *AlertingProcessor {
+	processor := AlertingProcessor{
+		Rules:    rules,
+		Notifier: notifier,
+		states:   make(map[stateKey]*ruleState),
+	}
+
+	return &processor
+}
+
+// Process satisfies the api.Processor interface
+func (ap *AlertingProcessor) Process(m api.Measurement) []api.Measurement {
+	if deferring, ok := ap.Notifier.(deferringNotifier); ok {
+		deferring.DeliverDue()
+	}
+
+	values, err := fields.Numeric(m)
+	if err != nil {
+		return []api.Measurement{m}
+	}
+
+	for index, rule := range ap.Rules {
+		value, found := values[rule.Field]
+		if !found || (len(rule.MeasurementType) > 0 && rule.MeasurementType != m.MeasurementType) {
+			continue
+		}
+
+		key := stateKey{rule: index, device: m.Device}
+		state, found := ap.states[key]
+		if !found {
+			state = &ruleState{}
+			ap.states[key] = state
+		}
+
+		if rule.Kind != Threshold {
+			var ok bool
+			value, ok = state.rate(rule, point{timestamp: m.Timestamp, value: value})
+			if !ok {
+				continue
+			}
+		}
+
+		ap.evaluate(rule, state, m, value)
+	}
+
+	return []api.Measurement{m}
+}
+
+// Flush satisfies the api.Flusher interface, delivering the alerts held back by the notifier. The
+// notifier is then closed, if it implements io.Closer, as the processor is dropped.
+func (ap *AlertingProcessor) Flush() []api.Measurement {
+	if deferring, ok := ap.Notifier.(deferringNotifier); ok {
+		deferring.DeliverAll()
+	}
+	if err := closeNotifier(ap.Notifier); err != nil {
+		log.Printf("Failed to close the notifier: %v", err)
+	}
+
+	return nil
+}
+
+// rate returns the rate of change per rule.RateUnit, positive in the direction of the rule. The rate is
+// only known once the history spans at least half of the unit, to avoid firing on a couple of samples.
+func (state *ruleState) rate(rule Rule, p point) (float64, bool) {
+	state.history = append(state.history, p)
+	windowStart := p.timestamp - int64(rule.RateUnit/time.Second)
+	for len(state.history) > 1 && state.history[1].timestamp <= windowStart {
+		state.history = state.history[1:]
+	}
+
+	first := state.history[0]
+	span := time.Duration(p.timestamp-first.timestamp) * time.Second
+	if span <= 0 || span < rule.RateUnit/2 {
+		return 0, false
+	}
+
+	rate := (p.value - first.value) / float64(span) * float64(rule.RateUnit)
+	if rule.Kind == Drop {
+		rate = -rate
+	}
+	return rate, true
+}
+
+func (ap *AlertingProcessor) evaluate(rule Rule, state *ruleState, m api.Measurement, value float64) {
+	if state.firing {
+		if rule.recovered(value) {
+			state.firing = false
+			state.pending = false
+			ap.notify(rule, state, m, value, Resolved)
+		}
+		return
+	}
+
+	if !rule.matches(value) {
+		state.pending = false
+		return
+	}
+
+	if !state.pending {
+		state.pending = true
+		state.pendingSince = m.Timestamp
+	}
+	if time.Duration(m.Timestamp-state.pendingSince)*time.Second >= rule.For {
+		state.firing = true
+		state.firingSince = m.Timestamp
+		ap.notify(rule, state, m, value, Firing)
+	}
+}
+
+func (ap *AlertingProcessor) notify(rule Rule, state *ruleState, m api.Measurement, value float64, alertState string) {
+	alert := Alert{
+		Rule:        rule.Name,
+		Expression:  rule.Expression,
+		State:       alertState,
+		Device:      m.Device,
+		Field:       rule.Field,
+		Value:       value,
+		Threshold:   rule.Threshold,
+		Timestamp:   m.Timestamp,
+		FiringSince: state.firingSince,
+	}
+
+	err := ap.Notifier.Notify(alert)
+	if err != nil {
+		log.Printf("Failed to notify alert %s: %v", rule.Name, err)
+	}
+}
@@ -0,0 +1,162 @@
+package alerting
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/renajohn/pac_collector/api"
+)
+
+type mockNotifier struct {
+	alerts []Alert
+}
+
+func (mn *mockNotifier) Notify(alert Alert) error {
+	mn.alerts = append(mn.alerts, alert)
+	return nil
+}
+
+func makeMeasurement(timestamp int64, field string, value float64) api.Measurement {
+	return api.Measurement{
+		MeasurementType: api.SWCTemperature,
+		Device:          "swc",
+		Timestamp:       timestamp,
+		Value:           []byte(fmt.Sprintf(`{"%s":%v}`, field, value)),
+	}
+}
+
+func newProcessor(t *testing.T, expression string) (*AlertingProcessor, *mockNotifier) {
+	t.Helper()
+	rule, err := ParseRule(expression)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	notifier := mockNotifier{}
+	return NewAlertingProcessor([]Rule{rule}, &notifier), &notifier
+}
+
+func assertStates(t *testing.T, expect []string, notifier *mockNotifier) {
+	t.Helper()
+	var states []string
+	for _, alert := range notifier.alerts {
+		states = append(states, alert.State)
+	}
+	if fmt.Sprint(expect) != fmt.Sprint(states) {
+		t.Errorf("Expected alerts %v got %v", expect, states)
+	}
+}
+
+func TestProcess(t *testing.T) {
+	t.Run("Threshold fires and resolves", func(t *testing.T) {
+		processor, notifier := newProcessor(t, "DrillOutboundTemperature < -2")
+
+		processor.Process(makeMeasurement(0, "DrillOutboundTemperature", -1))
+		processor.Process(makeMeasurement(60, "DrillOutboundTemperature", -3))
+		processor.Process(makeMeasurement(120, "DrillOutboundTemperature", -4))
+		processor.Process(makeMeasurement(180, "DrillOutboundTemperature", 0))
+
+		assertStates(t, []string{Firing, Resolved}, notifier)
+		if notifier.alerts[0].Value != -3 || notifier.alerts[1].FiringSince != 60 {
+			t.Errorf("Unexpected alerts %+v", notifier.alerts)
+		}
+	})
+
+	t.Run("Condition must hold for the duration", func(t *testing.T) {
+		processor, notifier := newProcessor(t, "TankTemperature < 40 for 10m")
+
+		processor.Process(makeMeasurement(0, "TankTemperature", 39))
+		processor.Process(makeMeasurement(300, "TankTemperature", 41))
+		processor.Process(makeMeasurement(360, "TankTemperature", 39))
+		processor.Process(makeMeasurement(900, "TankTemperature", 39))
+		assertStates(t, nil, notifier)
+
+		processor.Process(makeMeasurement(960, "TankTemperature", 39))
+		assertStates(t, []string{Firing}, notifier)
+	})
+
+	t.Run("Hysteresis delays the resolution", func(t *testing.T) {
+		processor, notifier := newProcessor(t, "TankTemperature < 40 hysteresis 2")
+
+		processor.Process(makeMeasurement(0, "TankTemperature", 39))
+		processor.Process(makeMeasurement(60, "TankTemperature", 41))
+		processor.Process(makeMeasurement(120, "TankTemperature", 39))
+		assertStates(t, []string{Firing}, notifier)
+
+		processor.Process(makeMeasurement(180, "TankTemperature", 42))
+		assertStates(t, []string{Firing, Resolved}, notifier)
+	})
+
+	t.Run("Drop rate is computed over the unit", func(t *testing.T) {
+		processor, notifier := newProcessor(t, "OutsideTemperature drop > 5/h")
+
+		processor.Process(makeMeasurement(0, "OutsideTemperature", 10))
+		processor.Process(makeMeasurement(900, "OutsideTemperature", 8))
+		assertStates(t, nil, notifier)
+
+		processor.Process(makeMeasurement(1800, "OutsideTemperature", 6.5))
+		processor.Process(makeMeasurement(3600, "OutsideTemperature", 4))
+		assertStates(t, []string{Firing}, notifier)
+		if notifier.alerts[0].Value != 7 {
+			t.Errorf("Expected a drop of 7/h, got %v", notifier.alerts[0].Value)
+		}
+
+		processor.Process(makeMeasurement(7200, "OutsideTemperature", 4))
+		assertStates(t, []string{Firing, Resolved}, notifier)
+	})
+
+	t.Run("Rules only apply to their measurement type", func(t *testing.T) {
+		processor, notifier := newProcessor(t, "Other.TankTemperature < 40")
+
+		processor.Process(makeMeasurement(0, "TankTemperature", 39))
+
+		assertStates(t, nil, notifier)
+	})
+
+	t.Run("Measurements are forwarded", func(t *testing.T) {
+		processor, _ := newProcessor(t, "TankTemperature < 40")
+		measurement := makeMeasurement(0, "TankTemperature", 39)
+
+		processed := processor.Process(measurement)
+
+		if len(processed) != 1 || string(processed[0].Value) != string(measurement.Value) {
+			t.Errorf("Expected measurement to be forwarded, got %+v", processed)
+		}
+	})
+}
+
+func TestDeferredAlerts(t *testing.T) {
+	t.Run("Alerts held back are delivered by the processor", func(t *testing.T) {
+		rule, err := ParseRule("DrillOutboundTemperature < -2")
+		if err != nil {
+			t.Fatal(err)
+		}
+		notifier := mockNotifier{}
+		throttled := NewThrottledNotifier(&notifier, 20*time.Millisecond)
+		processor := NewAlertingProcessor([]Rule{rule}, throttled)
+
+		processor.Process(makeMeasurement(0, "DrillOutboundTemperature", -3))
+		processor.Process(makeMeasurement(60, "DrillOutboundTemperature", 0))
+		assertStates(t, []string{Firing}, &notifier)
+
+		time.Sleep(30 * time.Millisecond)
+		processor.Process(makeMeasurement(120, "DrillOutboundTemperature", 0))
+		assertStates(t, []string{Firing, Resolved}, &notifier)
+	})
+
+	t.Run("Flush delivers every alert held back", func(t *testing.T) {
+		rule, err := ParseRule("DrillOutboundTemperature < -2")
+		if err != nil {
+			t.Fatal(err)
+		}
+		notifier := mockNotifier{}
+		processor := NewAlertingProcessor([]Rule{rule}, NewThrottledNotifier(&notifier, time.Hour))
+
+		processor.Process(makeMeasurement(0, "DrillOutboundTemperature", -3))
+		processor.Process(makeMeasurement(60, "DrillOutboundTemperature", 0))
+		processor.Flush()
+
+		assertStates(t, []string{Firing, Resolved}, &notifier)
+	})
+}
@@ -0,0 +1,102 @@
+package alerting
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+
+	"github.com/renajohn/pac_collector/api"
+)
+
+// Alert states
+const (
+	Firing   = "firing"
+	Resolved = "resolved"
+)
+
+// Alert is sent to a notifier every time a rule starts firing or is resolved
+type Alert struct {
+	Rule        string
+	Expression  string
+	State       string
+	Device      string
+	Field       string
+	Value       float64 // value, or rate per unit for rise and drop rules, which triggered the change
+	Threshold   float64
+	Timestamp   int64
+	FiringSince int64 // when the rule started firing, set on both states
+	Repeats     int   // number of notifications of the same rule folded into this one by a ThrottledNotifier
+}
+
+// Summary returns a one line description of the alert, suitable for a title or a subject
+func (alert Alert) Summary() string {
+	summary := fmt.Sprintf("[%s] %s on %s: %s = %v (threshold %v)", alert.State, alert.Rule, alert.Device, alert.Field, alert.Value, alert.Threshold)
+	if alert.Repeats > 0 {
+		summary += fmt.Sprintf(", %d repeated", alert.Repeats)
+	}
+	return summary
+}
+
+// Notifier delivers alerts
+type Notifier interface {
+	Notify(alert Alert) error
+}
+
+// SinkNotifier delivers alerts as api.Alert measurements to a sink
+type SinkNotifier struct {
+	Sink api.Sink
+}
+
+// NewSinkNotifier creates a new SinkNotifier
+func NewSinkNotifier(sink api.Sink) *SinkNotifier {
+	return &SinkNotifier{Sink: sink}
+}
+
+// Notify satisfies the Notifier interface
+func (sn *SinkNotifier) Notify(alert Alert) error {
+	data, _ := json.Marshal(alert)
+
+	return sn.Sink.Put(api.Measurement{
+		MeasurementType: api.Alert,
+		Device:          alert.Device,
+		Timestamp:       alert.Timestamp,
+		Value:           data,
+	})
+}
+
+// LogNotifier writes alerts to the log
+type LogNotifier struct {
+}
+
+// Notify satisfies the Notifier interface
+func (ln *LogNotifier) Notify(alert Alert) error {
+	log.Printf("Alert %s", alert.Summary())
+	return nil
+}
+
+// MultiNotifier delivers alerts to several notifiers
+type MultiNotifier []Notifier
+
+// Notify satisfies the Notifier interface, returning the first error after trying every notifier
+func (mn MultiNotifier) Notify(alert Alert) error {
+	var firstErr error
+	for _, notifier := range mn {
+		err := notifier.Notify(alert)
+		if err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// Close closes the notifiers implementing io.Closer, returning the first error
+func (mn MultiNotifier) Close() error {
+	var firstErr error
+	for _, notifier := range mn {
+		err := closeNotifier(notifier)
+		if err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
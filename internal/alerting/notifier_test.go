@@ -0,0 +1,29 @@
+package alerting
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/renajohn/pac_collector/api"
+	"github.com/renajohn/pac_collector/internal/mocksink"
+)
+
+func TestSinkNotifier(t *testing.T) {
+	t.Run("Alerts are put in the sink", func(t *testing.T) {
+		sink := mocksink.MockSink{}
+		notifier := NewSinkNotifier(&sink)
+		alert := Alert{Rule: "tank", State: Firing, Device: "swc", Field: "TankTemperature", Value: 39, Timestamp: 42}
+
+		notifier.Notify(alert)
+
+		measurement, err := sink.LastMeasurement()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got Alert
+		json.Unmarshal(measurement.Value, &got)
+		if measurement.MeasurementType != api.Alert || measurement.Timestamp != 42 || got != alert {
+			t.Errorf("Expected alert %+v got %+v", alert, measurement)
+		}
+	})
+}
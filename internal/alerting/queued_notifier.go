@@ -0,0 +1,82 @@
+package alerting
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"sync"
+)
+
+// DefaultQueueSize is the number of alerts a QueuedNotifier holds before dropping new ones
+const DefaultQueueSize = 100
+
+// QueuedNotifier delivers alerts from its own goroutine, so that a slow or unreachable notifier does
+// not hold up the measurements. Alerts are dropped, with an error, while the queue is full.
+type QueuedNotifier struct {
+	Notifier Notifier
+
+	mutex  sync.Mutex
+	closed bool
+	queue  chan Alert
+	done   chan struct{}
+}
+
+// NewQueuedNotifier creates a new QueuedNotifier, holding up to size alerts
+func NewQueuedNotifier(notifier Notifier, size int) *QueuedNotifier {
+	queued := QueuedNotifier{
+		Notifier: notifier,
+		queue:    make(chan Alert, size),
+		done:     make(chan struct{}),
+	}
+	go queued.deliver()
+
+	return &queued
+}
+
+// Notify satisfies the Notifier interface, the alert being delivered later on
+func (qn *QueuedNotifier) Notify(alert Alert) error {
+	qn.mutex.Lock()
+	defer qn.mutex.Unlock()
+
+	if qn.closed {
+		return fmt.Errorf("notifier closed, alert %s dropped", alert.Rule)
+	}
+	select {
+	case qn.queue <- alert:
+		return nil
+	default:
+		return fmt.Errorf("%d alerts waiting to be delivered, alert %s dropped", cap(qn.queue), alert.Rule)
+	}
+}
+
+// Close returns once the queued alerts are delivered
+func (qn *QueuedNotifier) Close() error {
+	qn.mutex.Lock()
+	if !qn.closed {
+		qn.closed = true
+		close(qn.queue)
+	}
+	qn.mutex.Unlock()
+
+	<-qn.done
+	return closeNotifier(qn.Notifier)
+}
+
+func (qn *QueuedNotifier) deliver() {
+	defer close(qn.done)
+
+	for alert := range qn.queue {
+		err := qn.Notifier.Notify(alert)
+		if err != nil {
+			log.Printf("Failed to notify alert %s: %v", alert.Rule, err)
+		}
+	}
+}
+
+// closeNotifier closes a notifier implementing io.Closer
+func closeNotifier(notifier Notifier) error {
+	if closer, ok := notifier.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
@@ -0,0 +1,76 @@
+package alerting
+
+import (
+	"testing"
+	"time"
+)
+
+type blockingNotifier struct {
+	release   chan struct{}
+	delivered chan Alert
+}
+
+func (bn *blockingNotifier) Notify(alert Alert) error {
+	<-bn.release
+	bn.delivered <- alert
+	return nil
+}
+
+func TestQueuedNotifier(t *testing.T) {
+	t.Run("Alerts are delivered without waiting for the notifier", func(t *testing.T) {
+		notifier := blockingNotifier{release: make(chan struct{}), delivered: make(chan Alert, 2)}
+		queued := NewQueuedNotifier(&notifier, 2)
+
+		done := make(chan error)
+		go func() { done <- queued.Notify(Alert{Rule: "tank"}) }()
+		select {
+		case err := <-done:
+			if err != nil {
+				t.Errorf("No error was expected but got %v", err)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("Expected Notify not to wait for the delivery")
+		}
+
+		close(notifier.release)
+		if alert := <-notifier.delivered; alert.Rule != "tank" {
+			t.Errorf("Unexpected alert %+v", alert)
+		}
+		queued.Close()
+	})
+
+	t.Run("Alerts are dropped while the queue is full", func(t *testing.T) {
+		notifier := blockingNotifier{release: make(chan struct{}), delivered: make(chan Alert, 3)}
+		queued := NewQueuedNotifier(&notifier, 1)
+
+		// the first alert is being delivered, the second one waits in the queue
+		queued.Notify(Alert{Rule: "first"})
+		for len(queued.queue) != 0 {
+			time.Sleep(time.Millisecond)
+		}
+		queued.Notify(Alert{Rule: "second"})
+
+		if err := queued.Notify(Alert{Rule: "third"}); err == nil {
+			t.Error("An error was expected and none was returned")
+		}
+		close(notifier.release)
+		queued.Close()
+		if len(notifier.delivered) != 2 {
+			t.Errorf("Expected 2 alerts to be delivered, got %d", len(notifier.delivered))
+		}
+	})
+
+	t.Run("Close waits for the queued alerts", func(t *testing.T) {
+		notifier := mockNotifier{}
+		queued := NewQueuedNotifier(&notifier, 10)
+		queued.Notify(Alert{Rule: "tank", State: Firing})
+		queued.Notify(Alert{Rule: "tank", State: Resolved})
+
+		queued.Close()
+
+		assertStates(t, []string{Firing, Resolved}, &notifier)
+		if err := queued.Notify(Alert{Rule: "tank"}); err == nil {
+			t.Error("Expected alerts to be refused once closed")
+		}
+	})
+}
@@ -0,0 +1,157 @@
+package alerting
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/renajohn/pac_collector/api"
+)
+
+// Rule kinds
+const (
+	Threshold = "threshold" // compares the value of a field
+	Rise      = "rise"      // compares how fast a field increases
+	Drop      = "drop"      // compares how fast a field decreases
+)
+
+// Rule describes a condition over a measurement field, such as:
+//
+//	TankTemperature < 40 for 10m
+//	outside-cooling: OutsideTemperature drop > 5°C/h
+//	SWCTemperature.DrillOutboundTemperature < -2 hysteresis 0.5
+type Rule struct {
+	Name            string
+	Expression      string
+	MeasurementType api.MeasurementType // empty to match any measurement with the field
+	Field           string
+	Kind            string
+	Operator        string        // <, <=, > or >=
+	Threshold       float64       // value, or rate per RateUnit
+	RateUnit        time.Duration // for Rise and Drop rules
+	For             time.Duration // how long the condition must hold before firing
+	Hysteresis      float64       // margin the value must cross back before resolving
+}
+
+var ruleRegexp = regexp.MustCompile(`^(?:([\w.-]+)\s*:\s*)?([\w]+(?:\.[\w]+)?)\s+(?:(rise|drop)\s+)?(<=|>=|<|>)\s*(-?[\d.]+)\s*(?:°C|K)?(?:/(s|m|h|d))?((?:\s+(?:for|hysteresis)\s+\S+)*)$`)
+
+var optionRegexp = regexp.MustCompile(`(for|hysteresis)\s+(\S+)`)
+
+var rateUnits = map[string]time.Duration{
+	"s": time.Second,
+	"m": time.Minute,
+	"h": time.Hour,
+	"d": 24 * time.Hour,
+}
+
+// ParseRule parses a rule expression
+func ParseRule(expression string) (Rule, error) {
+	expression = strings.TrimSpace(expression)
+	submatch := ruleRegexp.FindStringSubmatch(expression)
+	if submatch == nil {
+		return Rule{}, fmt.Errorf("invalid rule %q, expected \"[name:] Field <|<=|>|>= value [for duration] [hysteresis value]\"", expression)
+	}
+
+	rule := Rule{
+		Name:       submatch[1],
+		Expression: expression,
+		Kind:       Threshold,
+		Operator:   submatch[4],
+	}
+	if len(rule.Name) == 0 {
+		rule.Name = expression
+	}
+
+	if index := strings.Index(submatch[2], "."); index >= 0 {
+		rule.MeasurementType = api.MeasurementType(submatch[2][:index])
+		rule.Field = submatch[2][index+1:]
+	} else {
+		rule.Field = submatch[2]
+	}
+
+	threshold, err := strconv.ParseFloat(submatch[5], 64)
+	if err != nil {
+		return Rule{}, fmt.Errorf("invalid threshold in rule %q: %v", expression, err)
+	}
+	rule.Threshold = threshold
+
+	if len(submatch[3]) > 0 {
+		rule.Kind = submatch[3]
+		if len(submatch[6]) == 0 {
+			return Rule{}, fmt.Errorf("rule %q is missing a rate unit, e.g. 5/h", expression)
+		}
+		if rule.Operator != ">" && rule.Operator != ">=" {
+			return Rule{}, fmt.Errorf("rule %q must compare the rate with > or >=", expression)
+		}
+		rule.RateUnit = rateUnits[submatch[6]]
+	} else if len(submatch[6]) > 0 {
+		return Rule{}, fmt.Errorf("rule %q has a rate unit but is not a rise or drop rule", expression)
+	}
+
+	for _, option := range optionRegexp.FindAllStringSubmatch(submatch[7], -1) {
+		switch option[1] {
+		case "for":
+			rule.For, err = time.ParseDuration(option[2])
+		case "hysteresis":
+			rule.Hysteresis, err = strconv.ParseFloat(strings.TrimSuffix(strings.TrimSuffix(option[2], "°C"), "K"), 64)
+		}
+		if err != nil {
+			return Rule{}, fmt.Errorf("invalid %s in rule %q: %v", option[1], expression, err)
+		}
+	}
+
+	return rule, nil
+}
+
+// ParseRules parses one rule per line, ignoring empty lines and lines starting with #
+func ParseRules(reader io.Reader) ([]Rule, error) {
+	var rules []Rule
+
+	scanner := bufio.NewScanner(reader)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := strings.TrimSpace(scanner.Text())
+		if len(line) == 0 || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		rule, err := ParseRule(line)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", lineNumber, err)
+		}
+		rules = append(rules, rule)
+	}
+
+	return rules, scanner.Err()
+}
+
+// matches tells whether the value crosses the threshold
+func (rule Rule) matches(value float64) bool {
+	switch rule.Operator {
+	case "<":
+		return value < rule.Threshold
+	case "<=":
+		return value <= rule.Threshold
+	case ">":
+		return value > rule.Threshold
+	default:
+		return value >= rule.Threshold
+	}
+}
+
+// recovered tells whether the value went back beyond the threshold by the hysteresis margin
+func (rule Rule) recovered(value float64) bool {
+	switch rule.Operator {
+	case "<":
+		return value >= rule.Threshold+rule.Hysteresis
+	case "<=":
+		return value > rule.Threshold+rule.Hysteresis
+	case ">":
+		return value <= rule.Threshold-rule.Hysteresis
+	default:
+		return value < rule.Threshold-rule.Hysteresis
+	}
+}
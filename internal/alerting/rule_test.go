@@ -0,0 +1,102 @@
+package alerting
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseRule(t *testing.T) {
+	expectedTable := []struct {
+		name       string
+		expression string
+		shouldFail bool
+		expected   Rule
+	}{
+		{
+			name:       "Threshold with duration",
+			expression: "TankTemperature < 40 for 10m",
+			expected: Rule{
+				Name:       "TankTemperature < 40 for 10m",
+				Expression: "TankTemperature < 40 for 10m",
+				Field:      "TankTemperature",
+				Kind:       Threshold,
+				Operator:   "<",
+				Threshold:  40,
+				For:        10 * time.Minute,
+			},
+		},
+		{
+			name:       "Named rate rule with unit",
+			expression: "outside-cooling: OutsideTemperature drop > 5°C/h",
+			expected: Rule{
+				Name:       "outside-cooling",
+				Expression: "outside-cooling: OutsideTemperature drop > 5°C/h",
+				Field:      "OutsideTemperature",
+				Kind:       Drop,
+				Operator:   ">",
+				Threshold:  5,
+				RateUnit:   time.Hour,
+			},
+		},
+		{
+			name:       "Negative threshold with measurement type and hysteresis",
+			expression: "SWCTemperature.DrillOutboundTemperature < -2 hysteresis 0.5",
+			expected: Rule{
+				Name:            "SWCTemperature.DrillOutboundTemperature < -2 hysteresis 0.5",
+				Expression:      "SWCTemperature.DrillOutboundTemperature < -2 hysteresis 0.5",
+				MeasurementType: "SWCTemperature",
+				Field:           "DrillOutboundTemperature",
+				Kind:            Threshold,
+				Operator:        "<",
+				Threshold:       -2,
+				Hysteresis:      0.5,
+			},
+		},
+		{
+			name:       "should error out when operator is missing",
+			expression: "TankTemperature 40",
+			shouldFail: true,
+		},
+		{
+			name:       "should error out when rate rule has no unit",
+			expression: "OutsideTemperature drop > 5",
+			shouldFail: true,
+		},
+		{
+			name:       "should error out when duration is invalid",
+			expression: "TankTemperature < 40 for ever",
+			shouldFail: true,
+		},
+	}
+
+	for _, test := range expectedTable {
+		t.Run(test.name, func(t *testing.T) {
+			rule, err := ParseRule(test.expression)
+			if test.shouldFail && err == nil {
+				t.Errorf("Following rule should generate an error: %v", test.expression)
+			} else if !test.shouldFail && !reflect.DeepEqual(rule, test.expected) {
+				t.Errorf("Expected %+v, got %+v (%v)", test.expected, rule, err)
+			}
+		})
+	}
+}
+
+func TestParseRules(t *testing.T) {
+	t.Run("Comments and empty lines are ignored", func(t *testing.T) {
+		rules, err := ParseRules(strings.NewReader("# tank\nTankTemperature < 40\n\nOutsideTemperature > 30\n"))
+
+		if err != nil || len(rules) != 2 {
+			t.Errorf("Expected 2 rules, got %+v (%v)", rules, err)
+		}
+	})
+
+	t.Run("Errors report the line", func(t *testing.T) {
+		_, err := ParseRules(strings.NewReader("TankTemperature < 40\nfoo\n"))
+
+		if err == nil || !strings.HasPrefix(err.Error(), "line 2") {
+			t.Errorf("Expected an error on line 2, got %v", err)
+		}
+	})
+}
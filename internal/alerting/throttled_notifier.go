@@ -0,0 +1,123 @@
+package alerting
+
+import (
+	"log"
+	"sync"
+	"time"
+)
+
+// ThrottledNotifier limits notifications to one per rule and device every MinInterval. Alerts arriving
+// in between are grouped: only the latest one is delivered by DeliverDue once the interval has elapsed,
+// with the number of grouped notifications in Alert.Repeats. A state change is never lost: when the
+// latest alert is in the state delivered last, the latest alert of the other state is delivered before it.
+type ThrottledNotifier struct {
+	Notifier    Notifier
+	MinInterval time.Duration
+
+	mutex  sync.Mutex
+	groups map[groupKey]*group
+}
+
+type groupKey struct {
+	rule   string
+	device string
+}
+
+type group struct {
+	lastSent  time.Time
+	lastState string
+	pending   []Alert // alerts of alternating states, the latest last
+}
+
+// NewThrottledNotifier creates a new ThrottledNotifier
+func NewThrottledNotifier(notifier Notifier, minInterval time.Duration) *ThrottledNotifier {
+	throttled := ThrottledNotifier{
+		Notifier:    notifier,
+		MinInterval: minInterval,
+		groups:      make(map[groupKey]*group),
+	}
+
+	return &throttled
+}
+
+// Notify satisfies the Notifier interface. Alerts held back are delivered by DeliverDue or DeliverAll.
+func (tn *ThrottledNotifier) Notify(alert Alert) error {
+	tn.mutex.Lock()
+
+	key := groupKey{rule: alert.Rule, device: alert.Device}
+	g, found := tn.groups[key]
+	if !found {
+		g = &group{}
+		tn.groups[key] = g
+	}
+
+	if time.Since(g.lastSent) >= tn.MinInterval && len(g.pending) == 0 {
+		g.lastSent = time.Now()
+		g.lastState = alert.State
+		tn.mutex.Unlock()
+		return tn.Notifier.Notify(alert)
+	}
+
+	g.pending = append(g.pending, alert)
+	tn.mutex.Unlock()
+
+	return nil
+}
+
+// DeliverDue delivers the grouped alerts whose interval has elapsed. Delivery errors are logged.
+func (tn *ThrottledNotifier) DeliverDue() {
+	tn.deliver(false)
+}
+
+// DeliverAll delivers every grouped alert, e.g. before the notifier is dropped. Delivery errors are logged.
+func (tn *ThrottledNotifier) DeliverAll() {
+	tn.deliver(true)
+}
+
+// Close delivers every grouped alert, then closes the notifier if it implements io.Closer
+func (tn *ThrottledNotifier) Close() error {
+	tn.DeliverAll()
+	return closeNotifier(tn.Notifier)
+}
+
+func (tn *ThrottledNotifier) deliver(all bool) {
+	var alerts []Alert
+
+	tn.mutex.Lock()
+	for _, g := range tn.groups {
+		if len(g.pending) == 0 || (!all && time.Since(g.lastSent) < tn.MinInterval) {
+			continue
+		}
+		alerts = append(alerts, g.fold()...)
+		g.pending = nil
+		g.lastSent = time.Now()
+		g.lastState = alerts[len(alerts)-1].State
+	}
+	tn.mutex.Unlock()
+
+	for _, alert := range alerts {
+		err := tn.Notifier.Notify(alert)
+		if err != nil {
+			log.Printf("Failed to notify alert %s: %v", alert.Rule, err)
+		}
+	}
+}
+
+// fold returns the alerts to deliver out of the pending ones
+func (g *group) fold() []Alert {
+	latest := g.pending[len(g.pending)-1]
+	if latest.State != g.lastState || len(g.pending) == 1 {
+		latest.Repeats += len(g.pending) - 1
+		return []Alert{latest}
+	}
+
+	// the state changed and came back in between: deliver the change too
+	var change Alert
+	for _, alert := range g.pending {
+		if alert.State != latest.State {
+			change = alert
+		}
+	}
+	latest.Repeats += len(g.pending) - 2
+	return []Alert{change, latest}
+}
@@ -0,0 +1,60 @@
+package alerting
+
+import (
+	"testing"
+	"time"
+)
+
+func TestThrottledNotifier(t *testing.T) {
+	t.Run("First alert is delivered right away", func(t *testing.T) {
+		notifier := mockNotifier{}
+		throttled := NewThrottledNotifier(&notifier, time.Hour)
+
+		throttled.Notify(Alert{Rule: "tank", State: Firing})
+
+		if len(notifier.alerts) != 1 {
+			t.Errorf("Expected 1 alert, got %+v", notifier.alerts)
+		}
+	})
+
+	t.Run("Repeated alerts are grouped until the interval elapses", func(t *testing.T) {
+		notifier := mockNotifier{}
+		throttled := NewThrottledNotifier(&notifier, 50*time.Millisecond)
+
+		throttled.Notify(Alert{Rule: "tank", State: Firing})
+		throttled.Notify(Alert{Rule: "tank", State: Resolved})
+		throttled.Notify(Alert{Rule: "tank", State: Firing})
+		throttled.Notify(Alert{Rule: "tank", State: Resolved, Value: 39})
+		throttled.Notify(Alert{Rule: "outside", State: Firing})
+
+		throttled.DeliverDue()
+		if len(notifier.alerts) != 2 {
+			t.Fatalf("Expected 2 alerts before the interval, got %+v", notifier.alerts)
+		}
+
+		time.Sleep(60 * time.Millisecond)
+		throttled.DeliverDue()
+
+		if len(notifier.alerts) != 3 {
+			t.Fatalf("Expected 3 alerts after the interval, got %+v", notifier.alerts)
+		}
+		if alert := notifier.alerts[2]; alert.State != Resolved || alert.Value != 39 || alert.Repeats != 2 {
+			t.Errorf("Expected the latest alert with 2 repeats, got %+v", alert)
+		}
+	})
+
+	t.Run("A resolution is not folded into a later firing", func(t *testing.T) {
+		notifier := mockNotifier{}
+		throttled := NewThrottledNotifier(&notifier, time.Hour)
+
+		throttled.Notify(Alert{Rule: "tank", State: Firing})
+		throttled.Notify(Alert{Rule: "tank", State: Resolved, Value: 35})
+		throttled.Notify(Alert{Rule: "tank", State: Firing, Value: 39})
+		throttled.DeliverAll()
+
+		assertStates(t, []string{Firing, Resolved, Firing}, &notifier)
+		if notifier.alerts[1].Value != 35 || notifier.alerts[2].Value != 39 {
+			t.Errorf("Expected the resolution then the latest firing, got %+v", notifier.alerts)
+		}
+	})
+}
@@ -0,0 +1,135 @@
+package batcher
+
+import (
+	"sync"
+	"time"
+)
+
+// WriteFunc writes a batch of items, returning the items to keep for the next attempt, e.g. when
+// the destination cannot be reached
+type WriteFunc func(items []interface{}) (kept []interface{}, err error)
+
+// Batcher queues the items of a sink and writes them from a background goroutine, once BatchSize items
+// are pending and every FlushInterval, so that the last items are written even when no more arrive and
+// a slow destination does not block the collector. Items which could not be written are retried with
+// the next flush, the oldest ones being dropped above MaxPending.
+type Batcher struct {
+	BatchSize     int
+	FlushInterval time.Duration // 0 to write every item right away
+	MaxPending    int
+
+	write      WriteFunc
+	mutex      sync.Mutex
+	pending    []interface{}
+	err        error // outcome of the last write
+	flushMutex sync.Mutex
+	startOnce  sync.Once
+	closeOnce  sync.Once
+	wake       chan struct{}
+	stop       chan struct{}
+	done       chan struct{}
+}
+
+// NewBatcher creates a new Batcher writing with the given function
+func NewBatcher(batchSize int, flushInterval time.Duration, maxPending int, write WriteFunc) *Batcher {
+	batcher := Batcher{
+		BatchSize:     batchSize,
+		FlushInterval: flushInterval,
+		MaxPending:    maxPending,
+		write:         write,
+		wake:          make(chan struct{}, 1),
+		stop:          make(chan struct{}),
+		done:          make(chan struct{}),
+	}
+
+	return &batcher
+}
+
+// Add queues items, starting the background writes with the first ones. It returns the error of the
+// last write, so that a failing destination is reported to the caller.
+func (b *Batcher) Add(items ...interface{}) error {
+	b.startOnce.Do(func() { go b.run() })
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	b.pending = b.trim(append(b.pending, items...))
+	if len(b.pending) >= b.BatchSize || b.FlushInterval <= 0 {
+		select {
+		case b.wake <- struct{}{}:
+		default:
+		}
+	}
+
+	return b.err
+}
+
+// Pending returns the number of items waiting to be written
+func (b *Batcher) Pending() int {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	return len(b.pending)
+}
+
+// Flush writes the pending items right away
+func (b *Batcher) Flush() error {
+	b.flushMutex.Lock()
+	defer b.flushMutex.Unlock()
+
+	b.mutex.Lock()
+	items := b.pending
+	b.pending = nil
+	b.mutex.Unlock()
+
+	if len(items) == 0 {
+		return nil
+	}
+	kept, err := b.write(items)
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.pending = b.trim(append(kept, b.pending...))
+	b.err = err
+	return err
+}
+
+// Close stops the background writes, then writes the pending items
+func (b *Batcher) Close() error {
+	b.closeOnce.Do(func() {
+		close(b.stop)
+		b.startOnce.Do(func() { close(b.done) })
+	})
+	<-b.done
+
+	return b.Flush()
+}
+
+func (b *Batcher) run() {
+	defer close(b.done)
+
+	var tick <-chan time.Time
+	if b.FlushInterval > 0 {
+		ticker := time.NewTicker(b.FlushInterval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	for {
+		select {
+		case <-b.wake:
+		case <-tick:
+		case <-b.stop:
+			return
+		}
+		b.Flush()
+	}
+}
+
+// trim drops the oldest items above MaxPending
+func (b *Batcher) trim(items []interface{}) []interface{} {
+	if b.MaxPending > 0 && len(items) > b.MaxPending {
+		return items[len(items)-b.MaxPending:]
+	}
+	return items
+}
@@ -0,0 +1,123 @@
+package batcher
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+type recorder struct {
+	mutex   sync.Mutex
+	batches chan []interface{}
+	err     error
+}
+
+func newRecorder(err error) *recorder {
+	return &recorder{batches: make(chan []interface{}, 10), err: err}
+}
+
+func (r *recorder) write(items []interface{}) ([]interface{}, error) {
+	r.batches <- items
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if r.err != nil {
+		return items, r.err
+	}
+	return nil, nil
+}
+
+func (r *recorder) fail(err error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.err = err
+}
+
+func receive(t *testing.T, batches chan []interface{}) []interface{} {
+	t.Helper()
+	select {
+	case batch := <-batches:
+		return batch
+	case <-time.After(time.Second):
+		t.Fatal("Expected a write")
+	}
+	return nil
+}
+
+func TestBatcher(t *testing.T) {
+	t.Run("Items are written once the batch is full", func(t *testing.T) {
+		recorder := newRecorder(nil)
+		batcher := NewBatcher(2, time.Hour, 10, recorder.write)
+		defer batcher.Close()
+
+		batcher.Add(1)
+		select {
+		case batch := <-recorder.batches:
+			t.Fatalf("No write was expected before the batch is full, got %v", batch)
+		case <-time.After(20 * time.Millisecond):
+		}
+		batcher.Add(2)
+
+		if batch := receive(t, recorder.batches); fmt.Sprint(batch) != "[1 2]" {
+			t.Errorf("Expected [1 2], got %v", batch)
+		}
+	})
+
+	t.Run("The last items are written once the flush interval elapsed", func(t *testing.T) {
+		recorder := newRecorder(nil)
+		batcher := NewBatcher(100, 20*time.Millisecond, 1000, recorder.write)
+		defer batcher.Close()
+
+		batcher.Add(1)
+
+		if batch := receive(t, recorder.batches); fmt.Sprint(batch) != "[1]" {
+			t.Errorf("Expected [1], got %v", batch)
+		}
+	})
+
+	t.Run("Failed items are retried and the error reported", func(t *testing.T) {
+		recorder := newRecorder(errors.New("unreachable"))
+		batcher := NewBatcher(100, time.Hour, 10, recorder.write)
+		defer batcher.Close()
+
+		batcher.Add(1)
+		batcher.Flush()
+		err := batcher.Add(2)
+		if err == nil || err.Error() != "unreachable" {
+			t.Errorf("Expected the last write error, got %v", err)
+		}
+
+		recorder.fail(nil)
+		batcher.Flush()
+		receive(t, recorder.batches)
+		if batch := receive(t, recorder.batches); fmt.Sprint(batch) != "[1 2]" {
+			t.Errorf("Expected the failed item to be retried, got %v", batch)
+		}
+	})
+
+	t.Run("The oldest items are dropped above the limit", func(t *testing.T) {
+		recorder := newRecorder(nil)
+		batcher := NewBatcher(100, time.Hour, 2, recorder.write)
+
+		batcher.Add(1, 2, 3)
+		batcher.Close()
+
+		if batch := receive(t, recorder.batches); fmt.Sprint(batch) != "[2 3]" {
+			t.Errorf("Expected [2 3], got %v", batch)
+		}
+	})
+
+	t.Run("Close writes the pending items", func(t *testing.T) {
+		recorder := newRecorder(nil)
+		batcher := NewBatcher(100, time.Hour, 10, recorder.write)
+
+		batcher.Add(1)
+		err := batcher.Close()
+
+		if err != nil || batcher.Pending() != 0 {
+			t.Errorf("Expected the items to be written, got %v with %d pending", err, batcher.Pending())
+		}
+	})
+}
@@ -0,0 +1,299 @@
+package configfile
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// Config describes the sources, processors, sinks and servers of pacmon.
+// Optional sections are enabled by being present, even when empty.
+type Config struct {
+	Sources    Sources    `json:"sources"`
+	Processors Processors `json:"processors"`
+	Sinks      Sinks      `json:"sinks"`
+	Servers    Servers    `json:"servers"`
+}
+
+// Sources describes where the readings come from
+type Sources struct {
+	SWC        SWCSource         `json:"swc"`
+	PowerMeter *PowerMeterSource `json:"powerMeter"`
+}
+
+// SWCSource describes the session with the heat pump
+type SWCSource struct {
+	URL             string   `json:"url"`
+	Device          string   `json:"device"`
+	PollingInterval Duration `json:"pollingInterval"` // defaults to 60s
+}
+
+// PowerMeterSource describes the HTTP JSON power meter enabling the COP estimation
+type PowerMeterSource struct {
+	URL         string   `json:"url"`
+	Power       string   `json:"power"`
+	Energy      string   `json:"energy"`
+	EnergyScale *float64 `json:"energyScale"`
+}
+
+// Processors describes how readings are enriched, summarized or thinned out
+type Processors struct {
+	Aggregate *Aggregate `json:"aggregate"`
+	Derived   *Derived   `json:"derived"`
+	COP       *COP       `json:"cop"`
+	Cycles    *Cycles    `json:"cycles"`
+	Faults    *Faults    `json:"faults"`
+	Alerting  *Alerting  `json:"alerting"`
+	Deadband  *Deadband  `json:"deadband"`
+	Quality   *Quality   `json:"quality"`
+}
+
+// Aggregate summarizes the temperatures over a window
+type Aggregate struct {
+	Window Duration `json:"window"`
+}
+
+// Derived computes temperature spreads, lift and thermal power, the flows being in l/h
+type Derived struct {
+	HeatingFlow float64 `json:"heatingFlow"`
+	DrillFlow   float64 `json:"drillFlow"`
+}
+
+// COP tunes the coefficient of performance computed out of the power meter
+type COP struct {
+	Window Duration `json:"window"` // defaults to 24h
+}
+
+// Cycles tracks the compressor cycles and defrosts
+type Cycles struct {
+	MaxStartsPerHour float64 `json:"maxStartsPerHour"`
+}
+
+// Faults collects the fault and shutdown history
+type Faults struct {
+	StateFile string `json:"stateFile"`
+}
+
+// Alerting evaluates the rules of a file and delivers the alerts to the notifiers
+type Alerting struct {
+	Rules           string    `json:"rules"`
+	Notifiers       []string  `json:"notifiers"` // defaults to sink
+	WebhookSecret   string    `json:"webhookSecret"`
+	WebhookTemplate string    `json:"webhookTemplate"`
+	Interval        *Duration `json:"interval"` // defaults to 15m
+}
+
+// Deadband only forwards measurements whose fields moved beyond their deadband
+type Deadband struct {
+	Default   float64            `json:"default"`
+	Fields    map[string]float64 `json:"fields"`
+	Heartbeat *Duration          `json:"heartbeat"` // defaults to 10m
+}
+
+// Quality flags implausible, stuck and spiking sensor values
+type Quality struct {
+	StuckAfter *Duration        `json:"stuckAfter"` // defaults to 6h
+	Ranges     map[string]Range `json:"ranges"`
+}
+
+// Range is the plausible range of a field
+type Range struct {
+	Min float64 `json:"min"`
+	Max float64 `json:"max"`
+}
+
+// Sinks describes where the measurements are written to
+type Sinks struct {
+	Kafka       *KafkaSink       `json:"kafka"`
+	MQTT        *URLSink         `json:"mqtt"`
+	Influx      *URLSink         `json:"influx"`
+	Postgres    *URLSink         `json:"postgres"`
+	RemoteWrite *RemoteWriteSink `json:"remoteWrite"`
+	OTLP        *OTLPSink        `json:"otlp"`
+	File        *FileSink        `json:"file"`
+	Parquet     *ParquetSink     `json:"parquet"`
+	SQLite      *SQLiteSink      `json:"sqlite"`
+}
+
+// KafkaSink describes the Kafka broker
+type KafkaSink struct {
+	URL   string `json:"url"`
+	Topic string `json:"topic"` // defaults to SWCTemperature
+}
+
+// URLSink describes a sink configured by its URL, see the corresponding flag for its parameters
+type URLSink struct {
+	URL string `json:"url"`
+}
+
+// RemoteWriteSink describes the Prometheus remote-write endpoint
+type RemoteWriteSink struct {
+	URL    string            `json:"url"`
+	Labels map[string]string `json:"labels"`
+	Token  string            `json:"token"`
+}
+
+// OTLPSink describes the OpenTelemetry collector
+type OTLPSink struct {
+	Endpoint string `json:"endpoint"`
+	Site     string `json:"site"`
+}
+
+// FileSink describes the local files
+type FileSink struct {
+	Path           string    `json:"path"`
+	RotateSize     *int      `json:"rotateSize"`     // MB, defaults to 100
+	RotateInterval *Duration `json:"rotateInterval"` // defaults to 24h
+	Compression    string    `json:"compression"`    // defaults to gzip
+	MaxFiles       *int      `json:"maxFiles"`       // defaults to 30
+	Retention      Duration  `json:"retention"`
+	Sync           string    `json:"sync"` // defaults to interval
+}
+
+// ParquetSink describes the Parquet archive
+type ParquetSink struct {
+	Root           string   `json:"root"`
+	RotateInterval Duration `json:"rotateInterval"` // defaults to 1h
+	RowGroupSize   int      `json:"rowGroupSize"`
+}
+
+// SQLiteSink describes the embedded SQLite database
+type SQLiteSink struct {
+	Path               string    `json:"path"`
+	Retention          Duration  `json:"retention"`
+	DownsampleAfter    *Duration `json:"downsampleAfter"`    // defaults to 7d
+	DownsampleInterval Duration  `json:"downsampleInterval"` // defaults to 5m
+}
+
+// Servers describes the HTTP and gRPC servers
+type Servers struct {
+	HTTP         *HTTPServer `json:"http"`
+	GRPC         *GRPCServer `json:"grpc"`
+	StreamBuffer int         `json:"streamBuffer"` // defaults to 1000
+}
+
+// HTTPServer describes the HTTP server, which always serves /metrics, /healthz and /readyz
+type HTTPServer struct {
+	Addr       string  `json:"addr"`
+	Dashboard  bool    `json:"dashboard"`
+	Stream     bool    `json:"stream"`
+	ControlAPI bool    `json:"controlAPI"`
+	Health     *Health `json:"health"`
+}
+
+// Health tunes the thresholds of the /healthz and /readyz probes
+type Health struct {
+	StaleAfter        Duration  `json:"staleAfter"`        // defaults to three polling intervals
+	DisconnectedAfter *Duration `json:"disconnectedAfter"` // defaults to 5m
+	SinkFailingAfter  *Duration `json:"sinkFailingAfter"`  // defaults to 1m
+}
+
+// GRPCServer describes the gRPC server
+type GRPCServer struct {
+	Addr string `json:"addr"`
+}
+
+// Duration is a time.Duration written as a string, e.g. "90s", "5m" or "7d"
+type Duration time.Duration
+
+// ParseDuration parses a duration, days being supported on top of the time.ParseDuration units
+func ParseDuration(text string) (Duration, error) {
+	if days := strings.TrimSuffix(text, "d"); days != text {
+		count, err := strconv.ParseFloat(days, 64)
+		if err == nil {
+			return Duration(count * float64(24*time.Hour)), nil
+		}
+	}
+
+	duration, err := time.ParseDuration(text)
+	if err != nil {
+		return 0, fmt.Errorf("invalid duration %q, expected e.g. \"90s\", \"5m\", \"6h\" or \"7d\"", text)
+	}
+	return Duration(duration), nil
+}
+
+// UnmarshalText satisfies the encoding.TextUnmarshaler interface
+func (d *Duration) UnmarshalText(text []byte) error {
+	duration, err := ParseDuration(string(text))
+	if err != nil {
+		return err
+	}
+	*d = duration
+	return nil
+}
+
+// MarshalText satisfies the encoding.TextMarshaler interface
+func (d Duration) MarshalText() ([]byte, error) {
+	return []byte(time.Duration(d).String()), nil
+}
+
+// Load reads, interpolates, decodes and validates a YAML, TOML or JSON configuration file,
+// the format being chosen out of its extension
+func Load(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	config, err := Parse(data, filepath.Ext(path))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return config, nil
+}
+
+// Parse decodes, interpolates and validates a configuration of the given format: .yaml, .yml, .toml or .json.
+// Every problem found is reported, one per line.
+func Parse(data []byte, format string) (*Config, error) {
+	var document interface{}
+	var err error
+	switch strings.ToLower(format) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &document)
+	case ".toml":
+		var table map[string]interface{}
+		err = toml.Unmarshal(data, &table)
+		document = table
+	case ".json":
+		err = json.Unmarshal(data, &document)
+	default:
+		return nil, fmt.Errorf("unknown configuration format %q, expected .yaml, .yml, .toml or .json", format)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if document == nil {
+		document = map[string]interface{}{}
+	}
+
+	document, errs := interpolateDocument(document)
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+	if errs := checkSchema(document); len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+
+	// the document matches the schema, so it is decoded once for all formats
+	normalized, err := json.Marshal(document)
+	if err != nil {
+		return nil, err
+	}
+	var config Config
+	if err := json.Unmarshal(normalized, &config); err != nil {
+		return nil, err
+	}
+
+	if errs := config.Validate(); len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+	return &config, nil
+}
@@ -0,0 +1,171 @@
+package configfile
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+const yamlConfig = `
+sources:
+  swc:
+    url: ws://192.168.0.29:8214/
+    pollingInterval: 30s
+processors:
+  deadband:
+    default: 0.2
+    fields:
+      OutsideTemperature: 0.5
+    heartbeat: 0s
+sinks:
+  influx:
+    url: "http://influxdb:8086?org=home&bucket=pac&token=${PACMON_TOKEN}"
+  sqlite:
+    path: /data/pacmon.db
+    retention: 30d
+servers:
+  http:
+    addr: :9100
+    controlAPI: true
+`
+
+const tomlConfig = `
+[sources.swc]
+url = "ws://192.168.0.29:8214/"
+pollingInterval = "30s"
+
+[processors.deadband]
+default = 0.2
+heartbeat = "0s"
+fields = { OutsideTemperature = 0.5 }
+
+[sinks.influx]
+url = "http://influxdb:8086?org=home&bucket=pac&token=${PACMON_TOKEN}"
+
+[sinks.sqlite]
+path = "/data/pacmon.db"
+retention = "30d"
+
+[servers.http]
+addr = ":9100"
+controlAPI = true
+`
+
+const jsonConfig = `{
+  "sources": {"swc": {"url": "ws://192.168.0.29:8214/", "pollingInterval": "30s"}},
+  "processors": {"deadband": {"default": 0.2, "fields": {"OutsideTemperature": 0.5}, "heartbeat": "0s"}},
+  "sinks": {
+    "influx": {"url": "http://influxdb:8086?org=home&bucket=pac&token=${PACMON_TOKEN}"},
+    "sqlite": {"path": "/data/pacmon.db", "retention": "30d"}
+  },
+  "servers": {"http": {"addr": ":9100", "controlAPI": true}}
+}`
+
+func TestLoad(t *testing.T) {
+	t.Setenv("PACMON_TOKEN", "abc")
+
+	heartbeat := Duration(0)
+	expected := &Config{
+		Sources: Sources{SWC: SWCSource{URL: "ws://192.168.0.29:8214/", PollingInterval: Duration(30 * time.Second)}},
+		Processors: Processors{Deadband: &Deadband{
+			Default:   0.2,
+			Fields:    map[string]float64{"OutsideTemperature": 0.5},
+			Heartbeat: &heartbeat,
+		}},
+		Sinks: Sinks{
+			Influx: &URLSink{URL: "http://influxdb:8086?org=home&bucket=pac&token=abc"},
+			SQLite: &SQLiteSink{Path: "/data/pacmon.db", Retention: Duration(30 * 24 * time.Hour)},
+		},
+		Servers: Servers{HTTP: &HTTPServer{Addr: ":9100", ControlAPI: true}},
+	}
+
+	for name, content := range map[string]string{"pacmon.yaml": yamlConfig, "pacmon.yml": yamlConfig, "pacmon.toml": tomlConfig, "pacmon.json": jsonConfig} {
+		t.Run("The same configuration can be written as "+name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), name)
+			if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+				t.Fatal(err)
+			}
+
+			config, err := Load(path)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(config, expected) {
+				t.Errorf("Expected %+v, got %+v", expected, config)
+			}
+		})
+	}
+
+	t.Run("Errors are prefixed with the file", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "pacmon.yaml")
+		os.WriteFile(path, []byte("sources:\n  swc:\n    url: ws://pac\n"), 0600)
+
+		_, err := Load(path)
+		if err == nil || err.Error() != path+": sinks: at least one sink, or the http or grpc server, is required" {
+			t.Errorf("Unexpected error %v", err)
+		}
+	})
+
+	t.Run("Unknown formats are rejected", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "pacmon.ini")
+		os.WriteFile(path, []byte("[sources]"), 0600)
+
+		_, err := Load(path)
+		if err == nil || !strings.Contains(err.Error(), `unknown configuration format ".ini"`) {
+			t.Errorf("Unexpected error %v", err)
+		}
+	})
+}
+
+func TestValidate(t *testing.T) {
+	t.Run("Every inconsistent value is reported", func(t *testing.T) {
+		document := `
+sources:
+  swc:
+    url: http://192.168.0.29
+    pollingInterval: 500ms
+processors:
+  cop: {}
+  alerting: {}
+  quality:
+    ranges:
+      TankTemperature: {min: 95, max: 0}
+sinks:
+  file:
+    path: /data/pacmon.jsonl
+    compression: brotli
+servers:
+  http: {}
+`
+		_, err := Parse([]byte(document), ".yaml")
+		if err == nil {
+			t.Fatal("Expected an error")
+		}
+
+		expected := []string{
+			"sources.swc.url: must be a WebSocket URL, e.g. ws://192.168.0.29:8214/",
+			"sources.swc.pollingInterval: must be at least 1s",
+			"processors.cop: requires sources.powerMeter",
+			"processors.alerting.rules: is required",
+			"processors.quality.ranges.TankTemperature: min must be lower than max",
+			`sinks.file.compression: unknown compression "brotli", expected none, gzip or zstd`,
+			"servers.http.addr: is required",
+		}
+		if err.Error() != strings.Join(expected, "\n") {
+			t.Errorf("Expected errors\n%s\ngot\n%s", strings.Join(expected, "\n"), err)
+		}
+	})
+
+	t.Run("Empty sections enable a feature with its defaults", func(t *testing.T) {
+		config, err := Parse([]byte("sources:\n  swc:\n    url: ws://pac\nprocessors:\n  cycles: {}\nservers:\n  grpc:\n    addr: :9090\n"), ".yaml")
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if config.Processors.Cycles == nil || config.Processors.Faults != nil {
+			t.Errorf("Expected only the cycles to be enabled, got %+v", config.Processors)
+		}
+	})
+}
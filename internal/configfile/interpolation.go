@@ -0,0 +1,166 @@
+package configfile
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// interpolateDocument replaces the references within the string values of a decoded
+// document, so that neither comments nor the inserted values can alter its structure:
+//
+//	${NAME}           value of the NAME environment variable, which must be set
+//	${NAME:-default}  value of NAME, or default when NAME is unset or empty
+//	${file:path}      content of a file, e.g. a Docker secret, without its trailing newlines
+//	$$                a literal $
+//
+// A value made of references only is converted when its field expects a number or a boolean.
+func interpolateDocument(document interface{}) (interface{}, []error) {
+	var errs []error
+	document = interpolateValue(document, reflect.TypeOf(Config{}), "", &errs)
+	return document, errs
+}
+
+// interpolateValue interpolates a value, target being nil when the value is not part of the schema
+func interpolateValue(value interface{}, target reflect.Type, path string, errs *[]error) interface{} {
+	if target != nil && target.Kind() == reflect.Ptr {
+		target = target.Elem()
+	}
+
+	switch typed := value.(type) {
+	case string:
+		if !strings.Contains(typed, "$") {
+			return typed
+		}
+		result, err := interpolate(typed)
+		if err != nil {
+			*errs = append(*errs, &FieldError{Field: path, Message: err.Error()})
+			return typed
+		}
+		return convert(result, target)
+	case map[string]interface{}:
+		var fields map[string]reflect.Type
+		if target != nil && target.Kind() == reflect.Struct {
+			fields, _ = fieldTypes(target)
+		}
+		for _, key := range sortedKeys(typed) {
+			var itemType reflect.Type
+			switch {
+			case fields != nil:
+				itemType = fields[key]
+			case target != nil && target.Kind() == reflect.Map:
+				itemType = target.Elem()
+			}
+			typed[key] = interpolateValue(typed[key], itemType, join(path, key), errs)
+		}
+	case []interface{}:
+		var itemType reflect.Type
+		if target != nil && target.Kind() == reflect.Slice {
+			itemType = target.Elem()
+		}
+		for index, item := range typed {
+			typed[index] = interpolateValue(item, itemType, fmt.Sprintf("%s[%d]", path, index), errs)
+		}
+	}
+	return value
+}
+
+// convert returns an interpolated value as the number or boolean expected by its field, if it is one
+func convert(text string, target reflect.Type) interface{} {
+	if target == nil || target == durationType {
+		return text
+	}
+	switch target.Kind() {
+	case reflect.Int, reflect.Float64:
+		if number, err := strconv.ParseFloat(strings.TrimSpace(text), 64); err == nil {
+			return number
+		}
+	case reflect.Bool:
+		if boolean, err := strconv.ParseBool(strings.TrimSpace(text)); err == nil {
+			return boolean
+		}
+	}
+	return text
+}
+
+// interpolate replaces the references of a string value
+func interpolate(text string) (string, error) {
+	var result strings.Builder
+
+	for index := 0; index < len(text); index++ {
+		char := text[index]
+		if char != '$' || index+1 >= len(text) {
+			result.WriteByte(char)
+			continue
+		}
+
+		switch text[index+1] {
+		case '$':
+			result.WriteByte('$')
+			index++
+		case '{':
+			end := strings.IndexAny(text[index+2:], "}\n")
+			if end < 0 || text[index+2+end] != '}' {
+				return "", fmt.Errorf("unterminated reference %q", firstLine(text[index:]))
+			}
+			value, err := resolve(text[index+2 : index+2+end])
+			if err != nil {
+				return "", err
+			}
+			result.WriteString(value)
+			index += end + 2
+		default:
+			result.WriteByte(char)
+		}
+	}
+
+	return result.String(), nil
+}
+
+// resolve returns the value of the content of a reference
+func resolve(reference string) (string, error) {
+	if path, found := strings.CutPrefix(reference, "file:"); found {
+		if path == "" {
+			return "", fmt.Errorf("missing path in ${%s}", reference)
+		}
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return "", fmt.Errorf("cannot read the secret of ${%s}: %v", reference, err)
+		}
+		return strings.TrimRight(string(content), "\r\n"), nil
+	}
+
+	name, fallback, hasFallback := strings.Cut(reference, ":-")
+	if !validName(name) {
+		return "", fmt.Errorf("invalid reference ${%s}, expected ${NAME}, ${NAME:-default} or ${file:path}", reference)
+	}
+
+	value, set := os.LookupEnv(name)
+	switch {
+	case hasFallback && value == "":
+		return fallback, nil
+	case !set:
+		return "", fmt.Errorf("environment variable %s is not set, use ${%s:-default} to make it optional", name, name)
+	}
+	return value, nil
+}
+
+func validName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for index, char := range name {
+		letter := char == '_' || (char >= 'A' && char <= 'Z') || (char >= 'a' && char <= 'z')
+		if !letter && (index == 0 || char < '0' || char > '9') {
+			return false
+		}
+	}
+	return true
+}
+
+func firstLine(text string) string {
+	line, _, _ := strings.Cut(text, "\n")
+	return line
+}
@@ -0,0 +1,107 @@
+package configfile
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestInterpolate(t *testing.T) {
+	t.Setenv("PACMON_HOST", "192.168.0.29")
+	t.Setenv("PACMON_EMPTY", "")
+
+	secret := filepath.Join(t.TempDir(), "influx_token")
+	if err := os.WriteFile(secret, []byte("s3cr3t\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		text     string
+		expected string
+		err      string
+	}{
+		{name: "environment variables are replaced", text: "ws://${PACMON_HOST}:8214/", expected: "ws://192.168.0.29:8214/"},
+		{name: "defaults apply to unset variables", text: "${PACMON_UNSET:-SWCTemperature}", expected: "SWCTemperature"},
+		{name: "defaults apply to empty variables", text: "${PACMON_EMPTY:-SWCTemperature}", expected: "SWCTemperature"},
+		{name: "set variables win over defaults", text: "${PACMON_HOST:-localhost}", expected: "192.168.0.29"},
+		{name: "secrets are read from files", text: "token=${file:" + secret + "}", expected: "token=s3cr3t"},
+		{name: "$$ escapes a $", text: "pa$$word, $HOME", expected: "pa$word, $HOME"},
+		{name: "unset variables are reported", text: "ws://${PACMON_UNSET}/", err: "environment variable PACMON_UNSET is not set"},
+		{name: "missing secrets are reported", text: "${file:/nonexistent/secret}", err: "cannot read the secret of ${file:/nonexistent/secret}"},
+		{name: "unterminated references are reported", text: "ws://${PACMON_HOST\n:8214", err: "unterminated reference \"${PACMON_HOST\""},
+		{name: "invalid names are reported", text: "${1HOST}", err: "invalid reference ${1HOST}"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			result, err := interpolate(test.text)
+			if test.err != "" {
+				if err == nil || !strings.HasPrefix(err.Error(), test.err) {
+					t.Errorf("Expected error %q, got %v", test.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if result != test.expected {
+				t.Errorf("Expected %q, got %q", test.expected, result)
+			}
+		})
+	}
+}
+
+func TestParseInterpolation(t *testing.T) {
+	t.Setenv("PACMON_TOKEN", "a\"b\nc: d")
+	t.Setenv("PACMON_MAX_FILES", "7")
+	t.Setenv("PACMON_CONTROL", "true")
+
+	config, err := Parse([]byte(`
+# ${PACMON_UNSET} in a comment is left alone
+sources:
+  swc:
+    url: ws://${PACMON_HOST:-192.168.0.29}:8214/
+sinks:
+  influx:
+    url: "http://influxdb:8086?token=${PACMON_TOKEN}"
+  file:
+    path: /data
+    maxFiles: ${PACMON_MAX_FILES}
+servers:
+  http:
+    addr: :9100
+    controlAPI: ${PACMON_CONTROL}
+`), ".yaml")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	t.Run("values are inserted without changing the document", func(t *testing.T) {
+		expected := "http://influxdb:8086?token=a\"b\nc: d"
+		if config.Sinks.Influx.URL != expected {
+			t.Errorf("Expected %q, got %q", expected, config.Sinks.Influx.URL)
+		}
+		if config.Sources.SWC.URL != "ws://192.168.0.29:8214/" {
+			t.Errorf("Unexpected URL %q", config.Sources.SWC.URL)
+		}
+	})
+
+	t.Run("numbers and booleans are converted", func(t *testing.T) {
+		if config.Sinks.File.MaxFiles == nil || *config.Sinks.File.MaxFiles != 7 {
+			t.Errorf("Expected 7 files, got %v", config.Sinks.File.MaxFiles)
+		}
+		if !config.Servers.HTTP.ControlAPI {
+			t.Error("Expected the control API")
+		}
+	})
+
+	t.Run("errors are reported with their field", func(t *testing.T) {
+		_, err := Parse([]byte("sources:\n  swc:\n    url: ws://${PACMON_UNSET}/\n"), ".yaml")
+		expected := "sources.swc.url: environment variable PACMON_UNSET is not set"
+		if err == nil || !strings.HasPrefix(err.Error(), expected) {
+			t.Errorf("Expected error %q, got %v", expected, err)
+		}
+	})
+}
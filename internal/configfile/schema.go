@@ -0,0 +1,208 @@
+package configfile
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// FieldError reports a problem with a field, e.g. sinks.influx.url
+type FieldError struct {
+	Field   string
+	Message string
+}
+
+func (e *FieldError) Error() string {
+	return e.Field + ": " + e.Message
+}
+
+var durationType = reflect.TypeOf(Duration(0))
+
+// checkSchema reports the unknown fields and the values of the wrong type, whatever the format
+func checkSchema(document interface{}) []error {
+	var errs []error
+	checkValue(document, reflect.TypeOf(Config{}), "", &errs)
+	return errs
+}
+
+func checkValue(value interface{}, target reflect.Type, path string, errs *[]error) {
+	fail := func(format string, args ...interface{}) {
+		field := path
+		if field == "" {
+			field = "(root)"
+		}
+		*errs = append(*errs, &FieldError{Field: field, Message: fmt.Sprintf(format, args...)})
+	}
+
+	if target.Kind() == reflect.Ptr {
+		target = target.Elem()
+	}
+	if value == nil {
+		return
+	}
+
+	if target == durationType {
+		text, ok := value.(string)
+		if !ok {
+			fail("expected a duration string, e.g. \"90s\", got %s", describe(value))
+			return
+		}
+		if _, err := ParseDuration(text); err != nil {
+			fail("%v", err)
+		}
+		return
+	}
+
+	switch target.Kind() {
+	case reflect.Struct:
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			fail("expected a section, got %s", describe(value))
+			return
+		}
+		checkFields(object, target, path, errs)
+	case reflect.Map:
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			fail("expected a map, got %s", describe(value))
+			return
+		}
+		for _, name := range sortedKeys(object) {
+			checkValue(object[name], target.Elem(), join(path, name), errs)
+		}
+	case reflect.Slice:
+		items, ok := value.([]interface{})
+		if !ok {
+			fail("expected a list, got %s", describe(value))
+			return
+		}
+		for index, item := range items {
+			checkValue(item, target.Elem(), fmt.Sprintf("%s[%d]", path, index), errs)
+		}
+	case reflect.String:
+		if _, ok := value.(string); !ok {
+			fail("expected a string, got %s", describe(value))
+		}
+	case reflect.Bool:
+		if _, ok := value.(bool); !ok {
+			fail("expected true or false, got %s", describe(value))
+		}
+	case reflect.Int:
+		number, ok := toFloat(value)
+		if !ok || number != math.Trunc(number) {
+			fail("expected an integer, got %s", describe(value))
+		}
+	case reflect.Float64:
+		if _, ok := toFloat(value); !ok {
+			fail("expected a number, got %s", describe(value))
+		}
+	}
+}
+
+// checkFields reports the unknown fields of a section, suggesting the closest known one
+func checkFields(object map[string]interface{}, target reflect.Type, path string, errs *[]error) {
+	fields, names := fieldTypes(target)
+	for _, key := range sortedKeys(object) {
+		fieldType, found := fields[key]
+		if !found {
+			message := "unknown field"
+			if suggestion := closest(key, names); suggestion != "" {
+				message += fmt.Sprintf(", did you mean %q?", suggestion)
+			} else {
+				message += fmt.Sprintf(", expected one of %s", strings.Join(names, ", "))
+			}
+			*errs = append(*errs, &FieldError{Field: join(path, key), Message: message})
+			continue
+		}
+		checkValue(object[key], fieldType, join(path, key), errs)
+	}
+}
+
+// fieldTypes returns the types of the fields of a section by name, and the names in order
+func fieldTypes(target reflect.Type) (map[string]reflect.Type, []string) {
+	fields := make(map[string]reflect.Type, target.NumField())
+	var names []string
+	for index := 0; index < target.NumField(); index++ {
+		field := target.Field(index)
+		name := strings.Split(field.Tag.Get("json"), ",")[0]
+		fields[name] = field.Type
+		names = append(names, name)
+	}
+	return fields, names
+}
+
+// closest returns the known name within two edits of key, if any
+func closest(key string, names []string) string {
+	best, bestDistance := "", 3
+	for _, name := range names {
+		distance := levenshtein(strings.ToLower(key), strings.ToLower(name))
+		if distance < bestDistance {
+			best, bestDistance = name, distance
+		}
+	}
+	return best
+}
+
+func levenshtein(a string, b string) int {
+	previous := make([]int, len(b)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		current := make([]int, len(b)+1)
+		current[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous = current
+	}
+	return previous[len(b)]
+}
+
+func toFloat(value interface{}) (float64, bool) {
+	switch number := value.(type) {
+	case int:
+		return float64(number), true
+	case int64:
+		return float64(number), true
+	case uint64:
+		return float64(number), true
+	case float64:
+		return number, true
+	}
+	return 0, false
+}
+
+func describe(value interface{}) string {
+	switch typed := value.(type) {
+	case string:
+		return fmt.Sprintf("%q", typed)
+	case map[string]interface{}, map[interface{}]interface{}:
+		return "a section"
+	case []interface{}:
+		return "a list"
+	}
+	return fmt.Sprintf("%v", value)
+}
+
+func sortedKeys(object map[string]interface{}) []string {
+	keys := make([]string, 0, len(object))
+	for key := range object {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func join(path string, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
@@ -0,0 +1,66 @@
+package configfile
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCheckSchema(t *testing.T) {
+	tests := []struct {
+		name     string
+		format   string
+		document string
+		expected []string
+	}{
+		{
+			name:     "unknown fields suggest the closest known one",
+			format:   ".yaml",
+			document: "sources:\n  swc:\n    ulr: ws://pac\nsinks:\n  influx:\n    url: http://influxdb:8086\n",
+			expected: []string{`sources.swc.ulr: unknown field, did you mean "url"?`},
+		},
+		{
+			name:     "unknown fields list the known ones when nothing is close",
+			format:   ".json",
+			document: `{"sources": {"swc": {"url": "ws://pac"}}, "outputs": {}}`,
+			expected: []string{"outputs: unknown field, expected one of sources, processors, sinks, servers"},
+		},
+		{
+			name:     "values of the wrong type are reported with their path",
+			format:   ".toml",
+			document: "[sources.swc]\nurl = 42\npollingInterval = 60\n[servers.http]\naddr = \":9100\"\ndashboard = \"yes\"\n",
+			expected: []string{
+				`servers.http.dashboard: expected true or false, got "yes"`,
+				"sources.swc.pollingInterval: expected a duration string, e.g. \"90s\", got 60",
+				"sources.swc.url: expected a string, got 42",
+			},
+		},
+		{
+			name:     "invalid durations are reported",
+			format:   ".yaml",
+			document: "sources:\n  swc:\n    url: ws://pac\n    pollingInterval: 5 minutes\nservers:\n  grpc:\n    addr: :9090\n",
+			expected: []string{`sources.swc.pollingInterval: invalid duration "5 minutes", expected e.g. "90s", "5m", "6h" or "7d"`},
+		},
+		{
+			name:     "map and list items are checked",
+			format:   ".yaml",
+			document: "sources:\n  swc:\n    url: ws://pac\nprocessors:\n  alerting:\n    rules: rules.txt\n    notifiers: [log, 3]\n  quality:\n    ranges:\n      TankTemperature: {min: 0, maks: 95}\n",
+			expected: []string{
+				"processors.alerting.notifiers[1]: expected a string, got 3",
+				`processors.quality.ranges.TankTemperature.maks: unknown field, did you mean "max"?`,
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := Parse([]byte(test.document), test.format)
+			if err == nil {
+				t.Fatal("Expected an error")
+			}
+			lines := strings.Split(err.Error(), "\n")
+			if strings.Join(lines, "|") != strings.Join(test.expected, "|") {
+				t.Errorf("Expected errors\n%s\ngot\n%s", strings.Join(test.expected, "\n"), err)
+			}
+		})
+	}
+}
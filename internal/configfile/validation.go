@@ -0,0 +1,173 @@
+package configfile
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Validate reports every inconsistent value of the configuration
+func (c *Config) Validate() []error {
+	var errs []error
+	fail := func(field string, format string, args ...interface{}) {
+		errs = append(errs, &FieldError{Field: field, Message: fmt.Sprintf(format, args...)})
+	}
+	required := func(field string, value string) {
+		if strings.TrimSpace(value) == "" {
+			fail(field, "is required")
+		}
+	}
+	notNegative := func(field string, value Duration) {
+		if value < 0 {
+			fail(field, "cannot be negative")
+		}
+	}
+
+	swc := c.Sources.SWC
+	required("sources.swc.url", swc.URL)
+	if swc.URL != "" && !strings.HasPrefix(swc.URL, "ws:") {
+		fail("sources.swc.url", "must be a WebSocket URL, e.g. ws://192.168.0.29:8214/")
+	}
+	if swc.PollingInterval != 0 && time.Duration(swc.PollingInterval) < time.Second {
+		fail("sources.swc.pollingInterval", "must be at least 1s")
+	}
+	if meter := c.Sources.PowerMeter; meter != nil {
+		required("sources.powerMeter.url", meter.URL)
+		if meter.EnergyScale != nil && *meter.EnergyScale <= 0 {
+			fail("sources.powerMeter.energyScale", "must be positive")
+		}
+	}
+
+	processors := c.Processors
+	if processors.Aggregate != nil && processors.Aggregate.Window <= 0 {
+		fail("processors.aggregate.window", "must be positive")
+	}
+	if derived := processors.Derived; derived != nil && (derived.HeatingFlow < 0 || derived.DrillFlow < 0) {
+		fail("processors.derived", "flows cannot be negative")
+	}
+	if cop := processors.COP; cop != nil {
+		if c.Sources.PowerMeter == nil {
+			fail("processors.cop", "requires sources.powerMeter")
+		}
+		notNegative("processors.cop.window", cop.Window)
+	}
+	if cycles := processors.Cycles; cycles != nil && cycles.MaxStartsPerHour < 0 {
+		fail("processors.cycles.maxStartsPerHour", "cannot be negative")
+	}
+	if alerting := processors.Alerting; alerting != nil {
+		required("processors.alerting.rules", alerting.Rules)
+		if alerting.Interval != nil {
+			notNegative("processors.alerting.interval", *alerting.Interval)
+		}
+	}
+	if deadband := processors.Deadband; deadband != nil {
+		if deadband.Default < 0 {
+			fail("processors.deadband.default", "cannot be negative")
+		}
+		for name, value := range deadband.Fields {
+			if value < 0 {
+				fail("processors.deadband.fields."+name, "cannot be negative")
+			}
+		}
+		if deadband.Heartbeat != nil {
+			notNegative("processors.deadband.heartbeat", *deadband.Heartbeat)
+		}
+	}
+	if quality := processors.Quality; quality != nil {
+		if quality.StuckAfter != nil {
+			notNegative("processors.quality.stuckAfter", *quality.StuckAfter)
+		}
+		for name, bounds := range quality.Ranges {
+			if bounds.Min >= bounds.Max {
+				fail("processors.quality.ranges."+name, "min must be lower than max")
+			}
+		}
+	}
+
+	sinks := c.Sinks
+	if sinks.Kafka != nil {
+		required("sinks.kafka.url", sinks.Kafka.URL)
+	}
+	if sinks.MQTT != nil {
+		required("sinks.mqtt.url", sinks.MQTT.URL)
+	}
+	if sinks.Influx != nil {
+		required("sinks.influx.url", sinks.Influx.URL)
+	}
+	if sinks.Postgres != nil {
+		required("sinks.postgres.url", sinks.Postgres.URL)
+	}
+	if sinks.RemoteWrite != nil {
+		required("sinks.remoteWrite.url", sinks.RemoteWrite.URL)
+	}
+	if sinks.OTLP != nil {
+		required("sinks.otlp.endpoint", sinks.OTLP.Endpoint)
+	}
+	if file := sinks.File; file != nil {
+		required("sinks.file.path", file.Path)
+		if (file.RotateSize != nil && *file.RotateSize < 0) || (file.MaxFiles != nil && *file.MaxFiles < 0) {
+			fail("sinks.file", "rotateSize and maxFiles cannot be negative")
+		}
+		if file.RotateInterval != nil {
+			notNegative("sinks.file.rotateInterval", *file.RotateInterval)
+		}
+		notNegative("sinks.file.retention", file.Retention)
+		switch file.Compression {
+		case "", "none", "gzip", "zstd":
+		default:
+			fail("sinks.file.compression", "unknown compression %q, expected none, gzip or zstd", file.Compression)
+		}
+		switch file.Sync {
+		case "", "always", "interval", "never":
+		default:
+			fail("sinks.file.sync", "unknown sync policy %q, expected always, interval or never", file.Sync)
+		}
+	}
+	if parquet := sinks.Parquet; parquet != nil {
+		required("sinks.parquet.root", parquet.Root)
+		notNegative("sinks.parquet.rotateInterval", parquet.RotateInterval)
+		if parquet.RowGroupSize < 0 {
+			fail("sinks.parquet.rowGroupSize", "cannot be negative")
+		}
+	}
+	if sqlite := sinks.SQLite; sqlite != nil {
+		required("sinks.sqlite.path", sqlite.Path)
+		notNegative("sinks.sqlite.retention", sqlite.Retention)
+		if sqlite.DownsampleAfter != nil {
+			notNegative("sinks.sqlite.downsampleAfter", *sqlite.DownsampleAfter)
+		}
+		notNegative("sinks.sqlite.downsampleInterval", sqlite.DownsampleInterval)
+	}
+
+	servers := c.Servers
+	if http := servers.HTTP; http != nil {
+		required("servers.http.addr", http.Addr)
+		if health := http.Health; health != nil {
+			notNegative("servers.http.health.staleAfter", health.StaleAfter)
+			if health.DisconnectedAfter != nil {
+				notNegative("servers.http.health.disconnectedAfter", *health.DisconnectedAfter)
+			}
+			if health.SinkFailingAfter != nil {
+				notNegative("servers.http.health.sinkFailingAfter", *health.SinkFailingAfter)
+			}
+		}
+	}
+	if servers.GRPC != nil {
+		required("servers.grpc.addr", servers.GRPC.Addr)
+	}
+	if servers.StreamBuffer < 0 {
+		fail("servers.streamBuffer", "cannot be negative")
+	}
+
+	if !c.hasOutput() {
+		fail("sinks", "at least one sink, or the http or grpc server, is required")
+	}
+
+	return errs
+}
+
+func (c *Config) hasOutput() bool {
+	s := c.Sinks
+	return s.Kafka != nil || s.MQTT != nil || s.Influx != nil || s.Postgres != nil || s.RemoteWrite != nil || s.OTLP != nil ||
+		s.File != nil || s.Parquet != nil || s.SQLite != nil || c.Servers.HTTP != nil || c.Servers.GRPC != nil
+}
@@ -0,0 +1,114 @@
+package controlapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/renajohn/pac_collector/internal/multisink"
+	"github.com/renajohn/pac_collector/internal/streamhub"
+	"github.com/renajohn/pac_collector/internal/swcsource"
+)
+
+// Controller is the runtime interface of the source, implemented by swcsource.SWCSource
+type Controller interface {
+	Status() swcsource.SessionStatus
+	Navigation() []swcsource.NavigationItem
+	Refresh() error
+	SetPollInterval(interval time.Duration) error
+	Pause()
+	Resume()
+}
+
+// Status is the state of the session and of the sinks
+type Status struct {
+	Session swcsource.SessionStatus `json:"session"`
+	Sinks   []multisink.SinkHealth  `json:"sinks"`
+}
+
+// pollingInterval is the body changing the polling interval
+type pollingInterval struct {
+	Seconds int64 `json:"seconds"`
+}
+
+// API reports the state of pacmon and controls the collection over HTTP:
+//
+//	GET  /api/status            session state and sink health
+//	GET  /api/navigation        menu of the SWC
+//	GET  /api/latest            latest readings, filtered by the type and device parameters
+//	POST /api/refresh           polls the SWC immediately
+//	PUT  /api/polling-interval  changes the polling interval, e.g. {"seconds": 30}
+//	POST /api/pause             stops polling
+//	POST /api/resume            restarts polling
+type API struct {
+	source Controller
+	hub    *streamhub.Hub
+	sinks  *multisink.MultiSink
+}
+
+// NewAPI creates a new API
+func NewAPI(source Controller, hub *streamhub.Hub, sinks *multisink.MultiSink) *API {
+	return &API{source: source, hub: hub, sinks: sinks}
+}
+
+// ServeHTTP dispatches the requests under /api/
+func (a *API) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	route := r.Method + " " + strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/api"), "/")
+
+	switch route {
+	case "GET /status":
+		writeJSON(w, http.StatusOK, a.status())
+	case "GET /navigation":
+		navigation := a.source.Navigation()
+		if navigation == nil {
+			navigation = []swcsource.NavigationItem{}
+		}
+		writeJSON(w, http.StatusOK, navigation)
+	case "GET /latest":
+		writeJSON(w, http.StatusOK, a.hub.Latest(streamhub.ParseFilter(r.URL.Query())))
+	case "POST /refresh":
+		if err := a.source.Refresh(); err != nil {
+			writeError(w, http.StatusConflict, err)
+			return
+		}
+		writeJSON(w, http.StatusAccepted, a.source.Status())
+	case "PUT /polling-interval":
+		var body pollingInterval
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		if err := a.source.SetPollInterval(time.Duration(body.Seconds) * time.Second); err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, a.source.Status())
+	case "POST /pause":
+		a.source.Pause()
+		writeJSON(w, http.StatusOK, a.source.Status())
+	case "POST /resume":
+		a.source.Resume()
+		writeJSON(w, http.StatusOK, a.source.Status())
+	default:
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "no such endpoint: " + route})
+	}
+}
+
+func (a *API) status() Status {
+	status := Status{Session: a.source.Status(), Sinks: []multisink.SinkHealth{}}
+	if a.sinks != nil {
+		status.Sinks = a.sinks.Health()
+	}
+	return status
+}
+
+func writeJSON(w http.ResponseWriter, code int, value interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(value)
+}
+
+func writeError(w http.ResponseWriter, code int, err error) {
+	writeJSON(w, code, map[string]string{"error": err.Error()})
+}
@@ -0,0 +1,142 @@
+package controlapi
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/renajohn/pac_collector/api"
+	"github.com/renajohn/pac_collector/internal/mocksink"
+	"github.com/renajohn/pac_collector/internal/multisink"
+	"github.com/renajohn/pac_collector/internal/streamhub"
+	"github.com/renajohn/pac_collector/internal/swcsource"
+)
+
+type fakeController struct {
+	status    swcsource.SessionStatus
+	refreshed bool
+}
+
+func (fc *fakeController) Status() swcsource.SessionStatus {
+	return fc.status
+}
+
+func (fc *fakeController) Navigation() []swcsource.NavigationItem {
+	return []swcsource.NavigationItem{{ID: "0x1", Name: "Informations", Items: []swcsource.NavigationItem{{ID: "0x2", Name: "Températures"}}}}
+}
+
+func (fc *fakeController) Refresh() error {
+	if fc.status.State == swcsource.Connecting || fc.status.State == swcsource.Disconnected {
+		return errors.New("the session is not connected")
+	}
+	fc.refreshed = true
+	return nil
+}
+
+func (fc *fakeController) SetPollInterval(interval time.Duration) error {
+	if interval < swcsource.MinPollInterval {
+		return errors.New("the polling interval must be at least 1s")
+	}
+	fc.status.PollInterval = int64(interval / time.Second)
+	return nil
+}
+
+func (fc *fakeController) Pause() {
+	fc.status.State = swcsource.Paused
+}
+
+func (fc *fakeController) Resume() {
+	fc.status.State = swcsource.Connected
+}
+
+func request(t *testing.T, handler http.Handler, method string, path string, body string) (int, string) {
+	t.Helper()
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(method, path, strings.NewReader(body)))
+	return recorder.Code, strings.TrimSpace(recorder.Body.String())
+}
+
+func TestServeHTTP(t *testing.T) {
+	newAPI := func() (*API, *fakeController) {
+		source := &fakeController{status: swcsource.SessionStatus{State: swcsource.Connected, PollInterval: 60, Restarts: 1}}
+		hub := streamhub.NewHub(10)
+		hub.Process(api.Measurement{MeasurementType: api.SWCTemperature, Device: "swc", Timestamp: 60, Value: []byte(`{"TankTemperature":52.3}`)})
+		hub.Process(api.Measurement{MeasurementType: api.SWCOutputs, Device: "swc", Timestamp: 60, Value: []byte(`{"Compressor":true}`)})
+
+		sinks := multisink.NewMultiSink(&mocksink.MockSink{})
+		sinks.Put(api.Measurement{MeasurementType: api.SWCTemperature})
+
+		return NewAPI(source, hub, sinks), source
+	}
+
+	t.Run("Status reports the session and the sinks", func(t *testing.T) {
+		handler, _ := newAPI()
+		code, body := request(t, handler, "GET", "/api/status", "")
+
+		var status Status
+		json.Unmarshal([]byte(body), &status)
+		if code != 200 || status.Session.State != swcsource.Connected || status.Session.Restarts != 1 || len(status.Sinks) != 1 || !status.Sinks[0].Healthy {
+			t.Errorf("Unexpected status %d %s", code, body)
+		}
+	})
+
+	t.Run("Navigation is returned as a tree", func(t *testing.T) {
+		handler, _ := newAPI()
+		code, body := request(t, handler, "GET", "/api/navigation", "")
+
+		expected := `[{"id":"0x1","name":"Informations","items":[{"id":"0x2","name":"Températures"}]}]`
+		if code != 200 || body != expected {
+			t.Errorf("Expected %s got %d %s", expected, code, body)
+		}
+	})
+
+	t.Run("Latest readings are filtered", func(t *testing.T) {
+		handler, _ := newAPI()
+		code, body := request(t, handler, "GET", "/api/latest?type=SWCTemperature", "")
+
+		expected := `[{"id":1,"type":"SWCTemperature","device":"swc","timestamp":60,"value":{"TankTemperature":52.3}}]`
+		if code != 200 || body != expected {
+			t.Errorf("Expected %s got %d %s", expected, code, body)
+		}
+	})
+
+	t.Run("Collection is controlled", func(t *testing.T) {
+		handler, source := newAPI()
+
+		if code, body := request(t, handler, "POST", "/api/refresh", ""); code != http.StatusAccepted || !source.refreshed {
+			t.Errorf("Expected a refresh, got %d %s", code, body)
+		}
+		if code, body := request(t, handler, "PUT", "/api/polling-interval", `{"seconds":30}`); code != 200 || source.status.PollInterval != 30 {
+			t.Errorf("Expected the polling interval to change, got %d %s", code, body)
+		}
+		if code, body := request(t, handler, "POST", "/api/pause", ""); code != 200 || !strings.Contains(body, `"state":"paused"`) {
+			t.Errorf("Expected the collection to be paused, got %d %s", code, body)
+		}
+		if code, body := request(t, handler, "POST", "/api/resume", ""); code != 200 || !strings.Contains(body, `"state":"connected"`) {
+			t.Errorf("Expected the collection to be resumed, got %d %s", code, body)
+		}
+
+		source.status.State = swcsource.Disconnected
+		if code, body := request(t, handler, "POST", "/api/refresh", ""); code != http.StatusConflict {
+			t.Errorf("Expected a conflict when not connected, got %d %s", code, body)
+		}
+	})
+
+	t.Run("should error out on invalid requests", func(t *testing.T) {
+		handler, _ := newAPI()
+
+		if code, _ := request(t, handler, "PUT", "/api/polling-interval", `{"seconds":0}`); code != http.StatusBadRequest {
+			t.Errorf("Expected a bad request, got %d", code)
+		}
+		if code, _ := request(t, handler, "PUT", "/api/polling-interval", `thirty`); code != http.StatusBadRequest {
+			t.Errorf("Expected a bad request, got %d", code)
+		}
+		if code, _ := request(t, handler, "DELETE", "/api/status", ""); code != http.StatusNotFound {
+			t.Errorf("Expected not found, got %d", code)
+		}
+	})
+}
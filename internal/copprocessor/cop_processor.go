@@ -0,0 +1,157 @@
+package copprocessor
+
+import (
+	"encoding/json"
+	"log"
+	"time"
+
+	"github.com/renajohn/pac_collector/api"
+	"github.com/renajohn/pac_collector/internal/derivedprocessor"
+	"github.com/renajohn/pac_collector/internal/powermetersource"
+	"github.com/renajohn/pac_collector/internal/swcsource"
+)
+
+// COPMeasurement represents the coefficient of performance of the heat pump. Ratios are omitted
+// until electrical energy has been consumed over their period. Energies are in kWh.
+type COPMeasurement struct {
+	Instantaneous           *float64 `json:",omitempty"` // thermal power / electrical power, requires the heating flow rate
+	Rolling                 *float64 `json:",omitempty"` // over the rolling window
+	Daily                   *float64 `json:",omitempty"` // since midnight
+	RollingHeatEnergy       float64
+	RollingElectricalEnergy float64
+	DailyHeatEnergy         float64
+	DailyElectricalEnergy   float64
+}
+
+// COPProcessor combines the heat meter counters of the heat pump with the readings of an electrical
+// power meter, and emits a COP measurement every time one of them is updated.
+type COPProcessor struct {
+	Window   time.Duration  // rolling window, defaults to 24h
+	Location *time.Location // used to find midnight, defaults to local time
+
+	device          string
+	heatEnergy      *float64
+	electricalPower *float64
+	electrical      *float64
+	thermalPower    *float64
+
+	samples    []sample // samples within the rolling window, plus the one right before it
+	dailyStart *sample
+}
+
+type sample struct {
+	timestamp  int64
+	heat       float64
+	electrical float64
+}
+
+// NewCOPProcessor creates a new COPProcessor
+func NewCOPProcessor(window time.Duration) *COPProcessor {
+	if window == 0 {
+		window = 24 * time.Hour
+	}
+
+	processor := COPProcessor{
+		Window:   window,
+		Location: time.Local,
+	}
+
+	return &processor
+}
+
+// Process satisfies the api.Processor interface
+func (cp *COPProcessor) Process(m api.Measurement) []api.Measurement {
+	var err error
+	updated := false
+
+	switch m.MeasurementType {
+	case api.SWCHeatQuantity:
+		var heatQuantity swcsource.SWCHeatQuantity
+		err = json.Unmarshal(m.Value, &heatQuantity)
+		cp.heatEnergy = &heatQuantity.TotalEnergy
+		cp.device = m.Device
+		updated = true
+	case api.ElectricalPower:
+		var powerMeter powermetersource.PowerMeterMeasurement
+		err = json.Unmarshal(m.Value, &powerMeter)
+		cp.electrical = &powerMeter.Energy
+		cp.electricalPower = &powerMeter.Power
+		updated = true
+	case api.SWCDerived:
+		var derived derivedprocessor.DerivedMeasurement
+		err = json.Unmarshal(m.Value, &derived)
+		cp.thermalPower = derived.HeatingThermalPower
+	}
+
+	if err != nil {
+		log.Printf("Failed to decode %s measurement, skipping COP: %v", m.MeasurementType, err)
+		return []api.Measurement{m}
+	}
+	if !updated || cp.heatEnergy == nil || cp.electrical == nil {
+		return []api.Measurement{m}
+	}
+
+	cp.addSample(sample{timestamp: m.Timestamp, heat: *cp.heatEnergy, electrical: *cp.electrical})
+	data, _ := json.Marshal(cp.cop())
+
+	return []api.Measurement{m, {
+		MeasurementType: api.COP,
+		Device:          cp.device,
+		Timestamp:       m.Timestamp,
+		Value:           data,
+	}}
+}
+
+func (cp *COPProcessor) addSample(s sample) {
+	if len(cp.samples) > 0 {
+		last := cp.samples[len(cp.samples)-1]
+		if s.heat < last.heat || s.electrical < last.electrical {
+			// a counter was reset, start over
+			cp.samples = nil
+			cp.dailyStart = nil
+		}
+	}
+
+	cp.samples = append(cp.samples, s)
+	windowStart := s.timestamp - int64(cp.Window/time.Second)
+	for len(cp.samples) > 1 && cp.samples[1].timestamp <= windowStart {
+		cp.samples = cp.samples[1:]
+	}
+
+	if cp.dailyStart == nil || cp.day(cp.dailyStart.timestamp) != cp.day(s.timestamp) {
+		dailyStart := s
+		cp.dailyStart = &dailyStart
+	}
+}
+
+func (cp *COPProcessor) day(timestamp int64) string {
+	return time.Unix(timestamp, 0).In(cp.Location).Format("2006-01-02")
+}
+
+func (cp *COPProcessor) cop() COPMeasurement {
+	first, last := cp.samples[0], cp.samples[len(cp.samples)-1]
+
+	cop := COPMeasurement{
+		RollingHeatEnergy:       last.heat - first.heat,
+		RollingElectricalEnergy: last.electrical - first.electrical,
+		DailyHeatEnergy:         last.heat - cp.dailyStart.heat,
+		DailyElectricalEnergy:   last.electrical - cp.dailyStart.electrical,
+	}
+
+	cop.Rolling = ratio(cop.RollingHeatEnergy, cop.RollingElectricalEnergy)
+	cop.Daily = ratio(cop.DailyHeatEnergy, cop.DailyElectricalEnergy)
+	if cp.thermalPower != nil && cp.electricalPower != nil {
+		cop.Instantaneous = ratio(*cp.thermalPower*1000, *cp.electricalPower)
+	}
+
+	return cop
+}
+
+func ratio(heat float64, electrical float64) *float64 {
+	if electrical <= 0 {
+		return nil
+	}
+
+	value := heat / electrical
+	return &value
+}
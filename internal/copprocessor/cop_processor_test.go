@@ -0,0 +1,122 @@
+package copprocessor
+
+import (
+	"encoding/json"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/renajohn/pac_collector/api"
+	"github.com/renajohn/pac_collector/internal/derivedprocessor"
+	"github.com/renajohn/pac_collector/internal/powermetersource"
+	"github.com/renajohn/pac_collector/internal/swcsource"
+)
+
+func makeMeasurement(measurementType api.MeasurementType, timestamp int64, value interface{}) api.Measurement {
+	data, _ := json.Marshal(value)
+	return api.Measurement{MeasurementType: measurementType, Device: "swc", Timestamp: timestamp, Value: data}
+}
+
+func heat(timestamp int64, total float64) api.Measurement {
+	return makeMeasurement(api.SWCHeatQuantity, timestamp, swcsource.SWCHeatQuantity{TotalEnergy: total})
+}
+
+func electrical(timestamp int64, power float64, energy float64) api.Measurement {
+	return makeMeasurement(api.ElectricalPower, timestamp, powermetersource.PowerMeterMeasurement{Power: power, Energy: energy})
+}
+
+func decodeCOP(t *testing.T, measurements []api.Measurement) COPMeasurement {
+	t.Helper()
+	if len(measurements) != 2 || measurements[1].MeasurementType != api.COP {
+		t.Fatalf("Expected a COP measurement, got %+v", measurements)
+	}
+
+	var cop COPMeasurement
+	json.Unmarshal(measurements[1].Value, &cop)
+	return cop
+}
+
+func assertRatio(t *testing.T, expect float64, got *float64) {
+	t.Helper()
+	if got == nil {
+		t.Errorf("Expected value of %v got nil", expect)
+	} else if math.Abs(expect-*got) > 1e-9 {
+		t.Errorf("Expected value of %v got %v", expect, *got)
+	}
+}
+
+func newProcessor(window time.Duration) *COPProcessor {
+	processor := NewCOPProcessor(window)
+	processor.Location = time.UTC
+	return processor
+}
+
+func TestProcess(t *testing.T) {
+	t.Run("No COP until both counters are known", func(t *testing.T) {
+		processor := newProcessor(time.Hour)
+
+		processed := processor.Process(heat(0, 100))
+
+		if len(processed) != 1 {
+			t.Errorf("Expected no COP, got %+v", processed)
+		}
+	})
+
+	t.Run("Rolling and daily COP are computed out of the counters", func(t *testing.T) {
+		processor := newProcessor(time.Hour)
+
+		processor.Process(heat(0, 100))
+		processor.Process(electrical(0, 0, 10))
+		processor.Process(heat(1800, 104))
+		cop := decodeCOP(t, processor.Process(electrical(1800, 0, 11)))
+
+		assertRatio(t, 4, cop.Rolling)
+		assertRatio(t, 4, cop.Daily)
+		if cop.Instantaneous != nil {
+			t.Errorf("Expected no instantaneous COP without thermal power, got %v", *cop.Instantaneous)
+		}
+	})
+
+	t.Run("Rolling COP only covers the window", func(t *testing.T) {
+		processor := newProcessor(time.Hour)
+
+		processor.Process(heat(0, 100))
+		processor.Process(electrical(0, 0, 10))
+		processor.Process(heat(3600, 104))
+		processor.Process(electrical(3600, 0, 11))
+		processor.Process(heat(7200, 110))
+		cop := decodeCOP(t, processor.Process(electrical(7200, 0, 12)))
+
+		assertRatio(t, 6, cop.Rolling)
+		assertRatio(t, 5, cop.Daily)
+	})
+
+	t.Run("Daily COP restarts at midnight", func(t *testing.T) {
+		processor := newProcessor(48 * time.Hour)
+		midnight := int64(24 * 3600)
+
+		processor.Process(heat(midnight-60, 100))
+		processor.Process(electrical(midnight-60, 0, 10))
+		processor.Process(heat(midnight+60, 103))
+		processor.Process(electrical(midnight+60, 0, 10))
+		processor.Process(heat(midnight+3600, 106))
+		cop := decodeCOP(t, processor.Process(electrical(midnight+3600, 0, 11)))
+
+		assertRatio(t, 3, cop.Daily)
+		assertRatio(t, 6, cop.Rolling)
+	})
+
+	t.Run("Instantaneous COP uses the thermal power", func(t *testing.T) {
+		processor := newProcessor(time.Hour)
+		thermalPower := 6.0
+
+		processor.Process(makeMeasurement(api.SWCDerived, 0, derivedprocessor.DerivedMeasurement{HeatingThermalPower: &thermalPower}))
+		processor.Process(heat(0, 100))
+		cop := decodeCOP(t, processor.Process(electrical(0, 1500, 10)))
+
+		assertRatio(t, 4, cop.Instantaneous)
+		if cop.Rolling != nil {
+			t.Errorf("Expected no rolling COP without consumption, got %v", *cop.Rolling)
+		}
+	})
+}
@@ -0,0 +1,203 @@
+package cycleprocessor
+
+import (
+	"encoding/json"
+	"log"
+	"time"
+
+	"github.com/renajohn/pac_collector/api"
+	"github.com/renajohn/pac_collector/internal/swcsource"
+)
+
+// Cycle events
+const (
+	CompressorStart = "CompressorStart"
+	CompressorStop  = "CompressorStop"
+	DefrostStart    = "DefrostStart"
+	DefrostStop     = "DefrostStop"
+)
+
+// DefaultMaxStartsPerHour is the number of compressor starts per hour above which short-cycling is reported
+const DefaultMaxStartsPerHour = 3
+
+// CycleEvent represents a compressor or defrost transition. Durations are in seconds and only set
+// when the previous transition was observed.
+type CycleEvent struct {
+	Event         string
+	RunDuration   float64 `json:",omitempty"` // on stop events, how long it ran
+	OffDuration   float64 `json:",omitempty"` // on compressor start, how long the compressor rested
+	StartsPerHour float64 // compressor starts over the last hour
+}
+
+// ShortCyclingWarning is raised when the compressor starts too often, and cleared once it calms down
+type ShortCyclingWarning struct {
+	Active        bool
+	StartsPerHour float64
+	Threshold     float64
+}
+
+// CycleProcessor tracks the compressor and defrost transitions out of the SWCOutputs and SWCStatus
+// measurements. It emits CompressorCycle events and ShortCycling warnings, per device.
+type CycleProcessor struct {
+	MaxStartsPerHour float64
+
+	devices map[string]*deviceState
+}
+
+type deviceState struct {
+	known           bool
+	compressor      bool
+	compressorSince int64 // 0 when the transition was not observed
+	starts          []int64
+	defrostOutput   bool
+	defrostStatus   bool
+	statusKnown     bool
+	defrostSince    int64
+	shortCycling    bool
+}
+
+// NewCycleProcessor creates a new CycleProcessor
+func NewCycleProcessor(maxStartsPerHour float64) *CycleProcessor {
+	processor := CycleProcessor{
+		MaxStartsPerHour: maxStartsPerHour,
+		devices:          make(map[string]*deviceState),
+	}
+
+	return &processor
+}
+
+// Process satisfies the api.Processor interface
+func (cp *CycleProcessor) Process(m api.Measurement) []api.Measurement {
+	if m.MeasurementType != api.SWCOutputs && m.MeasurementType != api.SWCStatus {
+		return []api.Measurement{m}
+	}
+
+	state, found := cp.devices[m.Device]
+	if !found {
+		state = &deviceState{}
+		cp.devices[m.Device] = state
+	}
+
+	var events []interface{}
+	var err error
+	if m.MeasurementType == api.SWCOutputs {
+		var outputs swcsource.SWCOutputs
+		err = json.Unmarshal(m.Value, &outputs)
+		if err == nil {
+			events = cp.updateOutputs(state, outputs, m.Timestamp)
+		}
+	} else {
+		var status swcsource.SWCStatus
+		err = json.Unmarshal(m.Value, &status)
+		if err == nil {
+			events = cp.updateStatus(state, status, m.Timestamp)
+		}
+	}
+	if err != nil {
+		log.Printf("Failed to decode %s measurement, skipping cycle analysis: %v", m.MeasurementType, err)
+	}
+
+	processed := []api.Measurement{m}
+	for _, event := range events {
+		processed = append(processed, toMeasurement(m, event))
+	}
+
+	return processed
+}
+
+func (cp *CycleProcessor) updateOutputs(state *deviceState, outputs swcsource.SWCOutputs, timestamp int64) []interface{} {
+	var events []interface{}
+
+	if !state.known {
+		// the first observation gives the state, not a transition
+		state.known = true
+		state.compressor = outputs.Compressor
+		state.defrostOutput = outputs.DefrostValve
+		return nil
+	}
+
+	if outputs.Compressor && !state.compressor {
+		state.starts = append(state.starts, timestamp)
+		event := CycleEvent{Event: CompressorStart, StartsPerHour: state.startsPerHour(timestamp)}
+		if state.compressorSince > 0 {
+			event.OffDuration = float64(timestamp - state.compressorSince)
+		}
+		events = append(events, event)
+		state.compressorSince = timestamp
+	} else if !outputs.Compressor && state.compressor {
+		event := CycleEvent{Event: CompressorStop, StartsPerHour: state.startsPerHour(timestamp)}
+		if state.compressorSince > 0 {
+			event.RunDuration = float64(timestamp - state.compressorSince)
+		}
+		events = append(events, event)
+		state.compressorSince = timestamp
+	}
+	state.compressor = outputs.Compressor
+
+	events = append(events, state.updateDefrost(outputs.DefrostValve, state.defrostStatus, timestamp, state.startsPerHour(timestamp))...)
+
+	startsPerHour := state.startsPerHour(timestamp)
+	if startsPerHour > cp.MaxStartsPerHour != state.shortCycling {
+		state.shortCycling = !state.shortCycling
+		events = append(events, ShortCyclingWarning{Active: state.shortCycling, StartsPerHour: startsPerHour, Threshold: cp.MaxStartsPerHour})
+	}
+
+	return events
+}
+
+func (cp *CycleProcessor) updateStatus(state *deviceState, status swcsource.SWCStatus, timestamp int64) []interface{} {
+	defrosting := status.OperatingState == swcsource.DefrostState
+
+	if !state.statusKnown {
+		state.statusKnown = true
+		state.defrostStatus = defrosting
+		return nil
+	}
+
+	return state.updateDefrost(state.defrostOutput, defrosting, timestamp, state.startsPerHour(timestamp))
+}
+
+// updateDefrost reports a defrost when either the defrost valve is open or the status says so
+func (state *deviceState) updateDefrost(output bool, status bool, timestamp int64, startsPerHour float64) []interface{} {
+	wasDefrosting := state.defrostOutput || state.defrostStatus
+	state.defrostOutput, state.defrostStatus = output, status
+	defrosting := output || status
+
+	if defrosting && !wasDefrosting {
+		state.defrostSince = timestamp
+		return []interface{}{CycleEvent{Event: DefrostStart, StartsPerHour: startsPerHour}}
+	} else if !defrosting && wasDefrosting {
+		event := CycleEvent{Event: DefrostStop, StartsPerHour: startsPerHour}
+		if state.defrostSince > 0 {
+			event.RunDuration = float64(timestamp - state.defrostSince)
+		}
+		return []interface{}{event}
+	}
+
+	return nil
+}
+
+// startsPerHour counts the compressor starts over the last hour, forgetting the older ones
+func (state *deviceState) startsPerHour(timestamp int64) float64 {
+	hourAgo := timestamp - int64(time.Hour/time.Second)
+	for len(state.starts) > 0 && state.starts[0] <= hourAgo {
+		state.starts = state.starts[1:]
+	}
+
+	return float64(len(state.starts))
+}
+
+func toMeasurement(m api.Measurement, event interface{}) api.Measurement {
+	measurementType := api.CompressorCycle
+	if _, ok := event.(ShortCyclingWarning); ok {
+		measurementType = api.ShortCycling
+	}
+
+	data, _ := json.Marshal(event)
+	return api.Measurement{
+		MeasurementType: measurementType,
+		Device:          m.Device,
+		Timestamp:       m.Timestamp,
+		Value:           data,
+	}
+}
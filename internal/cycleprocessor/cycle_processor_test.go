@@ -0,0 +1,130 @@
+package cycleprocessor
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/renajohn/pac_collector/api"
+	"github.com/renajohn/pac_collector/internal/swcsource"
+)
+
+func outputs(timestamp int64, compressor bool, defrost bool) api.Measurement {
+	data, _ := json.Marshal(swcsource.SWCOutputs{Compressor: compressor, DefrostValve: defrost})
+	return api.Measurement{MeasurementType: api.SWCOutputs, Device: "swc", Timestamp: timestamp, Value: data}
+}
+
+func status(timestamp int64, state string) api.Measurement {
+	data, _ := json.Marshal(swcsource.SWCStatus{OperatingState: state})
+	return api.Measurement{MeasurementType: api.SWCStatus, Device: "swc", Timestamp: timestamp, Value: data}
+}
+
+func decodeEvents(t *testing.T, measurements []api.Measurement) []CycleEvent {
+	t.Helper()
+	var events []CycleEvent
+	for _, m := range measurements[1:] {
+		if m.MeasurementType != api.CompressorCycle {
+			continue
+		}
+		var event CycleEvent
+		json.Unmarshal(m.Value, &event)
+		events = append(events, event)
+	}
+	return events
+}
+
+func findWarning(measurements []api.Measurement) *ShortCyclingWarning {
+	for _, m := range measurements {
+		if m.MeasurementType == api.ShortCycling {
+			var warning ShortCyclingWarning
+			json.Unmarshal(m.Value, &warning)
+			return &warning
+		}
+	}
+	return nil
+}
+
+func TestProcess(t *testing.T) {
+	t.Run("First observation is not a transition", func(t *testing.T) {
+		processor := NewCycleProcessor(DefaultMaxStartsPerHour)
+
+		processed := processor.Process(outputs(0, true, false))
+
+		if len(processed) != 1 {
+			t.Errorf("Expected no events, got %+v", processed)
+		}
+	})
+
+	t.Run("Compressor cycles are reported with their durations", func(t *testing.T) {
+		processor := NewCycleProcessor(DefaultMaxStartsPerHour)
+
+		processor.Process(outputs(0, false, false))
+		start := decodeEvents(t, processor.Process(outputs(60, true, false)))
+		stop := decodeEvents(t, processor.Process(outputs(660, false, false)))
+		restart := decodeEvents(t, processor.Process(outputs(960, true, false)))
+
+		expected := []CycleEvent{
+			{Event: CompressorStart, StartsPerHour: 1},
+			{Event: CompressorStop, RunDuration: 600, StartsPerHour: 1},
+			{Event: CompressorStart, OffDuration: 300, StartsPerHour: 2},
+		}
+		got := append(append(start, stop...), restart...)
+		if !reflect.DeepEqual(expected, got) {
+			t.Errorf("Expected events %+v got %+v", expected, got)
+		}
+	})
+
+	t.Run("Short-cycling is raised and cleared", func(t *testing.T) {
+		processor := NewCycleProcessor(2)
+		processor.Process(outputs(0, false, false))
+
+		var warning *ShortCyclingWarning
+		for index := int64(0); index < 3; index++ {
+			if raised := findWarning(processor.Process(outputs(index*600+60, true, false))); raised != nil {
+				warning = raised
+			}
+			processor.Process(outputs(index*600+300, false, false))
+		}
+
+		if warning == nil || !warning.Active || warning.StartsPerHour != 3 {
+			t.Fatalf("Expected an active short-cycling warning, got %+v", warning)
+		}
+
+		warning = findWarning(processor.Process(outputs(7200, false, false)))
+		if warning == nil || warning.Active {
+			t.Errorf("Expected short-cycling warning to be cleared, got %+v", warning)
+		}
+	})
+
+	t.Run("Defrost is reported out of the valve or the status", func(t *testing.T) {
+		processor := NewCycleProcessor(DefaultMaxStartsPerHour)
+
+		processor.Process(outputs(0, true, false))
+		processor.Process(status(0, "Chauffage"))
+		start := decodeEvents(t, processor.Process(status(60, swcsource.DefrostState)))
+		none := decodeEvents(t, processor.Process(outputs(90, true, true)))
+		stillOpen := decodeEvents(t, processor.Process(status(120, "Chauffage")))
+		stop := decodeEvents(t, processor.Process(outputs(240, true, false)))
+
+		if len(start) != 1 || start[0].Event != DefrostStart {
+			t.Errorf("Expected a defrost start, got %+v", start)
+		}
+		if len(none) != 0 || len(stillOpen) != 0 {
+			t.Errorf("Expected no events while defrosting, got %+v %+v", none, stillOpen)
+		}
+		if len(stop) != 1 || stop[0].Event != DefrostStop || stop[0].RunDuration != 180 {
+			t.Errorf("Expected a defrost stop after 180s, got %+v", stop)
+		}
+	})
+
+	t.Run("Other measurement types are forwarded", func(t *testing.T) {
+		processor := NewCycleProcessor(DefaultMaxStartsPerHour)
+		other := api.Measurement{MeasurementType: api.SWCTemperature, Value: []byte("{}")}
+
+		processed := processor.Process(other)
+
+		if !reflect.DeepEqual([]api.Measurement{other}, processed) {
+			t.Errorf("Expected measurement to be forwarded, got %+v", processed)
+		}
+	})
+}
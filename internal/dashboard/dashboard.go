@@ -0,0 +1,167 @@
+package dashboard
+
+import (
+	"embed"
+	"encoding/json"
+	"io/fs"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/renajohn/pac_collector/api"
+	"github.com/renajohn/pac_collector/internal/fields"
+	"github.com/renajohn/pac_collector/internal/streamhub"
+	"github.com/renajohn/pac_collector/internal/swcsource"
+)
+
+// DefaultHistory is the period of the charts
+const DefaultHistory = 6 * time.Hour
+
+// charted are the measurement types whose numeric fields are kept for the charts
+var charted = map[api.MeasurementType]bool{
+	api.SWCTemperature: true,
+	api.SWCDerived:     true,
+	api.COP:            true,
+}
+
+//go:embed static
+var static embed.FS
+
+// Source reports the state of the session, implemented by swcsource.SWCSource
+type Source interface {
+	Status() swcsource.SessionStatus
+}
+
+// Dashboard serves a web page showing the latest measurements, the session status and charts of
+// the recent temperatures, updated live over Server-Sent Events. The latest measurements and the events
+// come from the hub, the dashboard observing the measurements as a pass-through api.Processor for the charts.
+type Dashboard struct {
+	History time.Duration
+
+	hub     *streamhub.Hub
+	source  Source
+	started time.Time
+	mutex   sync.Mutex
+	history map[seriesKey][]Point
+	files   http.Handler
+}
+
+type seriesKey struct {
+	measurementType api.MeasurementType
+	device          string
+	field           string
+}
+
+// Point is a value of a charted field
+type Point struct {
+	Timestamp int64   `json:"t"`
+	Value     float64 `json:"v"`
+}
+
+// Series is the history of a charted field
+type Series struct {
+	Type   api.MeasurementType `json:"type"`
+	Device string              `json:"device"`
+	Field  string              `json:"field"`
+	Unit   string              `json:"unit"`
+	Points []Point             `json:"points"`
+}
+
+// Status describes the session as reported by the source, the same one as /api/status and /healthz
+type Status struct {
+	swcsource.SessionStatus
+	Started int64 `json:"started"` // start of the dashboard
+}
+
+// State is the snapshot the page is initialized with
+type State struct {
+	Status         Status            `json:"status"`
+	Latest         []streamhub.Event `json:"latest"`
+	History        []Series          `json:"history"`
+	HistorySeconds int64             `json:"historySeconds"`
+}
+
+// NewDashboard creates a new Dashboard streaming the events of hub, which must observe the measurements too,
+// and reporting the session status of source
+func NewDashboard(hub *streamhub.Hub, source Source) *Dashboard {
+	files, _ := fs.Sub(static, "static")
+
+	dashboard := Dashboard{
+		History: DefaultHistory,
+		hub:     hub,
+		source:  source,
+		started: time.Now(),
+		history: make(map[seriesKey][]Point),
+		files:   http.FileServer(http.FS(files)),
+	}
+
+	return &dashboard
+}
+
+// Process satisfies the api.Processor interface, recording the charted fields
+func (d *Dashboard) Process(m api.Measurement) []api.Measurement {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	if charted[m.MeasurementType] {
+		values, _ := fields.Numeric(m)
+		oldest := m.Timestamp - int64(d.History/time.Second)
+		for field, value := range values {
+			key := seriesKey{m.MeasurementType, m.Device, field}
+			points := append(d.history[key], Point{m.Timestamp, value})
+			for len(points) > 0 && points[0].Timestamp < oldest {
+				points = points[1:]
+			}
+			d.history[key] = points
+		}
+	}
+
+	return []api.Measurement{m}
+}
+
+// ServeHTTP serves the page, the state snapshot on /dashboard/state and the live events of the hub on /dashboard/events
+func (d *Dashboard) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.URL.Path {
+	case "/dashboard/state":
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(d.State())
+	case "/dashboard/events":
+		d.hub.ServeHTTP(w, r)
+	default:
+		d.files.ServeHTTP(w, r)
+	}
+}
+
+// State returns the current status, the latest measurement of every type and device and the charted history
+func (d *Dashboard) State() State {
+	latest := d.hub.Latest(streamhub.Filter{})
+	status := Status{SessionStatus: d.source.Status(), Started: d.started.Unix()}
+
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	state := State{Status: status, Latest: latest, History: []Series{}, HistorySeconds: int64(d.History / time.Second)}
+
+	for key, points := range d.history {
+		state.History = append(state.History, Series{
+			Type:   key.measurementType,
+			Device: key.device,
+			Field:  key.field,
+			Unit:   fields.Unit(key.field),
+			Points: append([]Point(nil), points...),
+		})
+	}
+	sort.Slice(state.History, func(i, j int) bool {
+		a, b := state.History[i], state.History[j]
+		if a.Type != b.Type {
+			return a.Type < b.Type
+		}
+		if a.Device != b.Device {
+			return a.Device < b.Device
+		}
+		return a.Field < b.Field
+	})
+
+	return state
+}
@@ -0,0 +1,145 @@
+package dashboard
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/renajohn/pac_collector/api"
+	"github.com/renajohn/pac_collector/internal/streamhub"
+	"github.com/renajohn/pac_collector/internal/swcsource"
+)
+
+type fakeSource struct {
+	status swcsource.SessionStatus
+}
+
+func (fs *fakeSource) Status() swcsource.SessionStatus {
+	return fs.status
+}
+
+func temperature(timestamp int64, value string) api.Measurement {
+	return api.Measurement{
+		MeasurementType: api.SWCTemperature,
+		Device:          "swc",
+		Timestamp:       timestamp,
+		Value:           []byte(`{"TankTemperature":` + value + `}`),
+	}
+}
+
+// process observes the measurement as the hub then the dashboard, as chained by pacmon
+func process(hub *streamhub.Hub, dashboard *Dashboard, m api.Measurement) []api.Measurement {
+	hub.Process(m)
+	return dashboard.Process(m)
+}
+
+func TestProcess(t *testing.T) {
+	t.Run("Latest measurements and recent history are kept", func(t *testing.T) {
+		hub := streamhub.NewHub(10)
+		dashboard := NewDashboard(hub, &fakeSource{swcsource.SessionStatus{State: swcsource.Connected, LastPoll: 7200}})
+		dashboard.History = time.Hour
+
+		for _, m := range []api.Measurement{
+			temperature(0, "50"),
+			temperature(3600, "51"),
+			{MeasurementType: api.SWCOutputs, Device: "swc", Timestamp: 7200, Value: []byte(`{"Compressor":true}`)},
+			temperature(7200, "52"),
+		} {
+			if result := process(hub, dashboard, m); len(result) != 1 {
+				t.Errorf("Expected the measurement to be passed through, got %v", result)
+			}
+		}
+
+		state := dashboard.State()
+		if len(state.Latest) != 2 || state.Latest[0].Type != api.SWCOutputs || string(state.Latest[1].Value) != `{"TankTemperature":52}` {
+			t.Errorf("Unexpected latest measurements %v", state.Latest)
+		}
+		expected := []Series{{
+			Type:   api.SWCTemperature,
+			Device: "swc",
+			Field:  "TankTemperature",
+			Unit:   "°C",
+			Points: []Point{{3600, 51}, {7200, 52}},
+		}}
+		if !reflect.DeepEqual(expected, state.History) {
+			t.Errorf("Expected %v got %v", expected, state.History)
+		}
+		if state.Status.State != "connected" || state.Status.LastPoll != 7200 || state.HistorySeconds != 3600 {
+			t.Errorf("Unexpected state %+v", state)
+		}
+	})
+
+	t.Run("Session status is the one of the source", func(t *testing.T) {
+		source := &fakeSource{swcsource.SessionStatus{State: swcsource.Connected, LastPoll: 800}}
+		hub := streamhub.NewHub(10)
+		dashboard := NewDashboard(hub, source)
+		process(hub, dashboard, temperature(800, "50"))
+
+		source.status = swcsource.SessionStatus{State: swcsource.Disconnected, LastPoll: 800, Restarts: 2, LastError: "connection refused"}
+		status := dashboard.State().Status
+		if status.State != swcsource.Disconnected || status.Restarts != 2 || status.LastError != "connection refused" || status.Started == 0 {
+			t.Errorf("Expected the session to be disconnected, got %+v", status)
+		}
+	})
+}
+
+func TestServeHTTP(t *testing.T) {
+	hub := streamhub.NewHub(10)
+	dashboard := NewDashboard(hub, &fakeSource{swcsource.SessionStatus{State: swcsource.Connected}})
+	server := httptest.NewServer(dashboard)
+	defer server.Close()
+
+	t.Run("Page is served", func(t *testing.T) {
+		response, err := server.Client().Get(server.URL + "/")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer response.Body.Close()
+
+		body, _ := bufio.NewReader(response.Body).Peek(15)
+		if response.StatusCode != 200 || string(body) != "<!DOCTYPE html>" {
+			t.Errorf("Expected the page, got %d %s", response.StatusCode, body)
+		}
+	})
+
+	t.Run("State is served as JSON", func(t *testing.T) {
+		process(hub, dashboard, temperature(60, "50"))
+
+		response, err := server.Client().Get(server.URL + "/dashboard/state")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer response.Body.Close()
+
+		var state State
+		if err := json.NewDecoder(response.Body).Decode(&state); err != nil {
+			t.Fatal(err)
+		}
+		if len(state.Latest) != 1 || len(state.History) != 1 {
+			t.Errorf("Unexpected state %+v", state)
+		}
+	})
+
+	t.Run("Measurements of the hub are pushed as Server-Sent Events", func(t *testing.T) {
+		response, err := server.Client().Get(server.URL + "/dashboard/events")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer response.Body.Close()
+
+		// the subscription is registered before the headers are flushed
+		process(hub, dashboard, temperature(120, "51"))
+
+		reader := bufio.NewReader(response.Body)
+		id, _ := reader.ReadString('\n')
+		event, _ := reader.ReadString('\n')
+		data, _ := reader.ReadString('\n')
+		if id != "id: 2\n" || event != "event: measurement\n" || !strings.Contains(data, `"value":{"TankTemperature":51}`) {
+			t.Errorf("Unexpected event %q %q %q", id, event, data)
+		}
+	})
+}
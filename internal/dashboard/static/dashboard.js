@@ -0,0 +1,175 @@
+"use strict";
+
+const colors = ["#1f77b4", "#ff7f0e", "#2ca02c", "#d62728", "#9467bd", "#8c564b", "#e377c2", "#7f7f7f", "#bcbd22", "#17becf"];
+const charted = ["SWCTemperature", "SWCDerived", "COP"];
+
+const state = { latest: {}, history: {}, status: null, historySeconds: 6 * 3600 };
+
+function key(...parts) {
+  return parts.join("/");
+}
+
+function formatValue(value) {
+  if (typeof value === "number") {
+    return Number.isInteger(value) ? value.toString() : value.toFixed(1);
+  }
+  return String(value);
+}
+
+function renderStatus() {
+  const element = document.getElementById("status");
+  const status = state.status;
+  if (!status) {
+    return;
+  }
+  element.className = "status " + status.state;
+  element.textContent = status.state + (status.restarts > 0 ? " (" + status.restarts + " restarts)" : "");
+}
+
+function renderLatest() {
+  const container = document.getElementById("latest");
+  container.replaceChildren();
+  for (const event of Object.values(state.latest).sort((a, b) => key(a.type, a.device).localeCompare(key(b.type, b.device)))) {
+    const card = document.createElement("div");
+    card.className = "card";
+    const title = document.createElement("h3");
+    title.textContent = event.type + " · " + event.device;
+    card.appendChild(title);
+
+    const table = document.createElement("table");
+    const values = typeof event.value === "object" && event.value !== null ? event.value : { value: event.value };
+    for (const [name, value] of Object.entries(values)) {
+      const row = table.insertRow();
+      row.insertCell().textContent = name;
+      const cell = row.insertCell();
+      cell.className = "value";
+      cell.textContent = formatValue(value);
+    }
+    card.appendChild(table);
+
+    const updated = document.createElement("div");
+    updated.className = "updated";
+    updated.textContent = new Date(event.timestamp * 1000).toLocaleString();
+    card.appendChild(updated);
+    container.appendChild(card);
+  }
+}
+
+function renderCharts() {
+  const container = document.getElementById("charts");
+  container.replaceChildren();
+
+  const groups = {};
+  for (const series of Object.values(state.history)) {
+    const group = key(series.type, series.device, series.unit);
+    (groups[group] = groups[group] || []).push(series);
+  }
+
+  for (const group of Object.keys(groups).sort()) {
+    const series = groups[group].sort((a, b) => a.field.localeCompare(b.field));
+    const points = series.flatMap((s) => s.points);
+    if (points.length === 0) {
+      continue;
+    }
+    const minT = Math.min(...points.map((p) => p.t));
+    const maxT = Math.max(...points.map((p) => p.t), minT + 1);
+    let minV = Math.min(...points.map((p) => p.v));
+    let maxV = Math.max(...points.map((p) => p.v));
+    if (minV === maxV) {
+      minV -= 1;
+      maxV += 1;
+    }
+
+    const chart = document.createElement("div");
+    chart.className = "chart";
+    const title = document.createElement("h3");
+    title.textContent = series[0].type + " · " + series[0].device + (series[0].unit ? " (" + series[0].unit + ")" : "") +
+      " — " + formatValue(minV) + " to " + formatValue(maxV);
+    chart.appendChild(title);
+
+    const svg = document.createElementNS("http://www.w3.org/2000/svg", "svg");
+    svg.setAttribute("viewBox", "0 0 1000 300");
+    svg.setAttribute("preserveAspectRatio", "none");
+    const legend = document.createElement("div");
+    legend.className = "legend";
+
+    series.forEach((s, index) => {
+      const color = colors[index % colors.length];
+      const line = document.createElementNS("http://www.w3.org/2000/svg", "polyline");
+      line.setAttribute("fill", "none");
+      line.setAttribute("stroke", color);
+      line.setAttribute("stroke-width", "2");
+      line.setAttribute("vector-effect", "non-scaling-stroke");
+      line.setAttribute("points", s.points.map((p) =>
+        ((p.t - minT) / (maxT - minT) * 1000).toFixed(1) + "," + (300 - (p.v - minV) / (maxV - minV) * 300).toFixed(1)).join(" "));
+      svg.appendChild(line);
+
+      const label = document.createElement("span");
+      label.style.setProperty("--color", color);
+      label.textContent = s.field + " " + formatValue(s.points[s.points.length - 1].v);
+      legend.appendChild(label);
+    });
+
+    chart.appendChild(svg);
+    chart.appendChild(legend);
+    container.appendChild(chart);
+  }
+}
+
+function record(event) {
+  state.latest[key(event.type, event.device)] = event;
+  if (!charted.includes(event.type) || typeof event.value !== "object" || event.value === null) {
+    return;
+  }
+
+  for (const [field, value] of Object.entries(event.value)) {
+    if (typeof value !== "number" && typeof value !== "boolean") {
+      continue;
+    }
+    const seriesKey = key(event.type, event.device, field);
+    const series = state.history[seriesKey];
+    if (!series) {
+      continue;
+    }
+    series.points.push({ t: event.timestamp, v: Number(value) });
+    while (series.points.length > 0 && series.points[0].t < event.timestamp - state.historySeconds) {
+      series.points.shift();
+    }
+  }
+}
+
+async function refresh() {
+  const response = await fetch("dashboard/state");
+  const snapshot = await response.json();
+
+  state.status = snapshot.status;
+  state.historySeconds = snapshot.historySeconds;
+  state.latest = {};
+  state.history = {};
+  snapshot.latest.forEach((event) => { state.latest[key(event.type, event.device)] = event; });
+  snapshot.history.forEach((series) => { state.history[key(series.type, series.device, series.field)] = series; });
+
+  renderStatus();
+  renderLatest();
+  renderCharts();
+}
+
+function listen() {
+  const source = new EventSource("dashboard/events");
+  source.addEventListener("measurement", (message) => {
+    const event = JSON.parse(message.data);
+    record(event);
+    if (event.type === "SWCTemperature" && state.status) {
+      state.status.lastPoll = event.timestamp;
+    }
+    renderLatest();
+    renderCharts();
+  });
+  // reloading the snapshot catches up with the events missed while disconnected
+  source.addEventListener("open", () => refresh());
+}
+
+refresh();
+listen();
+// the status of the session is refreshed periodically
+setInterval(refresh, 15000);
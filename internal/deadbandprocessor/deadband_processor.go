@@ -0,0 +1,101 @@
+package deadbandprocessor
+
+import (
+	"math"
+	"reflect"
+	"time"
+
+	"github.com/renajohn/pac_collector/api"
+	"github.com/renajohn/pac_collector/internal/fields"
+)
+
+// DeadbandProcessor only forwards a measurement when one of its fields moved beyond its deadband
+// since the last forwarded measurement of the same type and device. A heartbeat forces a full record to be
+// forwarded periodically so that consumers can still detect liveness.
+type DeadbandProcessor struct {
+	DefaultDeadband float64            // applies to numeric fields without an explicit deadband
+	Deadbands       map[string]float64 // per field deadband
+	Heartbeat       time.Duration      // 0 disables the heartbeat
+
+	lastForwarded map[seriesKey]forwarded
+}
+
+type seriesKey struct {
+	measurementType api.MeasurementType
+	device          string
+}
+
+type forwarded struct {
+	timestamp int64
+	values    map[string]interface{}
+}
+
+// NewDeadbandProcessor creates a new DeadbandProcessor
+func NewDeadbandProcessor(defaultDeadband float64, deadbands map[string]float64, heartbeat time.Duration) *DeadbandProcessor {
+	processor := DeadbandProcessor{
+		DefaultDeadband: defaultDeadband,
+		Deadbands:       deadbands,
+		Heartbeat:       heartbeat,
+		lastForwarded:   make(map[seriesKey]forwarded),
+	}
+
+	return &processor
+}
+
+// Process satisfies the api.Processor interface
+func (dp *DeadbandProcessor) Process(m api.Measurement) []api.Measurement {
+	values, err := fields.Decode(m)
+	if err != nil {
+		// not a record we know how to compare, let it through
+		return []api.Measurement{m}
+	}
+
+	key := seriesKey{measurementType: m.MeasurementType, device: m.Device}
+	last, found := dp.lastForwarded[key]
+	if found && !dp.heartbeatDue(last, m) && !dp.changed(last.values, values) {
+		return nil
+	}
+
+	dp.lastForwarded[key] = forwarded{timestamp: m.Timestamp, values: values}
+	return []api.Measurement{m}
+}
+
+func (dp *DeadbandProcessor) heartbeatDue(last forwarded, m api.Measurement) bool {
+	if dp.Heartbeat <= 0 {
+		return false
+	}
+
+	return time.Duration(m.Timestamp-last.timestamp)*time.Second >= dp.Heartbeat
+}
+
+func (dp *DeadbandProcessor) changed(previous map[string]interface{}, current map[string]interface{}) bool {
+	if len(previous) != len(current) {
+		return true
+	}
+
+	for name, value := range current {
+		previousValue, found := previous[name]
+		if !found {
+			return true
+		}
+
+		number, isNumber := value.(float64)
+		previousNumber, wasNumber := previousValue.(float64)
+		if isNumber && wasNumber {
+			if math.Abs(number-previousNumber) > dp.deadband(name) {
+				return true
+			}
+		} else if !reflect.DeepEqual(value, previousValue) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (dp *DeadbandProcessor) deadband(name string) float64 {
+	if deadband, found := dp.Deadbands[name]; found {
+		return deadband
+	}
+	return dp.DefaultDeadband
+}
@@ -0,0 +1,92 @@
+package deadbandprocessor
+
+import (
+	"testing"
+	"time"
+
+	"github.com/renajohn/pac_collector/api"
+)
+
+func makeMeasurement(timestamp int64, value string) api.Measurement {
+	return api.Measurement{
+		MeasurementType: api.SWCTemperature,
+		Timestamp:       timestamp,
+		Value:           []byte(value),
+	}
+}
+
+func TestProcess(t *testing.T) {
+	assertForwarded := func(t *testing.T, expect bool, got []api.Measurement) {
+		t.Helper()
+		if expect && len(got) != 1 {
+			t.Errorf("Expected measurement to be forwarded, got %d measurements", len(got))
+		} else if !expect && len(got) != 0 {
+			t.Errorf("Expected measurement to be dropped, got %d measurements", len(got))
+		}
+	}
+
+	t.Run("First measurement is always forwarded", func(t *testing.T) {
+		processor := NewDeadbandProcessor(0.5, nil, 0)
+
+		assertForwarded(t, true, processor.Process(makeMeasurement(1, `{"TankTemperature":52.3}`)))
+	})
+
+	t.Run("Changes within the deadband are dropped", func(t *testing.T) {
+		processor := NewDeadbandProcessor(0.5, nil, 0)
+
+		processor.Process(makeMeasurement(1, `{"TankTemperature":52.3}`))
+		assertForwarded(t, false, processor.Process(makeMeasurement(2, `{"TankTemperature":52.3}`)))
+		assertForwarded(t, false, processor.Process(makeMeasurement(3, `{"TankTemperature":52.7}`)))
+		assertForwarded(t, true, processor.Process(makeMeasurement(4, `{"TankTemperature":52.9}`)))
+	})
+
+	t.Run("Deadband is measured against the last forwarded value", func(t *testing.T) {
+		processor := NewDeadbandProcessor(0.5, nil, 0)
+
+		processor.Process(makeMeasurement(1, `{"TankTemperature":52.0}`))
+		assertForwarded(t, false, processor.Process(makeMeasurement(2, `{"TankTemperature":52.4}`)))
+		assertForwarded(t, true, processor.Process(makeMeasurement(3, `{"TankTemperature":52.8}`)))
+	})
+
+	t.Run("Per field deadband overrides the default one", func(t *testing.T) {
+		processor := NewDeadbandProcessor(0.5, map[string]float64{"OutsideTemperature": 2}, 0)
+
+		processor.Process(makeMeasurement(1, `{"TankTemperature":52.0,"OutsideTemperature":4.0}`))
+		assertForwarded(t, false, processor.Process(makeMeasurement(2, `{"TankTemperature":52.0,"OutsideTemperature":5.5}`)))
+		assertForwarded(t, true, processor.Process(makeMeasurement(3, `{"TankTemperature":52.0,"OutsideTemperature":6.5}`)))
+	})
+
+	t.Run("Heartbeat forces a measurement to be forwarded", func(t *testing.T) {
+		processor := NewDeadbandProcessor(0.5, nil, time.Minute)
+
+		processor.Process(makeMeasurement(0, `{"TankTemperature":52.0}`))
+		assertForwarded(t, false, processor.Process(makeMeasurement(59, `{"TankTemperature":52.0}`)))
+		assertForwarded(t, true, processor.Process(makeMeasurement(60, `{"TankTemperature":52.0}`)))
+		assertForwarded(t, false, processor.Process(makeMeasurement(61, `{"TankTemperature":52.0}`)))
+	})
+
+	t.Run("Measurement types are tracked independently", func(t *testing.T) {
+		processor := NewDeadbandProcessor(0.5, nil, 0)
+		other := makeMeasurement(2, `{"TankTemperature":52.0}`)
+		other.MeasurementType = "Other"
+
+		processor.Process(makeMeasurement(1, `{"TankTemperature":52.0}`))
+		assertForwarded(t, true, processor.Process(other))
+	})
+
+	t.Run("Devices are tracked independently", func(t *testing.T) {
+		processor := NewDeadbandProcessor(0.5, nil, 0)
+		other := makeMeasurement(2, `{"TankTemperature":52.0}`)
+		other.Device = "other"
+
+		processor.Process(makeMeasurement(1, `{"TankTemperature":52.0}`))
+		assertForwarded(t, true, processor.Process(other))
+	})
+
+	t.Run("Non JSON measurements are forwarded", func(t *testing.T) {
+		processor := NewDeadbandProcessor(0.5, nil, 0)
+
+		processor.Process(makeMeasurement(1, "42"))
+		assertForwarded(t, true, processor.Process(makeMeasurement(2, "42")))
+	})
+}
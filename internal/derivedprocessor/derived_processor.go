@@ -0,0 +1,94 @@
+package derivedprocessor
+
+import (
+	"encoding/json"
+	"log"
+
+	"github.com/renajohn/pac_collector/api"
+	"github.com/renajohn/pac_collector/internal/swcsource"
+)
+
+// Volumetric heat capacities in kJ/(l.K) used to estimate the thermal power out of a flow rate
+const (
+	WaterHeatCapacity = 4.18
+	BrineHeatCapacity = 3.9 // ~25% glycol mix
+)
+
+// DerivedMeasurement represents the values computed out of an SWCMeasurement.
+// Temperature spreads are in K, thermal powers in kW and only set when the flow rate is known.
+type DerivedMeasurement struct {
+	HeatingDeltaT       float64  // heating outbound - heating inbound
+	DrillDeltaT         float64  // brine cooling through the heat pump, drill inbound - drill outbound
+	TemperatureLift     float64  // heating outbound - drill outbound
+	HeatingThermalPower *float64 `json:",omitempty"` // power delivered to the heating circuit
+	DrillThermalPower   *float64 `json:",omitempty"` // power extracted from the ground
+}
+
+// DerivedProcessor emits an SWCDerived measurement next to every SWCTemperature measurement
+type DerivedProcessor struct {
+	HeatingFlowRate float64 // l/h, 0 when unknown
+	DrillFlowRate   float64 // l/h, 0 when unknown
+
+	HeatingHeatCapacity float64 // kJ/(l.K), defaults to WaterHeatCapacity
+	DrillHeatCapacity   float64 // kJ/(l.K), defaults to BrineHeatCapacity
+}
+
+// NewDerivedProcessor creates a new DerivedProcessor. Flow rates are in l/h, 0 when unknown.
+func NewDerivedProcessor(heatingFlowRate float64, drillFlowRate float64) *DerivedProcessor {
+	processor := DerivedProcessor{
+		HeatingFlowRate:     heatingFlowRate,
+		DrillFlowRate:       drillFlowRate,
+		HeatingHeatCapacity: WaterHeatCapacity,
+		DrillHeatCapacity:   BrineHeatCapacity,
+	}
+
+	return &processor
+}
+
+// Process satisfies the api.Processor interface
+func (dp *DerivedProcessor) Process(m api.Measurement) []api.Measurement {
+	if m.MeasurementType != api.SWCTemperature {
+		return []api.Measurement{m}
+	}
+
+	var temperatures swcsource.SWCMeasurement
+	err := json.Unmarshal(m.Value, &temperatures)
+	if err != nil {
+		log.Printf("Failed to decode SWC temperatures, skipping derived metrics: %v", err)
+		return []api.Measurement{m}
+	}
+
+	data, _ := json.Marshal(dp.derive(temperatures))
+	derived := api.Measurement{
+		MeasurementType: api.SWCDerived,
+		Device:          m.Device,
+		Timestamp:       m.Timestamp,
+		Value:           data,
+	}
+
+	return []api.Measurement{m, derived}
+}
+
+func (dp *DerivedProcessor) derive(temperatures swcsource.SWCMeasurement) DerivedMeasurement {
+	derived := DerivedMeasurement{
+		HeatingDeltaT:   temperatures.HeatingOutboundTemperature - temperatures.HeatingInboundTemperature,
+		DrillDeltaT:     temperatures.DrillInboundTemperature - temperatures.DrillOutboundTemperature,
+		TemperatureLift: temperatures.HeatingOutboundTemperature - temperatures.DrillOutboundTemperature,
+	}
+
+	if dp.HeatingFlowRate > 0 {
+		power := thermalPower(dp.HeatingFlowRate, dp.HeatingHeatCapacity, derived.HeatingDeltaT)
+		derived.HeatingThermalPower = &power
+	}
+	if dp.DrillFlowRate > 0 {
+		power := thermalPower(dp.DrillFlowRate, dp.DrillHeatCapacity, derived.DrillDeltaT)
+		derived.DrillThermalPower = &power
+	}
+
+	return derived
+}
+
+// thermalPower returns the power in kW carried by a flow (l/h) of a fluid (kJ/(l.K)) over a temperature spread (K)
+func thermalPower(flowRate float64, heatCapacity float64, deltaT float64) float64 {
+	return flowRate / 3600 * heatCapacity * deltaT
+}
@@ -0,0 +1,94 @@
+package derivedprocessor
+
+import (
+	"encoding/json"
+	"math"
+	"reflect"
+	"testing"
+
+	"github.com/renajohn/pac_collector/api"
+	"github.com/renajohn/pac_collector/internal/swcsource"
+)
+
+func makeMeasurement(t *testing.T) api.Measurement {
+	t.Helper()
+	data, _ := json.Marshal(swcsource.SWCMeasurement{
+		HeatingOutboundTemperature: 35.0,
+		HeatingInboundTemperature:  30.0,
+		DrillInboundTemperature:    11.0,
+		DrillOutboundTemperature:   8.0,
+	})
+
+	return api.Measurement{
+		MeasurementType: api.SWCTemperature,
+		Device:          "swc",
+		Timestamp:       123456789,
+		Value:           data,
+	}
+}
+
+func decodeDerived(t *testing.T, measurements []api.Measurement) DerivedMeasurement {
+	t.Helper()
+	if len(measurements) != 2 {
+		t.Fatalf("Expected 2 measurements, got %d", len(measurements))
+	}
+
+	derived := measurements[1]
+	if derived.MeasurementType != api.SWCDerived || derived.Timestamp != 123456789 || derived.Device != "swc" {
+		t.Errorf("Unexpected derived measurement %+v", derived)
+	}
+
+	var values DerivedMeasurement
+	err := json.Unmarshal(derived.Value, &values)
+	if err != nil {
+		t.Fatalf("Failed to decode derived measurement: %v", err)
+	}
+	return values
+}
+
+func assertFloat(t *testing.T, expect float64, got float64) {
+	t.Helper()
+	if math.Abs(expect-got) > 1e-9 {
+		t.Errorf("Expected value of %v got %v", expect, got)
+	}
+}
+
+func TestProcess(t *testing.T) {
+	t.Run("Temperature spreads are computed", func(t *testing.T) {
+		processor := NewDerivedProcessor(0, 0)
+		measurement := makeMeasurement(t)
+
+		processed := processor.Process(measurement)
+
+		if !reflect.DeepEqual(measurement, processed[0]) {
+			t.Errorf("Expected original measurement to be forwarded first, got %+v", processed[0])
+		}
+		derived := decodeDerived(t, processed)
+		assertFloat(t, 5, derived.HeatingDeltaT)
+		assertFloat(t, 3, derived.DrillDeltaT)
+		assertFloat(t, 27, derived.TemperatureLift)
+		if derived.HeatingThermalPower != nil || derived.DrillThermalPower != nil {
+			t.Errorf("Expected no thermal power without flow rates, got %+v", derived)
+		}
+	})
+
+	t.Run("Thermal power is computed out of the flow rates", func(t *testing.T) {
+		processor := NewDerivedProcessor(1200, 1800)
+
+		derived := decodeDerived(t, processor.Process(makeMeasurement(t)))
+
+		assertFloat(t, 1200.0/3600*WaterHeatCapacity*5, *derived.HeatingThermalPower)
+		assertFloat(t, 1800.0/3600*BrineHeatCapacity*3, *derived.DrillThermalPower)
+	})
+
+	t.Run("Other measurement types are forwarded", func(t *testing.T) {
+		processor := NewDerivedProcessor(0, 0)
+		other := api.Measurement{MeasurementType: "Other", Value: []byte("42")}
+
+		processed := processor.Process(other)
+
+		if !reflect.DeepEqual([]api.Measurement{other}, processed) {
+			t.Errorf("Expected measurement to be forwarded, got %+v", processed)
+		}
+	})
+}
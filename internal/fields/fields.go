@@ -0,0 +1,104 @@
+package fields
+
+import (
+	"encoding/json"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/renajohn/pac_collector/api"
+)
+
+// Decode returns the fields of a measurement whose value is a JSON object
+func Decode(m api.Measurement) (map[string]interface{}, error) {
+	var values map[string]interface{}
+
+	err := json.Unmarshal(m.Value, &values)
+	if err != nil {
+		return nil, err
+	}
+
+	return values, nil
+}
+
+// Numeric returns the numeric fields of a measurement whose value is a JSON object.
+// Booleans are considered numeric, false being 0 and true 1.
+func Numeric(m api.Measurement) (map[string]float64, error) {
+	values, err := Decode(m)
+	if err != nil {
+		return nil, err
+	}
+
+	numeric := make(map[string]float64, len(values))
+	for name, value := range values {
+		switch typed := value.(type) {
+		case float64:
+			numeric[name] = typed
+		case bool:
+			numeric[name] = 0
+			if typed {
+				numeric[name] = 1
+			}
+		}
+	}
+
+	return numeric, nil
+}
+
+// Names returns the field names in alphabetical order, for stable iteration
+func Names(values map[string]float64) []string {
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// Unit returns the unit of a field out of its name, empty when unknown or dimensionless.
+// Aggregated fields, e.g. TankTemperatureMean, share the unit of the field they summarize.
+func Unit(name string) string {
+	if strings.HasSuffix(name, "Count") {
+		return ""
+	}
+	for _, suffix := range []string{"Min", "Max", "Mean", "Last"} {
+		name = strings.TrimSuffix(name, suffix)
+	}
+
+	switch {
+	case strings.HasSuffix(name, "Temperature"), strings.HasSuffix(name, "DeltaT"), strings.HasSuffix(name, "Lift"):
+		return "°C"
+	case strings.HasSuffix(name, "ThermalPower"):
+		return "kW"
+	case strings.HasSuffix(name, "Power"):
+		return "W"
+	case strings.HasSuffix(name, "Energy"):
+		return "kWh"
+	}
+
+	return ""
+}
+
+// SnakeCase converts a CamelCase name to snake_case, acronyms being kept together, e.g. SWCTemperature to swc_temperature
+func SnakeCase(name string) string {
+	runes := []rune(name)
+
+	var snake strings.Builder
+	for i, char := range runes {
+		if unicode.IsUpper(char) && i > 0 {
+			previousLower := unicode.IsLower(runes[i-1]) || unicode.IsDigit(runes[i-1])
+			nextLower := i+1 < len(runes) && unicode.IsLower(runes[i+1])
+			if previousLower || (unicode.IsUpper(runes[i-1]) && nextLower) {
+				snake.WriteRune('_')
+			}
+		}
+		if unicode.IsLetter(char) || unicode.IsDigit(char) {
+			snake.WriteRune(unicode.ToLower(char))
+		} else {
+			snake.WriteRune('_')
+		}
+	}
+
+	return snake.String()
+}
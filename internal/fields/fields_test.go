@@ -0,0 +1,78 @@
+package fields
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/renajohn/pac_collector/api"
+)
+
+func TestNumeric(t *testing.T) {
+	t.Run("Happy case", func(t *testing.T) {
+		m := api.Measurement{
+			MeasurementType: api.SWCTemperature,
+			Value:           []byte(`{"TankTemperature":52.3,"OutsideTemperature":-4,"Label":"foo","Compressor":true}`),
+		}
+
+		values, err := Numeric(m)
+
+		if err != nil {
+			t.Fatalf("No error was expected but got %v", err)
+		}
+		expected := map[string]float64{"TankTemperature": 52.3, "OutsideTemperature": -4, "Compressor": 1}
+		if !reflect.DeepEqual(expected, values) {
+			t.Errorf("Expected %v got %v", expected, values)
+		}
+	})
+
+	t.Run("When value is not a JSON object, an error should be returned", func(t *testing.T) {
+		_, err := Numeric(api.Measurement{Value: []byte("42")})
+
+		if err == nil {
+			t.Error("An error was expected and none was returned")
+		}
+	})
+}
+
+func TestNames(t *testing.T) {
+	names := Names(map[string]float64{"b": 1, "c": 2, "a": 3})
+
+	if !reflect.DeepEqual([]string{"a", "b", "c"}, names) {
+		t.Errorf("Expected sorted names, got %v", names)
+	}
+}
+
+func TestUnit(t *testing.T) {
+	expected := map[string]string{
+		"TankTemperature":      "°C",
+		"TankTemperatureMean":  "°C",
+		"TankTemperatureCount": "",
+		"HeatingDeltaT":        "°C",
+		"TemperatureLift":      "°C",
+		"HeatingThermalPower":  "kW",
+		"Power":                "W",
+		"TotalEnergy":          "kWh",
+		"Compressor":           "",
+	}
+
+	for name, unit := range expected {
+		if Unit(name) != unit {
+			t.Errorf("Expected unit of %s to be %q got %q", name, unit, Unit(name))
+		}
+	}
+}
+
+func TestSnakeCase(t *testing.T) {
+	expected := map[string]string{
+		"SWCTemperature":      "swc_temperature",
+		"HeatingDeltaT":       "heating_delta_t",
+		"COP":                 "cop",
+		"TankTemperatureMean": "tank_temperature_mean",
+	}
+
+	for name, snake := range expected {
+		if SnakeCase(name) != snake {
+			t.Errorf("Expected %s to become %s got %s", name, snake, SnakeCase(name))
+		}
+	}
+}
@@ -0,0 +1,222 @@
+package filesink
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/renajohn/pac_collector/api"
+	"github.com/renajohn/pac_collector/internal/fields"
+	"github.com/renajohn/pac_collector/internal/models"
+)
+
+// Formats
+const (
+	JSONLines = "jsonl" // one JSON object per measurement
+	CSV       = "csv"   // one file per measurement type, one column per field of the type
+)
+
+// Sync policies
+const (
+	SyncAlways   = "always"   // fsync after every measurement
+	SyncInterval = "interval" // fsync at most every SyncInterval
+	SyncNever    = "never"    // leave it to the OS, files being synced on rotation and close
+)
+
+// FileSink writes measurements to local files, rotated by size or age. Rotated files are
+// timestamped, optionally compressed, and only the most recent ones are kept.
+type FileSink struct {
+	Path           string // e.g. /var/lib/pacmon/measurements.jsonl, CSV files being suffixed with the measurement type
+	Format         string
+	MaxSize        int64         // bytes after which the file is rotated, 0 to disable
+	RotateInterval time.Duration // age after which the file is rotated, 0 to disable
+	Compression    string        // compression of the rotated files: "", "gzip" or "zstd"
+	MaxFiles       int           // rotated files kept per output, 0 to keep them all
+	Retention      time.Duration // age after which rotated files are deleted, 0 to keep them
+	Sync           string
+	SyncInterval   time.Duration
+
+	now     func() time.Time
+	mutex   sync.Mutex
+	outputs map[string]*rotatingFile
+}
+
+// NewFileSink creates a new sink writing to path, the format being CSV when the path ends with .csv and JSON Lines otherwise
+func NewFileSink(path string) *FileSink {
+	format := JSONLines
+	if strings.EqualFold(filepath.Ext(path), ".csv") {
+		format = CSV
+	}
+
+	sink := FileSink{
+		Path:         path,
+		Format:       format,
+		Sync:         SyncInterval,
+		SyncInterval: 10 * time.Second,
+		now:          time.Now,
+		outputs:      make(map[string]*rotatingFile),
+	}
+
+	return &sink
+}
+
+type jsonLine struct {
+	Type      api.MeasurementType `json:"type"`
+	Device    string              `json:"device"`
+	Timestamp int64               `json:"timestamp"`
+	Value     json.RawMessage     `json:"value"`
+	Tags      map[string]string   `json:"tags,omitempty"`
+}
+
+// Put satisfies the api.Sink interface
+func (fs *FileSink) Put(m api.Measurement) error {
+	fs.mutex.Lock()
+	defer fs.mutex.Unlock()
+
+	if fs.Format == CSV {
+		return fs.putCSV(m)
+	}
+
+	line, err := json.Marshal(jsonLine{m.MeasurementType, m.Device, m.Timestamp, json.RawMessage(m.Value), m.Tags})
+	if err != nil {
+		return err
+	}
+
+	output, err := fs.output(fs.Path, nil)
+	if err != nil {
+		return err
+	}
+	return output.write(append(line, '\n'))
+}
+
+// putCSV appends a row to the file of the measurement type. The columns are the fields of the model
+// of the type, if any, and the fields seen so far otherwise: a missing field is an empty cell, the
+// file being rotated only when a field without a column shows up.
+func (fs *FileSink) putCSV(m api.Measurement) error {
+	values, err := fields.Decode(m)
+	if err != nil {
+		return fmt.Errorf("cannot write %s measurement as CSV: %v", m.MeasurementType, err)
+	}
+
+	names, _ := models.Fields(m.MeasurementType)
+	for name := range values {
+		if !contains(names, name) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	header := append([]string{"timestamp", "device"}, names...)
+
+	extension := filepath.Ext(fs.Path)
+	path := strings.TrimSuffix(fs.Path, extension) + "-" + string(m.MeasurementType) + extension
+	output, err := fs.output(path, header)
+	if err != nil {
+		return err
+	}
+
+	row := []string{strconv.FormatInt(m.Timestamp, 10), m.Device}
+	for _, name := range output.header[2:] {
+		row = append(row, formatCell(values[name]))
+	}
+	return output.write(csvLine(row))
+}
+
+// Close syncs and closes the files
+func (fs *FileSink) Close() error {
+	fs.mutex.Lock()
+	defer fs.mutex.Unlock()
+
+	var firstErr error
+	for path, output := range fs.outputs {
+		if err := output.close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+		delete(fs.outputs, path)
+	}
+
+	return firstErr
+}
+
+// output returns the file to write to, rotating it first when it expired or its header misses
+// some of the columns of header
+func (fs *FileSink) output(path string, header []string) (*rotatingFile, error) {
+	output, found := fs.outputs[path]
+	if !found {
+		output = &rotatingFile{sink: fs, path: path}
+		fs.outputs[path] = output
+	}
+
+	if output.file != nil && (output.expired() || (header != nil && !covers(output.header, header))) {
+		if err := output.rotate(); err != nil {
+			return nil, err
+		}
+	}
+	if output.file == nil {
+		if err := output.open(header); err != nil {
+			return nil, err
+		}
+	}
+
+	return output, nil
+}
+
+func formatCell(value interface{}) string {
+	switch typed := value.(type) {
+	case nil:
+		return ""
+	case float64:
+		return strconv.FormatFloat(typed, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(typed)
+	case string:
+		return typed
+	}
+
+	data, _ := json.Marshal(value)
+	return string(data)
+}
+
+func csvLine(row []string) []byte {
+	var line strings.Builder
+	writer := csv.NewWriter(&line)
+	writer.Write(row)
+	writer.Flush()
+	return []byte(line.String())
+}
+
+// covers tells whether every column of header is in existing
+func covers(existing []string, header []string) bool {
+	for _, column := range header {
+		if !contains(existing, column) {
+			return false
+		}
+	}
+	return true
+}
+
+func contains(columns []string, column string) bool {
+	for _, c := range columns {
+		if c == column {
+			return true
+		}
+	}
+	return false
+}
+
+func equal(a []string, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,234 @@
+package filesink
+
+import (
+	"compress/gzip"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/renajohn/pac_collector/api"
+)
+
+func temperature(timestamp int64, value string) api.Measurement {
+	return api.Measurement{MeasurementType: api.SWCTemperature, Device: "swc", Timestamp: timestamp, Value: []byte(value)}
+}
+
+func cycle(timestamp int64, value string) api.Measurement {
+	return api.Measurement{MeasurementType: api.CompressorCycle, Device: "swc", Timestamp: timestamp, Value: []byte(value)}
+}
+
+// custom returns a measurement of a type without a model
+func custom(timestamp int64, value string) api.Measurement {
+	return api.Measurement{MeasurementType: "Custom", Device: "swc", Timestamp: timestamp, Value: []byte(value)}
+}
+
+func listFiles(t *testing.T, dir string) []string {
+	t.Helper()
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var names []string
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	sort.Strings(names)
+	return names
+}
+
+func readFile(t *testing.T, path string) string {
+	t.Helper()
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(content)
+}
+
+// fakeClock returns a clock starting at a fixed time, advanced by the returned function
+func fakeClock() (func() time.Time, func(time.Duration)) {
+	now := time.Date(2020, 9, 13, 12, 26, 40, 0, time.UTC)
+	return func() time.Time { return now }, func(d time.Duration) { now = now.Add(d) }
+}
+
+func TestPut(t *testing.T) {
+	t.Run("Measurements are written as JSON lines", func(t *testing.T) {
+		dir := t.TempDir()
+		sink := NewFileSink(filepath.Join(dir, "pacmon.jsonl"))
+
+		sink.Put(temperature(42, `{"TankTemperature":52.3}`))
+		sink.Put(api.Measurement{MeasurementType: api.Anomaly, Device: "swc", Timestamp: 43, Value: []byte(`{"Kind":"stuck"}`), Tags: map[string]string{"quality": "suspect"}})
+		sink.Close()
+
+		expected := `{"type":"SWCTemperature","device":"swc","timestamp":42,"value":{"TankTemperature":52.3}}
+{"type":"Anomaly","device":"swc","timestamp":43,"value":{"Kind":"stuck"},"tags":{"quality":"suspect"}}
+`
+		if content := readFile(t, filepath.Join(dir, "pacmon.jsonl")); content != expected {
+			t.Errorf("Expected\n%s\ngot\n%s", expected, content)
+		}
+	})
+
+	t.Run("CSV files hold one column per field of the measurement type", func(t *testing.T) {
+		dir := t.TempDir()
+		sink := NewFileSink(filepath.Join(dir, "pacmon.csv"))
+		sink.now, _ = fakeClock()
+
+		sink.Put(cycle(42, `{"Event":"CompressorStart","OffDuration":600,"StartsPerHour":2}`))
+		sink.Put(cycle(43, `{"Event":"CompressorStop","RunDuration":900,"StartsPerHour":2}`))
+		sink.Put(cycle(44, `{"Event":"DefrostStart","StartsPerHour":2}`))
+		sink.Close()
+
+		expected := []string{"pacmon-CompressorCycle.csv"}
+		if files := listFiles(t, dir); !equal(expected, files) {
+			t.Fatalf("Expected files %v got %v", expected, files)
+		}
+		content := readFile(t, filepath.Join(dir, "pacmon-CompressorCycle.csv"))
+		if content != "timestamp,device,Event,OffDuration,RunDuration,StartsPerHour\n42,swc,CompressorStart,600,,2\n43,swc,CompressorStop,,900,2\n44,swc,DefrostStart,,,2\n" {
+			t.Errorf("Unexpected file\n%s", content)
+		}
+	})
+
+	t.Run("CSV files of a type without a model are rotated only when a field is added", func(t *testing.T) {
+		dir := t.TempDir()
+		sink := NewFileSink(filepath.Join(dir, "pacmon.csv"))
+		sink.now, _ = fakeClock()
+
+		sink.Put(custom(42, `{"A":1,"B":2}`))
+		sink.Put(custom(43, `{"A":3}`))
+		sink.Put(custom(44, `{"A":4,"C":5}`))
+		sink.Close()
+
+		expected := []string{"pacmon-Custom-20200913T122640.csv", "pacmon-Custom.csv"}
+		if files := listFiles(t, dir); !equal(expected, files) {
+			t.Fatalf("Expected files %v got %v", expected, files)
+		}
+		if content := readFile(t, filepath.Join(dir, "pacmon-Custom-20200913T122640.csv")); content != "timestamp,device,A,B\n42,swc,1,2\n43,swc,3,\n" {
+			t.Errorf("Unexpected rotated file\n%s", content)
+		}
+		if content := readFile(t, filepath.Join(dir, "pacmon-Custom.csv")); content != "timestamp,device,A,C\n44,swc,4,5\n" {
+			t.Errorf("Unexpected current file\n%s", content)
+		}
+	})
+
+	t.Run("Existing CSV files missing a column are rotated when reopened", func(t *testing.T) {
+		dir := t.TempDir()
+		path := filepath.Join(dir, "pacmon-Custom.csv")
+		if err := os.WriteFile(path, []byte("timestamp,device,A\n41,swc,1\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		sink := NewFileSink(filepath.Join(dir, "pacmon.csv"))
+		sink.now, _ = fakeClock()
+
+		sink.Put(custom(42, `{"A":2,"B":3}`))
+		sink.Close()
+
+		expected := []string{"pacmon-Custom-20200913T122640.csv", "pacmon-Custom.csv"}
+		if files := listFiles(t, dir); !equal(expected, files) {
+			t.Fatalf("Expected files %v got %v", expected, files)
+		}
+		if content := readFile(t, path); content != "timestamp,device,A,B\n42,swc,2,3\n" {
+			t.Errorf("Unexpected current file\n%s", content)
+		}
+	})
+
+	t.Run("Existing CSV files covering the fields are appended to", func(t *testing.T) {
+		dir := t.TempDir()
+		path := filepath.Join(dir, "pacmon-Custom.csv")
+		if err := os.WriteFile(path, []byte("timestamp,device,A,B\n41,swc,1,2\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		sink := NewFileSink(filepath.Join(dir, "pacmon.csv"))
+		sink.now, _ = fakeClock()
+
+		sink.Put(custom(42, `{"A":3}`))
+		sink.Close()
+
+		if content := readFile(t, path); content != "timestamp,device,A,B\n41,swc,1,2\n42,swc,3,\n" {
+			t.Errorf("Unexpected file\n%s", content)
+		}
+	})
+
+	t.Run("Files are rotated by size, compressed with gzip and only the latest ones are kept", func(t *testing.T) {
+		dir := t.TempDir()
+		sink := NewFileSink(filepath.Join(dir, "pacmon.jsonl"))
+		sink.Sync = SyncAlways
+		sink.MaxSize = 10
+		sink.Compression = "gzip"
+		sink.MaxFiles = 2
+		var advance func(time.Duration)
+		sink.now, advance = fakeClock()
+
+		for timestamp := int64(0); timestamp < 4; timestamp++ {
+			sink.Put(temperature(timestamp, `{"TankTemperature":52.3}`))
+			advance(time.Second)
+		}
+		sink.Close()
+
+		expected := []string{"pacmon-20200913T122642.jsonl.gz", "pacmon-20200913T122643.jsonl.gz"}
+		if files := listFiles(t, dir); !equal(expected, files) {
+			t.Fatalf("Expected files %v got %v", expected, files)
+		}
+		file, _ := os.Open(filepath.Join(dir, expected[1]))
+		defer file.Close()
+		reader, err := gzip.NewReader(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		content, _ := ioutil.ReadAll(reader)
+		if string(content) != `{"type":"SWCTemperature","device":"swc","timestamp":3,"value":{"TankTemperature":52.3}}`+"\n" {
+			t.Errorf("Unexpected compressed content %s", content)
+		}
+	})
+
+	t.Run("Files are rotated by age and compressed with zstd", func(t *testing.T) {
+		dir := t.TempDir()
+		sink := NewFileSink(filepath.Join(dir, "pacmon.jsonl"))
+		sink.RotateInterval = time.Hour
+		sink.Compression = "zstd"
+		var advance func(time.Duration)
+		sink.now, advance = fakeClock()
+
+		sink.Put(temperature(0, `{"TankTemperature":52.3}`))
+		advance(59 * time.Minute)
+		sink.Put(temperature(1, `{"TankTemperature":52.3}`))
+		advance(time.Minute)
+		sink.Put(temperature(2, `{"TankTemperature":52.3}`))
+		sink.Close()
+
+		expected := []string{"pacmon-20200913T132640.jsonl.zst", "pacmon.jsonl"}
+		if files := listFiles(t, dir); !equal(expected, files) {
+			t.Fatalf("Expected files %v got %v", expected, files)
+		}
+		file, _ := os.Open(filepath.Join(dir, expected[0]))
+		defer file.Close()
+		reader, _ := zstd.NewReader(file)
+		defer reader.Close()
+		content, _ := ioutil.ReadAll(reader)
+		if len(content) == 0 || string(content[len(content)-2:]) != "}\n" {
+			t.Errorf("Unexpected compressed content %s", content)
+		}
+	})
+
+	t.Run("Rotated files older than the retention are deleted", func(t *testing.T) {
+		dir := t.TempDir()
+		old := filepath.Join(dir, "pacmon-20200101T000000.jsonl")
+		ioutil.WriteFile(old, []byte("{}\n"), 0644)
+		os.Chtimes(old, time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC), time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC))
+		sink := NewFileSink(filepath.Join(dir, "pacmon.jsonl"))
+		sink.MaxSize = 1
+		sink.Retention = 24 * time.Hour
+		sink.now, _ = fakeClock()
+
+		sink.Put(temperature(0, `{"TankTemperature":52.3}`))
+
+		expected := []string{"pacmon-20200913T122640.jsonl"}
+		if files := listFiles(t, dir); !equal(expected, files) {
+			t.Errorf("Expected files %v got %v", expected, files)
+		}
+	})
+}
@@ -0,0 +1,246 @@
+package filesink
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// rotatingFile is an output file of the sink along with its rotation state
+type rotatingFile struct {
+	sink     *FileSink
+	path     string
+	file     *os.File
+	header   []string
+	size     int64
+	opened   time.Time
+	lastSync time.Time
+}
+
+// open appends to the existing file, the header being written to new files only. An existing file whose
+// header misses some of the columns, e.g. written before the fields changed, is rotated first, while
+// the header of a file covering them is kept.
+func (rf *rotatingFile) open(header []string) error {
+	if err := os.MkdirAll(filepath.Dir(rf.path), 0755); err != nil {
+		return err
+	}
+
+	file, err := os.OpenFile(rf.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+
+	if header != nil && info.Size() > 0 {
+		existing, err := firstLine(rf.path)
+		if err != nil {
+			file.Close()
+			return err
+		}
+		columns, err := csv.NewReader(strings.NewReader(existing)).Read()
+		if err != nil || !covers(columns, header) || !equal(columns[:2], header[:2]) {
+			file.Close()
+			if err := rf.rotate(); err != nil {
+				return err
+			}
+			return rf.open(header)
+		}
+		header = columns
+	}
+
+	rf.file, rf.header, rf.size = file, header, info.Size()
+	rf.opened, rf.lastSync = rf.sink.now(), rf.sink.now()
+
+	if header != nil && rf.size == 0 {
+		return rf.write(csvLine(header))
+	}
+	return nil
+}
+
+func (rf *rotatingFile) write(data []byte) error {
+	written, err := rf.file.Write(data)
+	rf.size += int64(written)
+	if err != nil {
+		return err
+	}
+
+	now := rf.sink.now()
+	if rf.sink.Sync == SyncAlways || (rf.sink.Sync == SyncInterval && now.Sub(rf.lastSync) >= rf.sink.SyncInterval) {
+		rf.lastSync = now
+		if err := rf.file.Sync(); err != nil {
+			return err
+		}
+	}
+
+	if rf.sink.MaxSize > 0 && rf.size >= rf.sink.MaxSize {
+		return rf.rotate()
+	}
+	return nil
+}
+
+func (rf *rotatingFile) expired() bool {
+	return rf.sink.RotateInterval > 0 && rf.sink.now().Sub(rf.opened) >= rf.sink.RotateInterval
+}
+
+func (rf *rotatingFile) close() error {
+	if rf.file == nil {
+		return nil
+	}
+
+	syncErr := rf.file.Sync()
+	closeErr := rf.file.Close()
+	rf.file = nil
+	if syncErr != nil {
+		return syncErr
+	}
+	return closeErr
+}
+
+// rotate closes the file, renames it with its rotation time, compresses it and applies the retention
+func (rf *rotatingFile) rotate() error {
+	if err := rf.close(); err != nil {
+		return err
+	}
+
+	extension := filepath.Ext(rf.path)
+	base := strings.TrimSuffix(rf.path, extension)
+	stamp := rf.sink.now().UTC().Format("20060102T150405")
+	rotated := fmt.Sprintf("%s-%s%s", base, stamp, extension)
+	for index := 1; exists(rotated) || exists(rotated+compressedExtension(rf.sink.Compression)); index++ {
+		rotated = fmt.Sprintf("%s-%s.%d%s", base, stamp, index, extension)
+	}
+
+	if err := os.Rename(rf.path, rotated); err != nil {
+		return err
+	}
+	if len(rf.sink.Compression) > 0 {
+		if err := compress(rotated, rf.sink.Compression); err != nil {
+			return err
+		}
+	}
+
+	return rf.applyRetention(base+"-", extension)
+}
+
+// applyRetention deletes the rotated files beyond MaxFiles or older than Retention
+func (rf *rotatingFile) applyRetention(prefix string, extension string) error {
+	matches, err := filepath.Glob(prefix + "*" + extension + "*")
+	if err != nil {
+		return err
+	}
+
+	var rotated []string
+	for _, match := range matches {
+		if isRotated(strings.TrimPrefix(match, prefix)) {
+			rotated = append(rotated, match)
+		}
+	}
+	sort.Strings(rotated)
+
+	for index, path := range rotated {
+		expired := false
+		if rf.sink.Retention > 0 {
+			info, err := os.Stat(path)
+			expired = err == nil && rf.sink.now().Sub(info.ModTime()) > rf.sink.Retention
+		}
+		if expired || (rf.sink.MaxFiles > 0 && index < len(rotated)-rf.sink.MaxFiles) {
+			if err := os.Remove(path); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// isRotated tells whether a file name suffix starts with a rotation timestamp, e.g. 20200913T122640.jsonl.gz
+func isRotated(suffix string) bool {
+	if len(suffix) < 15 {
+		return false
+	}
+	_, err := time.Parse("20060102T150405", suffix[:15])
+	return err == nil
+}
+
+func compressedExtension(compression string) string {
+	switch compression {
+	case "gzip":
+		return ".gz"
+	case "zstd":
+		return ".zst"
+	}
+	return ""
+}
+
+// compress replaces a file by its compressed version
+func compress(path string, compression string) error {
+	source, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer source.Close()
+
+	target, err := os.Create(path + compressedExtension(compression))
+	if err != nil {
+		return err
+	}
+	defer target.Close()
+
+	var writer io.WriteCloser
+	switch compression {
+	case "gzip":
+		writer = gzip.NewWriter(target)
+	case "zstd":
+		writer, err = zstd.NewWriter(target)
+		if err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("unknown compression %q", compression)
+	}
+
+	if _, err := io.Copy(writer, source); err != nil {
+		return err
+	}
+	if err := writer.Close(); err != nil {
+		return err
+	}
+	if err := target.Sync(); err != nil {
+		return err
+	}
+
+	return os.Remove(path)
+}
+
+// firstLine returns the first line of a file, including its line break
+func firstLine(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	line, err := bufio.NewReader(file).ReadString('\n')
+	if err != nil && err != io.EOF {
+		return "", err
+	}
+	return line, nil
+}
+
+func exists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
@@ -0,0 +1,113 @@
+package grpcserver
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+
+	"github.com/renajohn/pac_collector/api"
+	"github.com/renajohn/pac_collector/api/pacmonpb"
+	"github.com/renajohn/pac_collector/internal/streamhub"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// Server implements the Pacmon gRPC service on top of the stream hub observing the collector pipeline
+type Server struct {
+	pacmonpb.UnimplementedPacmonServer
+
+	hub *streamhub.Hub
+}
+
+// NewServer creates a new Server
+func NewServer(hub *streamhub.Hub) *Server {
+	server := Server{hub: hub}
+
+	return &server
+}
+
+// Serve registers the service on a new gRPC server listening on addr, returning once the listener fails
+func (s *Server) Serve(addr string) error {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+
+	return s.serve(listener)
+}
+
+func (s *Server) serve(listener net.Listener) error {
+	grpcServer := grpc.NewServer()
+	pacmonpb.RegisterPacmonServer(grpcServer, s)
+	return grpcServer.Serve(listener)
+}
+
+// Subscribe streams the measurements until the client goes away. Clients too slow to keep up
+// are ended with RESOURCE_EXHAUSTED, and can resume from the last ID they received.
+func (s *Server) Subscribe(request *pacmonpb.SubscribeRequest, stream pacmonpb.Pacmon_SubscribeServer) error {
+	subscription, backlog := s.hub.Subscribe(toFilter(request.GetFilter()), request.GetLastId(), request.LastId != nil)
+	defer s.hub.Unsubscribe(subscription)
+
+	for _, event := range backlog {
+		if err := stream.Send(toMeasurement(event)); err != nil {
+			return err
+		}
+	}
+
+	for {
+		select {
+		case event, ok := <-subscription.Events():
+			if !ok {
+				return status.Error(codes.ResourceExhausted, "subscriber too slow")
+			}
+			if err := stream.Send(toMeasurement(event)); err != nil {
+				return err
+			}
+		case <-stream.Context().Done():
+			return nil
+		}
+	}
+}
+
+// GetLatest returns the latest measurement of every type and device
+func (s *Server) GetLatest(ctx context.Context, request *pacmonpb.GetLatestRequest) (*pacmonpb.GetLatestResponse, error) {
+	response := pacmonpb.GetLatestResponse{}
+	for _, event := range s.hub.Latest(toFilter(request.GetFilter())) {
+		response.Measurements = append(response.Measurements, toMeasurement(event))
+	}
+
+	return &response, nil
+}
+
+func toFilter(filter *pacmonpb.Filter) streamhub.Filter {
+	converted := streamhub.Filter{Types: make(map[api.MeasurementType]bool), Devices: make(map[string]bool)}
+	for _, name := range filter.GetTypes() {
+		converted.Types[api.MeasurementType(name)] = true
+	}
+	for _, name := range filter.GetDevices() {
+		converted.Devices[name] = true
+	}
+	return converted
+}
+
+// toMeasurement converts an event, values which are not a JSON object being held by a value field
+func toMeasurement(event streamhub.Event) *pacmonpb.Measurement {
+	var value interface{}
+	json.Unmarshal(event.Value, &value)
+	values, ok := value.(map[string]interface{})
+	if !ok {
+		values = map[string]interface{}{"value": value}
+	}
+	converted, _ := structpb.NewStruct(values)
+
+	return &pacmonpb.Measurement{
+		Id:        event.ID,
+		Type:      string(event.Type),
+		Device:    event.Device,
+		Timestamp: event.Timestamp,
+		Value:     converted,
+		Tags:      event.Tags,
+	}
+}
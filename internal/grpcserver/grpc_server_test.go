@@ -0,0 +1,97 @@
+package grpcserver
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/renajohn/pac_collector/api"
+	"github.com/renajohn/pac_collector/api/pacmonpb"
+	"github.com/renajohn/pac_collector/internal/streamhub"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/proto"
+)
+
+func startServer(t *testing.T, hub *streamhub.Hub) pacmonpb.PacmonClient {
+	t.Helper()
+	listener := bufconn.Listen(1024 * 1024)
+	go NewServer(hub).serve(listener)
+
+	connection, err := grpc.NewClient("passthrough:///bufconn",
+		grpc.WithContextDialer(func(ctx context.Context, addr string) (net.Conn, error) { return listener.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		connection.Close()
+		listener.Close()
+	})
+
+	return pacmonpb.NewPacmonClient(connection)
+}
+
+func temperature(timestamp int64) api.Measurement {
+	return api.Measurement{
+		MeasurementType: api.SWCTemperature,
+		Device:          "swc",
+		Timestamp:       timestamp,
+		Value:           []byte(`{"TankTemperature":52.3,"Label":"tank"}`),
+		Tags:            map[string]string{"quality": "suspect"},
+	}
+}
+
+func TestSubscribe(t *testing.T) {
+	t.Run("Measurements are resumed, then streamed", func(t *testing.T) {
+		hub := streamhub.NewHub(10)
+		client := startServer(t, hub)
+		hub.Process(temperature(1))
+		hub.Process(api.Measurement{MeasurementType: api.SWCOutputs, Device: "swc", Timestamp: 2, Value: []byte(`{"Compressor":true}`)})
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		stream, err := client.Subscribe(ctx, &pacmonpb.SubscribeRequest{
+			Filter: &pacmonpb.Filter{Types: []string{"SWCTemperature"}},
+			LastId: proto.Uint64(0),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		measurement, err := stream.Recv()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if measurement.Id != 1 || measurement.Type != "SWCTemperature" || measurement.Value.Fields["TankTemperature"].GetNumberValue() != 52.3 ||
+			measurement.Value.Fields["Label"].GetStringValue() != "tank" || measurement.Tags["quality"] != "suspect" {
+			t.Errorf("Unexpected measurement %v", measurement)
+		}
+
+		hub.Process(temperature(3))
+		measurement, err = stream.Recv()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if measurement.Id != 3 || measurement.Timestamp != 3 {
+			t.Errorf("Unexpected measurement %v", measurement)
+		}
+	})
+}
+
+func TestGetLatest(t *testing.T) {
+	hub := streamhub.NewHub(10)
+	client := startServer(t, hub)
+	hub.Process(temperature(1))
+	hub.Process(temperature(2))
+
+	response, err := client.GetLatest(context.Background(), &pacmonpb.GetLatestRequest{Filter: &pacmonpb.Filter{Devices: []string{"swc"}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(response.Measurements) != 1 || response.Measurements[0].Timestamp != 2 {
+		t.Errorf("Expected the latest temperatures, got %v", response.Measurements)
+	}
+}
@@ -0,0 +1,198 @@
+package healthcheck
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/renajohn/pac_collector/internal/multisink"
+	"github.com/renajohn/pac_collector/internal/swcsource"
+)
+
+// Default thresholds
+const (
+	DefaultDisconnectedAfter = 5 * time.Minute
+	DefaultSinkFailingAfter  = time.Minute
+	staleIntervals           = 3 // polling intervals after which readings are stale when StaleAfter is not set
+)
+
+// Source reports the state of the session, implemented by swcsource.SWCSource
+type Source interface {
+	Status() swcsource.SessionStatus
+}
+
+// Sinks reports the outcome of the writes, implemented by multisink.MultiSink
+type Sinks interface {
+	Health() []multisink.SinkHealth
+}
+
+// Check is the outcome of a single check
+type Check struct {
+	Name    string `json:"name"`
+	Healthy bool   `json:"healthy"`
+	Message string `json:"message"`
+}
+
+// Report is the body of the /healthz and /readyz replies
+type Report struct {
+	Status string  `json:"status"` // ok or failing
+	Checks []Check `json:"checks"`
+}
+
+// Checker serves the liveness and readiness probes:
+//
+//	/healthz  fails when the session is down or the readings are stale, although the source keeps
+//	          reconnecting with a back-off, for an orchestrator to restart pacmon
+//	/readyz   additionally fails until a first reading is received and while a sink keeps failing
+type Checker struct {
+	StaleAfter        time.Duration // age after which the last reading is stale, three polling intervals when 0
+	DisconnectedAfter time.Duration // time the session may be down before pacmon is unhealthy
+	SinkFailingAfter  time.Duration // time a sink may fail before pacmon is not ready
+	Started           time.Time     // reference of the checks until a first reading or write, the creation of the checker by default
+
+	source Source
+	sinks  Sinks
+	now    func() time.Time
+}
+
+// NewChecker creates a new Checker, sinks being optional
+func NewChecker(source Source, sinks Sinks) *Checker {
+	return newCheckerWithClock(source, sinks, time.Now)
+}
+
+func newCheckerWithClock(source Source, sinks Sinks, now func() time.Time) *Checker {
+	return &Checker{
+		DisconnectedAfter: DefaultDisconnectedAfter,
+		SinkFailingAfter:  DefaultSinkFailingAfter,
+		source:            source,
+		sinks:             sinks,
+		Started:           now(),
+		now:               now,
+	}
+}
+
+// ServeHTTP replies 200 when healthy and 503 otherwise, paths ending in /readyz being readiness probes
+func (c *Checker) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var report Report
+	if strings.HasSuffix(r.URL.Path, "/readyz") {
+		report = c.Readiness()
+	} else {
+		report = c.Liveness()
+	}
+
+	code := http.StatusOK
+	if report.Status != "ok" {
+		code = http.StatusServiceUnavailable
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(report)
+}
+
+// Liveness checks the session and the age of the last reading
+func (c *Checker) Liveness() Report {
+	status := c.source.Status()
+	now := c.now()
+
+	return newReport(c.checkSession(status, now), c.checkReadings(status, now))
+}
+
+// Readiness checks the liveness, that a first reading was received and that the sinks can be written to
+func (c *Checker) Readiness() Report {
+	status := c.source.Status()
+	now := c.now()
+
+	checks := []Check{c.checkSession(status, now), c.checkReadings(status, now)}
+	if status.LastPoll == 0 {
+		checks[1] = Check{Name: "readings", Message: "no reading received yet"}
+	}
+	if c.sinks != nil {
+		for _, health := range c.sinks.Health() {
+			checks = append(checks, c.checkSink(health, now))
+		}
+	}
+
+	return newReport(checks...)
+}
+
+// checkSession fails when the session has been down for longer than DisconnectedAfter
+func (c *Checker) checkSession(status swcsource.SessionStatus, now time.Time) Check {
+	check := Check{Name: "session", Healthy: true, Message: status.State}
+	if status.State == swcsource.Connected || status.State == swcsource.Paused {
+		return check
+	}
+
+	// restarts failing in a row do not reset the downtime
+	down := c.Started
+	if status.DownSince > 0 {
+		down = time.Unix(status.DownSince, 0)
+	}
+	elapsed := now.Sub(down)
+	check.Message = fmt.Sprintf("%s for %s", status.State, elapsed.Round(time.Second))
+	if status.LastError != "" {
+		check.Message += ": " + status.LastError
+	}
+	check.Healthy = elapsed <= c.DisconnectedAfter
+
+	return check
+}
+
+// checkReadings fails when no reading was received for longer than the staleness threshold, unless polling is paused
+func (c *Checker) checkReadings(status swcsource.SessionStatus, now time.Time) Check {
+	check := Check{Name: "readings", Healthy: true}
+	if status.State == swcsource.Paused {
+		check.Message = "polling paused"
+		return check
+	}
+
+	staleAfter := c.StaleAfter
+	if staleAfter <= 0 {
+		staleAfter = staleIntervals * time.Duration(status.PollInterval) * time.Second
+	}
+
+	last := c.Started
+	if status.LastPoll > 0 {
+		last = time.Unix(status.LastPoll, 0)
+	}
+	age := now.Sub(last)
+	if status.LastPoll > 0 {
+		check.Message = fmt.Sprintf("last reading %s ago", age.Round(time.Second))
+	} else {
+		check.Message = fmt.Sprintf("no reading for %s", age.Round(time.Second))
+	}
+	check.Healthy = age <= staleAfter
+
+	return check
+}
+
+// checkSink fails when the writes have been failing for longer than SinkFailingAfter
+func (c *Checker) checkSink(health multisink.SinkHealth, now time.Time) Check {
+	check := Check{Name: "sink " + health.Name, Healthy: true, Message: "writable"}
+	if health.Healthy {
+		return check
+	}
+
+	failing := c.Started
+	if health.LastSuccess > 0 {
+		failing = time.Unix(health.LastSuccess, 0)
+	}
+	elapsed := now.Sub(failing)
+	check.Message = fmt.Sprintf("failing for %s: %s", elapsed.Round(time.Second), health.LastError)
+	check.Healthy = elapsed <= c.SinkFailingAfter
+
+	return check
+}
+
+func newReport(checks ...Check) Report {
+	report := Report{Status: "ok", Checks: checks}
+	for _, check := range checks {
+		if !check.Healthy {
+			report.Status = "failing"
+		}
+	}
+	return report
+}
@@ -0,0 +1,195 @@
+package healthcheck
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/renajohn/pac_collector/internal/multisink"
+	"github.com/renajohn/pac_collector/internal/swcsource"
+)
+
+type fakeSource struct {
+	status swcsource.SessionStatus
+}
+
+func (fs *fakeSource) Status() swcsource.SessionStatus {
+	return fs.status
+}
+
+type fakeSinks struct {
+	health []multisink.SinkHealth
+}
+
+func (fs *fakeSinks) Health() []multisink.SinkHealth {
+	return fs.health
+}
+
+type fakeClock struct {
+	time time.Time
+}
+
+func (fc *fakeClock) now() time.Time {
+	return fc.time
+}
+
+func probe(t *testing.T, checker *Checker, path string) (int, Report) {
+	t.Helper()
+	recorder := httptest.NewRecorder()
+	checker.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, path, nil))
+
+	var report Report
+	if err := json.Unmarshal(recorder.Body.Bytes(), &report); err != nil {
+		t.Fatalf("Failed to decode %s: %v", recorder.Body.String(), err)
+	}
+	return recorder.Code, report
+}
+
+func failing(report Report) []string {
+	var names []string
+	for _, check := range report.Checks {
+		if !check.Healthy {
+			names = append(names, check.Name)
+		}
+	}
+	return names
+}
+
+func TestChecker(t *testing.T) {
+	start := time.Unix(1700000000, 0)
+
+	setup := func() (*fakeSource, *fakeSinks, *fakeClock, *Checker) {
+		source := &fakeSource{status: swcsource.SessionStatus{State: swcsource.Connected, PollInterval: 60, LastPoll: start.Unix()}}
+		sinks := &fakeSinks{health: []multisink.SinkHealth{{Name: "influxsink", Healthy: true, LastSuccess: start.Unix()}}}
+		clock := &fakeClock{time: start}
+		return source, sinks, clock, newCheckerWithClock(source, sinks, clock.now)
+	}
+
+	t.Run("A connected session with fresh readings is healthy and ready", func(t *testing.T) {
+		_, _, clock, checker := setup()
+		clock.time = start.Add(30 * time.Second)
+
+		for _, path := range []string{"/healthz", "/readyz"} {
+			code, report := probe(t, checker, path)
+			if code != http.StatusOK || report.Status != "ok" {
+				t.Errorf("Expected %s to be ok, got %d %+v", path, code, report)
+			}
+		}
+	})
+
+	t.Run("Readings are stale after three polling intervals by default", func(t *testing.T) {
+		source, _, clock, checker := setup()
+
+		clock.time = start.Add(180 * time.Second)
+		if code, report := probe(t, checker, "/healthz"); code != http.StatusOK {
+			t.Errorf("Expected readings to be fresh, got %+v", report)
+		}
+
+		clock.time = start.Add(181 * time.Second)
+		code, report := probe(t, checker, "/healthz")
+		if code != http.StatusServiceUnavailable || report.Status != "failing" {
+			t.Errorf("Expected a 503, got %d %+v", code, report)
+		}
+		if names := failing(report); len(names) != 1 || names[0] != "readings" {
+			t.Errorf("Expected the readings check to fail, got %v", names)
+		}
+
+		// the threshold follows the polling interval changed at runtime
+		source.status.PollInterval = 120
+		if code, report := probe(t, checker, "/healthz"); code != http.StatusOK {
+			t.Errorf("Expected readings to be fresh, got %+v", report)
+		}
+	})
+
+	t.Run("The staleness threshold can be configured", func(t *testing.T) {
+		_, _, clock, checker := setup()
+		checker.StaleAfter = 90 * time.Second
+
+		clock.time = start.Add(91 * time.Second)
+		if code, _ := probe(t, checker, "/healthz"); code != http.StatusServiceUnavailable {
+			t.Errorf("Expected readings to be stale, got %d", code)
+		}
+	})
+
+	t.Run("Readings are not stale while polling is paused", func(t *testing.T) {
+		source, _, clock, checker := setup()
+		source.status.State = swcsource.Paused
+
+		clock.time = start.Add(time.Hour)
+		if code, report := probe(t, checker, "/readyz"); code != http.StatusOK {
+			t.Errorf("Expected a paused session to be ready, got %+v", report)
+		}
+	})
+
+	t.Run("A session down for longer than the threshold is unhealthy", func(t *testing.T) {
+		source, _, clock, checker := setup()
+		checker.StaleAfter = time.Hour
+		source.status.State = swcsource.Disconnected
+		source.status.LastError = "connection refused"
+		source.status.LastErrorTime = start.Add(time.Minute).Unix()
+		source.status.DownSince = start.Add(time.Minute).Unix()
+
+		clock.time = start.Add(6 * time.Minute)
+		if code, report := probe(t, checker, "/healthz"); code != http.StatusOK {
+			t.Errorf("Expected the session to be within its grace period, got %+v", report)
+		}
+
+		// the restarts failing in the meantime do not extend the grace period
+		source.status.LastErrorTime = start.Add(6 * time.Minute).Unix()
+		clock.time = start.Add(6*time.Minute + time.Second)
+		code, report := probe(t, checker, "/healthz")
+		if code != http.StatusServiceUnavailable {
+			t.Errorf("Expected a 503, got %d", code)
+		}
+		if names := failing(report); len(names) != 1 || names[0] != "session" {
+			t.Errorf("Expected the session check to fail, got %v", names)
+		}
+		if report.Checks[0].Message != "disconnected for 5m1s: connection refused" {
+			t.Errorf("Unexpected message %q", report.Checks[0].Message)
+		}
+	})
+
+	t.Run("pacmon is not ready until a first reading is received", func(t *testing.T) {
+		source, _, clock, checker := setup()
+		source.status = swcsource.SessionStatus{State: swcsource.Connecting, PollInterval: 60}
+		clock.time = start.Add(10 * time.Second)
+
+		if code, report := probe(t, checker, "/healthz"); code != http.StatusOK {
+			t.Errorf("Expected a starting session to be alive, got %+v", report)
+		}
+		code, report := probe(t, checker, "/readyz")
+		if code != http.StatusServiceUnavailable {
+			t.Errorf("Expected a 503, got %d", code)
+		}
+		if names := failing(report); len(names) != 1 || names[0] != "readings" {
+			t.Errorf("Expected the readings check to fail, got %v", names)
+		}
+	})
+
+	t.Run("A sink failing for longer than the threshold makes pacmon not ready", func(t *testing.T) {
+		_, sinks, clock, checker := setup()
+		sinks.health[0].Healthy = false
+		sinks.health[0].LastError = "timeout"
+
+		clock.time = start.Add(time.Minute)
+		if code, report := probe(t, checker, "/readyz"); code != http.StatusOK {
+			t.Errorf("Expected the sink to be within its grace period, got %+v", report)
+		}
+
+		clock.time = start.Add(time.Minute + time.Second)
+		code, report := probe(t, checker, "/readyz")
+		if code != http.StatusServiceUnavailable {
+			t.Errorf("Expected a 503, got %d", code)
+		}
+		if names := failing(report); len(names) != 1 || names[0] != "sink influxsink" {
+			t.Errorf("Expected the sink check to fail, got %v", names)
+		}
+
+		// liveness does not depend on the sinks, restarting would not help
+		if code, _ := probe(t, checker, "/healthz"); code != http.StatusOK {
+			t.Errorf("Expected pacmon to be alive, got %d", code)
+		}
+	})
+}
@@ -4,9 +4,12 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"sort"
 
 	"github.com/renajohn/pac_collector/api"
+	"github.com/renajohn/pac_collector/internal/tracing"
 	"github.com/segmentio/kafka-go"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 type kafkaWriter interface {
@@ -54,19 +57,48 @@ type KafkaSink struct {
 
 // Put statisfies the api.Sink interface
 func (ks *KafkaSink) Put(measurement api.Measurement) error {
+	ctx := measurement.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	return tracing.Run(ctx, "kafka.put", func(ctx context.Context) error { return ks.put(ctx, measurement) },
+		attribute.String("measurement.type", string(measurement.MeasurementType)))
+}
+
+func (ks *KafkaSink) put(ctx context.Context, measurement api.Measurement) error {
 	writer := ks.factory.NewWriter()
 	defer writer.Close()
 
 	log.Println(fmt.Sprintf("Sending message to Kafka - [%s]: %v", measurement.MeasurementType, string(measurement.Value)))
 
 	message := kafka.Message{
-		Key:   []byte(measurement.MeasurementType),
-		Value: measurement.Value,
+		Key:     []byte(measurement.MeasurementType),
+		Value:   measurement.Value,
+		Headers: toHeaders(measurement.Tags),
 	}
-	writeErr := writer.WriteMessages(context.Background(), message)
+	writeErr := writer.WriteMessages(ctx, message)
 	if writeErr != nil {
 		fmt.Printf("failed to send a message to Kafka: %g\n", writeErr)
 	}
 
 	return writeErr
 }
+
+// toHeaders maps the measurement tags to message headers, sorted by key
+func toHeaders(tags map[string]string) []kafka.Header {
+	if len(tags) == 0 {
+		return nil
+	}
+
+	keys := make([]string, 0, len(tags))
+	for key := range tags {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	headers := make([]kafka.Header, 0, len(keys))
+	for _, key := range keys {
+		headers = append(headers, kafka.Header{Key: key, Value: []byte(tags[key])})
+	}
+	return headers
+}
@@ -7,6 +7,10 @@ import (
 
 	"github.com/renajohn/pac_collector/api"
 	"github.com/segmentio/kafka-go"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
 )
 
 type mockWriter struct {
@@ -65,6 +69,39 @@ func TestPut(t *testing.T) {
 		}
 	})
 
+	t.Run("Writes are traced", func(t *testing.T) {
+		recorder := tracetest.NewSpanRecorder()
+		otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+		defer otel.SetTracerProvider(sdktrace.NewTracerProvider())
+		factory := mockWriterFactoryImpl{returnError: true}
+		sink := newKafkaSinkWithConnectionFactory(&factory)
+
+		sink.Put(api.Measurement{MeasurementType: api.SWCTemperature, Value: []byte("42")})
+
+		spans := recorder.Ended()
+		if len(spans) != 1 || spans[0].Name() != "kafka.put" || spans[0].Status().Code != codes.Error {
+			t.Errorf("Expected a failed kafka.put span, got %v", spans)
+		}
+	})
+
+	t.Run("Tags are sent as headers", func(t *testing.T) {
+		factory := mockWriterFactoryImpl{}
+		sink := newKafkaSinkWithConnectionFactory(&factory)
+		measure := api.Measurement{
+			MeasurementType: api.SWCTemperature,
+			Timestamp:       123456789,
+			Value:           []byte("42"),
+			Tags:            map[string]string{"quality": "suspect", "device": "swc"},
+		}
+
+		sink.Put(measure)
+
+		headers := factory.writer.messages[0].Headers
+		if len(headers) != 2 || headers[0].Key != "device" || string(headers[1].Value) != "suspect" {
+			t.Errorf("Expected sorted headers out of the tags, got %+v", headers)
+		}
+	})
+
 	t.Run("If connection returns an error, propagate error", func(t *testing.T) {
 		factory := mockWriterFactoryImpl{
 			returnError: true,
@@ -0,0 +1,59 @@
+package models
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/renajohn/pac_collector/api"
+	"github.com/renajohn/pac_collector/internal/alerting"
+	"github.com/renajohn/pac_collector/internal/copprocessor"
+	"github.com/renajohn/pac_collector/internal/cycleprocessor"
+	"github.com/renajohn/pac_collector/internal/derivedprocessor"
+	"github.com/renajohn/pac_collector/internal/powermetersource"
+	"github.com/renajohn/pac_collector/internal/qualityprocessor"
+	"github.com/renajohn/pac_collector/internal/swcsource"
+)
+
+// Models are the typed values of the measurement types, from which the sinks with a fixed layout,
+// e.g. Parquet files, derive their columns.
+var Models = map[api.MeasurementType]interface{}{
+	api.SWCTemperature:  swcsource.SWCMeasurement{},
+	api.SWCHeatQuantity: swcsource.SWCHeatQuantity{},
+	api.SWCOutputs:      swcsource.SWCOutputs{},
+	api.SWCStatus:       swcsource.SWCStatus{},
+	api.SWCFault:        swcsource.SWCFault{},
+	api.SWCDerived:      derivedprocessor.DerivedMeasurement{},
+	api.ElectricalPower: powermetersource.PowerMeterMeasurement{},
+	api.COP:             copprocessor.COPMeasurement{},
+	api.CompressorCycle: cycleprocessor.CycleEvent{},
+	api.ShortCycling:    cycleprocessor.ShortCyclingWarning{},
+	api.Anomaly:         qualityprocessor.AnomalyEvent{},
+	api.Alert:           alerting.Alert{},
+}
+
+// Fields returns the sorted JSON names of the fields of a measurement type, whether or not they are
+// omitted when empty, and false when the type has no model
+func Fields(measurementType api.MeasurementType) ([]string, bool) {
+	model, found := Models[measurementType]
+	if !found {
+		return nil, false
+	}
+
+	modelType := reflect.TypeOf(model)
+	var names []string
+	for index := 0; index < modelType.NumField(); index++ {
+		field := modelType.Field(index)
+		name := strings.Split(field.Tag.Get("json"), ",")[0]
+		if field.PkgPath != "" || name == "-" {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names, true
+}
@@ -0,0 +1,25 @@
+package models
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/renajohn/pac_collector/api"
+)
+
+func TestFields(t *testing.T) {
+	t.Run("Fields omitted when empty are listed too", func(t *testing.T) {
+		names, found := Fields(api.CompressorCycle)
+
+		expected := []string{"Event", "OffDuration", "RunDuration", "StartsPerHour"}
+		if !found || !reflect.DeepEqual(expected, names) {
+			t.Errorf("Expected %v got %v", expected, names)
+		}
+	})
+
+	t.Run("Types without a model have no fields", func(t *testing.T) {
+		if names, found := Fields("Custom"); found || names != nil {
+			t.Errorf("Expected no fields, got %v", names)
+		}
+	})
+}
@@ -0,0 +1,201 @@
+package mqttsink
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/eclipse/paho.mqtt.golang/packets"
+	"github.com/renajohn/pac_collector/api"
+)
+
+type publication struct {
+	payload  string
+	retained bool
+}
+
+// fakeBroker is a minimal MQTT broker acknowledging connections and publications
+type fakeBroker struct {
+	listener net.Listener
+
+	mutex     sync.Mutex
+	connect   *packets.ConnectPacket
+	published map[string][]publication
+}
+
+func startBroker(t *testing.T) *fakeBroker {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	broker := fakeBroker{listener: listener, published: make(map[string][]publication)}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go broker.serve(conn)
+		}
+	}()
+	t.Cleanup(func() { listener.Close() })
+
+	return &broker
+}
+
+func (fb *fakeBroker) serve(conn net.Conn) {
+	defer conn.Close()
+
+	for {
+		received, err := packets.ReadPacket(conn)
+		if err != nil {
+			return
+		}
+
+		switch packet := received.(type) {
+		case *packets.ConnectPacket:
+			fb.mutex.Lock()
+			fb.connect = packet
+			fb.mutex.Unlock()
+			packets.NewControlPacket(packets.Connack).Write(conn)
+		case *packets.PublishPacket:
+			fb.mutex.Lock()
+			fb.published[packet.TopicName] = append(fb.published[packet.TopicName], publication{string(packet.Payload), packet.Retain})
+			fb.mutex.Unlock()
+			if packet.Qos == 1 {
+				ack := packets.NewControlPacket(packets.Puback).(*packets.PubackPacket)
+				ack.MessageID = packet.MessageID
+				ack.Write(conn)
+			}
+		case *packets.PingreqPacket:
+			packets.NewControlPacket(packets.Pingresp).Write(conn)
+		case *packets.DisconnectPacket:
+			return
+		}
+	}
+}
+
+func (fb *fakeBroker) publications(topic string) []publication {
+	fb.mutex.Lock()
+	defer fb.mutex.Unlock()
+	return fb.published[topic]
+}
+
+func (fb *fakeBroker) waitFor(t *testing.T, topic string, payload string) {
+	t.Helper()
+	for deadline := time.Now().Add(2 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		for _, published := range fb.publications(topic) {
+			if published.payload == payload {
+				return
+			}
+		}
+	}
+	t.Fatalf("Expected %q to be published on %s, got %v", payload, topic, fb.publications(topic))
+}
+
+func TestNewMQTTSink(t *testing.T) {
+	t.Run("Options are read from the URL", func(t *testing.T) {
+		sink, err := NewMQTTSink("mqtt://localhost:1883?qos=2&retain=false&prefix=heating&discovery=")
+
+		if err != nil {
+			t.Fatalf("No error was expected but got %v", err)
+		}
+		if sink.QoS != 2 || sink.Retained || sink.TopicPrefix != "heating" || sink.DiscoveryPrefix != "" {
+			t.Errorf("Unexpected options %+v", sink)
+		}
+	})
+
+	t.Run("should error out when QoS is invalid", func(t *testing.T) {
+		if _, err := NewMQTTSink("mqtt://localhost:1883?qos=3"); err == nil {
+			t.Error("An error was expected and none was returned")
+		}
+	})
+}
+
+func TestPut(t *testing.T) {
+	t.Run("Fields are published and announced to Home Assistant", func(t *testing.T) {
+		broker := startBroker(t)
+		sink, err := NewMQTTSink(fmt.Sprintf("mqtt://user:secret@%s?qos=1", broker.listener.Addr()))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for i := 0; i < 2; i++ {
+			err = sink.Put(api.Measurement{
+				MeasurementType: api.SWCTemperature,
+				Device:          "basement",
+				Timestamp:       42,
+				Value:           []byte(`{"TankTemperature":52.3}`),
+			})
+			if err != nil {
+				t.Fatalf("No error was expected but got %v", err)
+			}
+		}
+		broker.waitFor(t, "pacmon/status", "online")
+		sink.Close()
+
+		broker.mutex.Lock()
+		connect := broker.connect
+		broker.mutex.Unlock()
+		if connect.Username != "user" || string(connect.Password) != "secret" || connect.WillTopic != "pacmon/status" || string(connect.WillMessage) != "offline" || !connect.WillRetain {
+			t.Errorf("Unexpected connection %v", connect)
+		}
+
+		state := broker.publications("pacmon/basement/SWCTemperature/TankTemperature")
+		if len(state) != 2 || state[0] != (publication{"52.3", true}) {
+			t.Errorf("Expected the field state to be published, got %v", state)
+		}
+		record := broker.publications("pacmon/basement/SWCTemperature")
+		if len(record) != 2 || record[0].payload != `{"TankTemperature":52.3}` {
+			t.Errorf("Expected the whole record to be published, got %v", record)
+		}
+
+		discovery := broker.publications("homeassistant/sensor/pacmon_basement/SWCTemperature_TankTemperature/config")
+		if len(discovery) != 1 || !discovery[0].retained {
+			t.Fatalf("Expected a single retained discovery config, got %v", discovery)
+		}
+		var config discoveryConfig
+		json.Unmarshal([]byte(discovery[0].payload), &config)
+		expected := discoveryConfig{
+			Name:              "TankTemperature",
+			UniqueID:          "pacmon_basement_SWCTemperature_TankTemperature",
+			StateTopic:        "pacmon/basement/SWCTemperature/TankTemperature",
+			AvailabilityTopic: "pacmon/status",
+			UnitOfMeasurement: "°C",
+			DeviceClass:       "temperature",
+			StateClass:        "measurement",
+			Device:            discoveryDevice{Identifiers: []string{"pacmon_basement"}, Name: "basement", Model: "SWC"},
+		}
+		if fmt.Sprint(expected) != fmt.Sprint(config) {
+			t.Errorf("Expected %+v got %+v", expected, config)
+		}
+
+		broker.waitFor(t, "pacmon/status", "offline")
+	})
+
+	t.Run("Booleans are announced as binary sensors and events are not split", func(t *testing.T) {
+		broker := startBroker(t)
+		sink, _ := NewMQTTSink(fmt.Sprintf("mqtt://%s?qos=1", broker.listener.Addr()))
+		defer sink.Close()
+
+		sink.Put(api.Measurement{MeasurementType: api.SWCOutputs, Device: "swc", Value: []byte(`{"Compressor":true}`)})
+		sink.Put(api.Measurement{MeasurementType: api.Alert, Device: "swc", Value: []byte(`{"Rule":"tank","State":"firing"}`)})
+
+		if states := broker.publications("pacmon/swc/SWCOutputs/Compressor"); len(states) != 1 || states[0].payload != "true" {
+			t.Errorf("Expected the compressor state to be published, got %v", states)
+		}
+		if configs := broker.publications("homeassistant/binary_sensor/pacmon_swc/SWCOutputs_Compressor/config"); len(configs) != 1 {
+			t.Errorf("Expected a binary sensor to be announced, got %v", configs)
+		}
+		if alerts := broker.publications("pacmon/swc/Alert"); len(alerts) != 1 || alerts[0].retained {
+			t.Errorf("Expected the alert to be published as a whole without retention, got %v", alerts)
+		}
+		if fields := broker.publications("pacmon/swc/Alert/State"); len(fields) != 0 {
+			t.Errorf("Expected events not to be split by field, got %v", fields)
+		}
+	})
+}
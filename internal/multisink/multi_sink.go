@@ -0,0 +1,155 @@
+package multisink
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/renajohn/pac_collector/api"
+)
+
+// MultiSink forwards every measurement to several sinks. A failing sink does not prevent the
+// others from receiving the measurement.
+type MultiSink struct {
+	Sinks []api.Sink // replaced with Replace once measurements are being put
+
+	putting sync.RWMutex // read locked by the ongoing Puts, so that Replace can wait for them
+	mutex   sync.Mutex
+	health  map[api.Sink]*SinkHealth
+}
+
+// SinkHealth reports the outcome of the writes to a sink, times being unix seconds
+type SinkHealth struct {
+	Name          string `json:"name"`
+	Healthy       bool   `json:"healthy"` // no write failed since the last successful one
+	Writes        int64  `json:"writes"`
+	Errors        int64  `json:"errors"`
+	LastSuccess   int64  `json:"lastSuccess,omitempty"`
+	LastError     string `json:"lastError,omitempty"`
+	LastErrorTime int64  `json:"lastErrorTime,omitempty"`
+}
+
+// NewMultiSink creates a new MultiSink
+func NewMultiSink(sinks ...api.Sink) *MultiSink {
+	sink := MultiSink{
+		Sinks: sinks,
+	}
+
+	return &sink
+}
+
+// Put satisfies the api.Sink interface
+func (ms *MultiSink) Put(m api.Measurement) error {
+	ms.putting.RLock()
+	defer ms.putting.RUnlock()
+
+	var errs []error
+	for _, sink := range ms.current() {
+		err := sink.Put(m)
+		ms.record(sink, err)
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return combine(errs)
+}
+
+// Replace swaps the sinks, the health of the sinks which are kept being preserved.
+// It returns once the ongoing Puts are over, so the removed sinks can then be closed.
+func (ms *MultiSink) Replace(sinks ...api.Sink) {
+	ms.putting.Lock()
+	defer ms.putting.Unlock()
+	ms.mutex.Lock()
+	defer ms.mutex.Unlock()
+
+	kept := make(map[api.Sink]*SinkHealth, len(sinks))
+	for _, sink := range sinks {
+		if health, found := ms.health[sink]; found {
+			kept[sink] = health
+		}
+	}
+	ms.Sinks = sinks
+	ms.health = kept
+}
+
+func (ms *MultiSink) current() []api.Sink {
+	ms.mutex.Lock()
+	defer ms.mutex.Unlock()
+
+	return ms.Sinks
+}
+
+func (ms *MultiSink) record(sink api.Sink, err error) {
+	ms.mutex.Lock()
+	defer ms.mutex.Unlock()
+
+	health := ms.sinkHealth(sink)
+	now := time.Now().Unix()
+	if err != nil {
+		health.Errors++
+		health.LastError = err.Error()
+		health.LastErrorTime = now
+		health.Healthy = false
+		return
+	}
+	health.Writes++
+	health.LastSuccess = now
+	health.Healthy = true
+}
+
+func (ms *MultiSink) sinkHealth(sink api.Sink) *SinkHealth {
+	if ms.health == nil {
+		ms.health = make(map[api.Sink]*SinkHealth)
+	}
+	health, found := ms.health[sink]
+	if !found {
+		name := strings.TrimPrefix(fmt.Sprintf("%T", sink), "*")
+		health = &SinkHealth{Name: strings.SplitN(name, ".", 2)[0], Healthy: true}
+		ms.health[sink] = health
+	}
+	return health
+}
+
+// Health returns the health of every sink, named after their package, e.g. influxsink
+func (ms *MultiSink) Health() []SinkHealth {
+	ms.mutex.Lock()
+	defer ms.mutex.Unlock()
+
+	health := make([]SinkHealth, len(ms.Sinks))
+	for index, sink := range ms.Sinks {
+		health[index] = *ms.sinkHealth(sink)
+	}
+	return health
+}
+
+// Close closes the sinks implementing io.Closer
+func (ms *MultiSink) Close() error {
+	var errs []error
+	for _, sink := range ms.current() {
+		if closer, ok := sink.(io.Closer); ok {
+			if err := closer.Close(); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+
+	return combine(errs)
+}
+
+func combine(errs []error) error {
+	switch len(errs) {
+	case 0:
+		return nil
+	case 1:
+		return errs[0]
+	}
+
+	messages := make([]string, 0, len(errs))
+	for _, err := range errs {
+		messages = append(messages, err.Error())
+	}
+	return fmt.Errorf("%d sinks failed: %s", len(errs), strings.Join(messages, "; "))
+}
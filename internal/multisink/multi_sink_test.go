@@ -0,0 +1,127 @@
+package multisink
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/renajohn/pac_collector/api"
+	"github.com/renajohn/pac_collector/internal/mocksink"
+)
+
+type failingSink struct {
+	closed bool
+}
+
+func (fs *failingSink) Put(m api.Measurement) error {
+	return errors.New("boom")
+}
+
+func (fs *failingSink) Close() error {
+	fs.closed = true
+	return nil
+}
+
+type blockingSink struct {
+	entered chan struct{}
+	release chan struct{}
+}
+
+func (bs *blockingSink) Put(m api.Measurement) error {
+	close(bs.entered)
+	<-bs.release
+	return nil
+}
+
+func TestPut(t *testing.T) {
+	t.Run("Measurements are forwarded to every sink", func(t *testing.T) {
+		first, second := &mocksink.MockSink{}, &mocksink.MockSink{}
+		sink := NewMultiSink(first, second)
+
+		err := sink.Put(api.Measurement{MeasurementType: api.SWCTemperature})
+
+		if err != nil || len(first.Values) != 1 || len(second.Values) != 1 {
+			t.Errorf("Expected both sinks to receive the measurement, got %v, %v and %v", err, first.Values, second.Values)
+		}
+	})
+
+	t.Run("A failing sink does not prevent the others from receiving the measurement", func(t *testing.T) {
+		working := &mocksink.MockSink{}
+		sink := NewMultiSink(&failingSink{}, working)
+
+		err := sink.Put(api.Measurement{MeasurementType: api.SWCTemperature})
+
+		if err == nil || len(working.Values) != 1 {
+			t.Errorf("Expected an error and the measurement to be forwarded, got %v and %v", err, working.Values)
+		}
+	})
+}
+
+func TestClose(t *testing.T) {
+	closer := &failingSink{}
+	sink := NewMultiSink(&mocksink.MockSink{}, closer)
+
+	if err := sink.Close(); err != nil || !closer.closed {
+		t.Errorf("Expected closable sinks to be closed, got %v", err)
+	}
+}
+
+func TestHealth(t *testing.T) {
+	sink := NewMultiSink(&mocksink.MockSink{}, &failingSink{})
+	sink.Put(api.Measurement{MeasurementType: api.SWCTemperature})
+
+	health := sink.Health()
+	if len(health) != 2 || health[0].Name != "mocksink" || !health[0].Healthy || health[0].Writes != 1 || health[0].LastSuccess == 0 {
+		t.Errorf("Expected the mock sink to be healthy, got %+v", health)
+	}
+	if health[1].Name != "multisink" || health[1].Healthy || health[1].Errors != 1 || health[1].LastError != "boom" {
+		t.Errorf("Expected the failing sink to be unhealthy, got %+v", health)
+	}
+}
+
+func TestReplace(t *testing.T) {
+	kept, removed, added := &mocksink.MockSink{}, &failingSink{}, &mocksink.MockSink{}
+	sink := NewMultiSink(kept, removed)
+	sink.Put(api.Measurement{MeasurementType: api.SWCTemperature, Timestamp: 1})
+
+	sink.Replace(added, kept)
+	sink.Put(api.Measurement{MeasurementType: api.SWCTemperature, Timestamp: 2})
+
+	if len(kept.Values) != 2 || len(added.Values) != 1 || added.Values[0].Timestamp != 2 {
+		t.Errorf("Expected the measurements to go to the current sinks, got %v and %v", kept.Values, added.Values)
+	}
+	if removed.closed {
+		t.Error("Expected the removed sink to be left open")
+	}
+
+	health := sink.Health()
+	if len(health) != 2 || health[0].Writes != 1 || health[1].Writes != 2 {
+		t.Errorf("Expected the health of the kept sink to be preserved, got %+v", health)
+	}
+}
+
+func TestReplaceWaitsForPuts(t *testing.T) {
+	blocking := &blockingSink{entered: make(chan struct{}), release: make(chan struct{})}
+	sink := NewMultiSink(blocking)
+	go sink.Put(api.Measurement{MeasurementType: api.SWCTemperature, Timestamp: 1})
+	<-blocking.entered
+
+	replaced := make(chan struct{})
+	go func() {
+		sink.Replace(&mocksink.MockSink{})
+		close(replaced)
+	}()
+
+	select {
+	case <-replaced:
+		t.Fatal("Expected Replace to wait for the ongoing Put")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(blocking.release)
+	select {
+	case <-replaced:
+	case <-time.After(time.Second):
+		t.Fatal("Expected Replace to return once the Put is over")
+	}
+}
@@ -0,0 +1,49 @@
+package multisource
+
+import (
+	"sync"
+
+	"github.com/renajohn/pac_collector/api"
+)
+
+// MultiSource merges the measurements of several sources into a single channel
+type MultiSource struct {
+	Sources []api.Source
+
+	measurementsChannel chan api.Measurement
+}
+
+// NewMultiSource creates a new MultiSource
+func NewMultiSource(sources ...api.Source) *MultiSource {
+	source := MultiSource{
+		Sources:             sources,
+		measurementsChannel: make(chan api.Measurement, 10),
+	}
+
+	return &source
+}
+
+// MeasurementsChannel satisfies the api.Source interface
+func (ms *MultiSource) MeasurementsChannel() <-chan api.Measurement {
+	return ms.measurementsChannel
+}
+
+// Start satisfies the api.Source interface. The merged channel is closed once every source channel is closed.
+func (ms *MultiSource) Start() {
+	var wg sync.WaitGroup
+
+	for _, source := range ms.Sources {
+		go source.Start()
+
+		wg.Add(1)
+		go func(source api.Source) {
+			defer wg.Done()
+			for measurement := range source.MeasurementsChannel() {
+				ms.measurementsChannel <- measurement
+			}
+		}(source)
+	}
+
+	wg.Wait()
+	close(ms.measurementsChannel)
+}
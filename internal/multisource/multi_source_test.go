@@ -0,0 +1,47 @@
+package multisource
+
+import (
+	"sort"
+	"testing"
+
+	"github.com/renajohn/pac_collector/api"
+)
+
+type MockSource struct {
+	measurementsChannel chan api.Measurement
+}
+
+func (ms *MockSource) Start() {
+}
+
+func (ms *MockSource) MeasurementsChannel() <-chan api.Measurement {
+	return ms.measurementsChannel
+}
+
+func makeSource(timestamps ...int64) *MockSource {
+	source := MockSource{make(chan api.Measurement, len(timestamps))}
+	for _, timestamp := range timestamps {
+		source.measurementsChannel <- api.Measurement{MeasurementType: api.SWCTemperature, Timestamp: timestamp}
+	}
+	close(source.measurementsChannel)
+
+	return &source
+}
+
+func TestStart(t *testing.T) {
+	t.Run("Measurements of all sources are merged", func(t *testing.T) {
+		source := NewMultiSource(makeSource(1, 2), makeSource(3))
+
+		go source.Start()
+
+		var timestamps []int
+		for measurement := range source.MeasurementsChannel() {
+			timestamps = append(timestamps, int(measurement.Timestamp))
+		}
+		sort.Ints(timestamps)
+
+		if len(timestamps) != 3 || timestamps[0] != 1 || timestamps[2] != 3 {
+			t.Errorf("Expected the measurements of both sources, got %v", timestamps)
+		}
+	})
+}
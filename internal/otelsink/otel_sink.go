@@ -0,0 +1,156 @@
+package otelsink
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/renajohn/pac_collector/api"
+	"github.com/renajohn/pac_collector/internal/fields"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetricgrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetrichttp"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/metric"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// DefaultExportInterval is the interval at which the gauges are exported
+const DefaultExportInterval = time.Minute
+
+// units maps the field units to UCUM, as expected by OpenTelemetry
+var units = map[string]string{
+	"°C":  "Cel",
+	"W":   "W",
+	"kW":  "kW",
+	"kWh": "kWh",
+}
+
+// OTelSink exports the numeric fields of the measurements as OpenTelemetry gauges. Its TracerProvider
+// exports spans to the same endpoint, e.g. once installed with tracing.SetTracerProvider.
+type OTelSink struct {
+	meterProvider  *sdkmetric.MeterProvider
+	tracerProvider *sdktrace.TracerProvider
+	meter          metric.Meter
+
+	mutex  sync.Mutex
+	gauges map[string]metric.Float64Gauge
+}
+
+// NewOTelSink creates a new sink exporting to an OTLP endpoint, either gRPC (grpc://collector:4317,
+// grpcs:// for TLS) or HTTP (http://collector:4318, https://). The attributes, e.g. device and site,
+// describe the resource.
+func NewOTelSink(endpoint string, attributes map[string]string) (*OTelSink, error) {
+	parsed, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx := context.Background()
+	var metricExporter sdkmetric.Exporter
+	var spanExporter sdktrace.SpanExporter
+	switch parsed.Scheme {
+	case "grpc", "grpcs":
+		metricOptions := []otlpmetricgrpc.Option{otlpmetricgrpc.WithEndpoint(parsed.Host)}
+		traceOptions := []otlptracegrpc.Option{otlptracegrpc.WithEndpoint(parsed.Host)}
+		if parsed.Scheme == "grpc" {
+			metricOptions = append(metricOptions, otlpmetricgrpc.WithInsecure())
+			traceOptions = append(traceOptions, otlptracegrpc.WithInsecure())
+		}
+		metricExporter, err = otlpmetricgrpc.New(ctx, metricOptions...)
+		if err == nil {
+			spanExporter, err = otlptracegrpc.New(ctx, traceOptions...)
+		}
+	case "http", "https":
+		metricOptions := []otlpmetrichttp.Option{otlpmetrichttp.WithEndpoint(parsed.Host)}
+		traceOptions := []otlptracehttp.Option{otlptracehttp.WithEndpoint(parsed.Host)}
+		if parsed.Scheme == "http" {
+			metricOptions = append(metricOptions, otlpmetrichttp.WithInsecure())
+			traceOptions = append(traceOptions, otlptracehttp.WithInsecure())
+		}
+		metricExporter, err = otlpmetrichttp.New(ctx, metricOptions...)
+		if err == nil {
+			spanExporter, err = otlptracehttp.New(ctx, traceOptions...)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported OTLP endpoint %q, expecting grpc://, grpcs://, http:// or https://", endpoint)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	reader := sdkmetric.NewPeriodicReader(metricExporter, sdkmetric.WithInterval(DefaultExportInterval))
+	return newOTelSinkWithReader(reader, sdktrace.NewBatchSpanProcessor(spanExporter), attributes), nil
+}
+
+func newOTelSinkWithReader(reader sdkmetric.Reader, spanProcessor sdktrace.SpanProcessor, attributes map[string]string) *OTelSink {
+	resourceAttributes := []attribute.KeyValue{attribute.String("service.name", "pacmon")}
+	for key, value := range attributes {
+		resourceAttributes = append(resourceAttributes, attribute.String(key, value))
+	}
+	res := resource.NewSchemaless(resourceAttributes...)
+
+	meterProvider := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader), sdkmetric.WithResource(res))
+	sink := OTelSink{
+		meterProvider:  meterProvider,
+		tracerProvider: sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(spanProcessor), sdktrace.WithResource(res)),
+		meter:          meterProvider.Meter("github.com/renajohn/pac_collector"),
+		gauges:         make(map[string]metric.Float64Gauge),
+	}
+
+	return &sink
+}
+
+// Put satisfies the api.Sink interface. Measurements without numeric fields are ignored.
+func (ots *OTelSink) Put(m api.Measurement) error {
+	values, err := fields.Numeric(m)
+	if err != nil {
+		return nil
+	}
+
+	for _, field := range fields.Names(values) {
+		gauge, err := ots.gauge(fmt.Sprintf("pacmon.%s.%s", m.MeasurementType, field), units[fields.Unit(field)])
+		if err != nil {
+			return err
+		}
+		gauge.Record(context.Background(), values[field], metric.WithAttributes(attribute.String("device", m.Device)))
+	}
+
+	return nil
+}
+
+// TracerProvider returns the provider exporting spans to the endpoint of the sink
+func (ots *OTelSink) TracerProvider() trace.TracerProvider {
+	return ots.tracerProvider
+}
+
+// Close exports the pending metrics and spans
+func (ots *OTelSink) Close() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	return errors.Join(ots.meterProvider.Shutdown(ctx), ots.tracerProvider.Shutdown(ctx))
+}
+
+func (ots *OTelSink) gauge(name string, unit string) (metric.Float64Gauge, error) {
+	ots.mutex.Lock()
+	defer ots.mutex.Unlock()
+
+	if gauge, found := ots.gauges[name]; found {
+		return gauge, nil
+	}
+
+	gauge, err := ots.meter.Float64Gauge(name, metric.WithUnit(unit))
+	if err != nil {
+		return nil, err
+	}
+	ots.gauges[name] = gauge
+	return gauge, nil
+}
@@ -0,0 +1,58 @@
+package otelsink
+
+import (
+	"context"
+	"testing"
+
+	"github.com/renajohn/pac_collector/api"
+	"go.opentelemetry.io/otel/attribute"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestNewOTelSink(t *testing.T) {
+	t.Run("should error out when the protocol is unknown", func(t *testing.T) {
+		if _, err := NewOTelSink("udp://collector:4317", nil); err == nil {
+			t.Error("An error was expected and none was returned")
+		}
+	})
+
+	t.Run("Both gRPC and HTTP endpoints are supported", func(t *testing.T) {
+		for _, endpoint := range []string{"grpc://localhost:4317", "https://localhost:4318"} {
+			if _, err := NewOTelSink(endpoint, nil); err != nil {
+				t.Errorf("No error was expected for %s but got %v", endpoint, err)
+			}
+		}
+	})
+}
+
+func TestPut(t *testing.T) {
+	reader := sdkmetric.NewManualReader()
+	sink := newOTelSinkWithReader(reader, sdktrace.NewSimpleSpanProcessor(tracetest.NewInMemoryExporter()), map[string]string{"site": "home"})
+
+	sink.Put(api.Measurement{MeasurementType: api.SWCTemperature, Device: "swc", Value: []byte(`{"TankTemperature":52.3,"Label":"foo"}`)})
+	sink.Put(api.Measurement{MeasurementType: api.SWCTemperature, Device: "swc", Value: []byte(`{"TankTemperature":52.5}`)})
+	sink.Put(api.Measurement{MeasurementType: api.Alert, Value: []byte(`"not an object"`)})
+
+	var collected metricdata.ResourceMetrics
+	if err := reader.Collect(context.Background(), &collected); err != nil {
+		t.Fatal(err)
+	}
+
+	if site, _ := collected.Resource.Set().Value("site"); site.AsString() != "home" {
+		t.Errorf("Expected the site resource attribute, got %v", collected.Resource)
+	}
+	metrics := collected.ScopeMetrics[0].Metrics
+	if len(metrics) != 1 || metrics[0].Name != "pacmon.SWCTemperature.TankTemperature" || metrics[0].Unit != "Cel" {
+		t.Fatalf("Expected a single temperature gauge, got %+v", metrics)
+	}
+	points := metrics[0].Data.(metricdata.Gauge[float64]).DataPoints
+	if len(points) != 1 || points[0].Value != 52.5 || !points[0].Attributes.HasValue("device") {
+		t.Errorf("Expected the latest value of the device, got %+v", points)
+	}
+	if device, _ := points[0].Attributes.Value(attribute.Key("device")); device.AsString() != "swc" {
+		t.Errorf("Expected device attribute swc, got %v", device)
+	}
+}
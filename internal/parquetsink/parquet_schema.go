@@ -0,0 +1,69 @@
+package parquetsink
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+
+	"github.com/parquet-go/parquet-go"
+	"github.com/renajohn/pac_collector/api"
+	"github.com/renajohn/pac_collector/internal/models"
+)
+
+// genericModel holds the value of the measurement types without a model, as a JSON column
+type genericModel struct {
+	Value string `parquet:"value,json"`
+}
+
+// rowType is a Parquet row layout: the measurement time and device followed by the fields of the model
+type rowType struct {
+	model  reflect.Type
+	row    reflect.Type
+	schema *parquet.Schema
+}
+
+func newRowType(measurementType api.MeasurementType) *rowType {
+	model := reflect.TypeOf(genericModel{})
+	if value, found := models.Models[measurementType]; found {
+		model = reflect.TypeOf(value)
+	}
+
+	columns := []reflect.StructField{
+		{Name: "MeasurementTimestamp", Type: reflect.TypeOf(int64(0)), Tag: `parquet:"timestamp,timestamp(millisecond)"`},
+		{Name: "MeasurementDevice", Type: reflect.TypeOf(""), Tag: `parquet:"device,dict"`},
+	}
+	for index := 0; index < model.NumField(); index++ {
+		field := model.Field(index)
+		// the measurement timestamp and device already have their columns, query engines being case insensitive
+		if field.PkgPath != "" || strings.EqualFold(field.Name, "timestamp") || strings.EqualFold(field.Name, "device") {
+			continue
+		}
+		columns = append(columns, reflect.StructField{Name: field.Name, Type: field.Type, Tag: field.Tag})
+	}
+
+	row := reflect.StructOf(columns)
+	return &rowType{
+		model:  model,
+		row:    row,
+		schema: parquet.SchemaOf(reflect.New(row).Interface()),
+	}
+}
+
+// newRow converts a measurement to a row
+func (rt *rowType) newRow(m api.Measurement) (interface{}, error) {
+	model := reflect.New(rt.model)
+	if rt.model == reflect.TypeOf(genericModel{}) {
+		model.Elem().Field(0).SetString(string(m.Value))
+	} else if err := json.Unmarshal(m.Value, model.Interface()); err != nil {
+		return nil, err
+	}
+
+	row := reflect.New(rt.row).Elem()
+	row.Field(0).SetInt(m.Timestamp * 1000)
+	row.Field(1).SetString(m.Device)
+	for index := 2; index < rt.row.NumField(); index++ {
+		row.Field(index).Set(model.Elem().FieldByName(rt.row.Field(index).Name))
+	}
+
+	return row.Addr().Interface(), nil
+}
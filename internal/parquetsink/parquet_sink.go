@@ -0,0 +1,219 @@
+package parquetsink
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/parquet-go/parquet-go"
+	"github.com/renajohn/pac_collector/api"
+)
+
+// Defaults of the buffering and rotation options
+const (
+	DefaultRowGroupSize   = 10000
+	DefaultRotateInterval = 24 * time.Hour
+)
+
+// ParquetSink archives measurements as Parquet files partitioned Hive-style, e.g.
+// <root>/SWCTemperature/device=swc/date=2020-09-13/part-20200913T120000.parquet.
+// Files are written under a hidden name and only renamed once finalized, so that query engines never read partial files.
+// The files left in progress by a previous run are recovered with the first Put: finalized ones are renamed,
+// unreadable ones, which were interrupted before their footer was written, are deleted.
+type ParquetSink struct {
+	Root           string
+	RowGroupSize   int64         // rows buffered before a row group is written
+	RotateInterval time.Duration // age after which a file is finalized, files being also finalized when the date changes
+
+	now       func() time.Time
+	mutex     sync.Mutex
+	recovered bool
+	rows      map[api.MeasurementType]*rowType
+	files     map[fileKey]*partFile
+}
+
+type fileKey struct {
+	measurementType api.MeasurementType
+	device          string
+}
+
+type partFile struct {
+	date   string
+	path   string
+	file   *os.File
+	writer *parquet.Writer
+	opened time.Time
+}
+
+// NewParquetSink creates a new sink archiving under root
+func NewParquetSink(root string) *ParquetSink {
+	sink := ParquetSink{
+		Root:           root,
+		RowGroupSize:   DefaultRowGroupSize,
+		RotateInterval: DefaultRotateInterval,
+		now:            time.Now,
+		rows:           make(map[api.MeasurementType]*rowType),
+		files:          make(map[fileKey]*partFile),
+	}
+
+	return &sink
+}
+
+// Put satisfies the api.Sink interface
+func (ps *ParquetSink) Put(m api.Measurement) error {
+	ps.mutex.Lock()
+	defer ps.mutex.Unlock()
+
+	if !ps.recovered {
+		if err := ps.recoverOrphans(); err != nil {
+			return err
+		}
+		ps.recovered = true
+	}
+
+	rows, found := ps.rows[m.MeasurementType]
+	if !found {
+		rows = newRowType(m.MeasurementType)
+		ps.rows[m.MeasurementType] = rows
+	}
+	row, err := rows.newRow(m)
+	if err != nil {
+		return fmt.Errorf("cannot convert %s measurement to a row: %v", m.MeasurementType, err)
+	}
+
+	key := fileKey{m.MeasurementType, m.Device}
+	date := time.Unix(m.Timestamp, 0).UTC().Format("2006-01-02")
+	part := ps.files[key]
+	if part != nil && (part.date != date || ps.now().Sub(part.opened) >= ps.RotateInterval) {
+		delete(ps.files, key)
+		if err := part.finalize(); err != nil {
+			return err
+		}
+		part = nil
+	}
+	if part == nil {
+		part, err = ps.create(key, date, rows.schema)
+		if err != nil {
+			return err
+		}
+		ps.files[key] = part
+	}
+
+	return part.writer.Write(row)
+}
+
+// Close finalizes the files being written
+func (ps *ParquetSink) Close() error {
+	ps.mutex.Lock()
+	defer ps.mutex.Unlock()
+
+	var firstErr error
+	for key, part := range ps.files {
+		if err := part.finalize(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+		delete(ps.files, key)
+	}
+
+	return firstErr
+}
+
+func (ps *ParquetSink) create(key fileKey, date string, schema *parquet.Schema) (*partFile, error) {
+	dir := filepath.Join(ps.Root, string(key.measurementType), "device="+url.PathEscape(key.device), "date="+date)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+
+	now := ps.now()
+	stamp := now.UTC().Format("20060102T150405")
+	path := filepath.Join(dir, fmt.Sprintf("part-%s.parquet", stamp))
+	for index := 1; exists(path); index++ {
+		path = filepath.Join(dir, fmt.Sprintf("part-%s.%d.parquet", stamp, index))
+	}
+
+	file, err := os.Create(inProgress(path))
+	if err != nil {
+		return nil, err
+	}
+
+	part := partFile{
+		date:   date,
+		path:   path,
+		file:   file,
+		writer: parquet.NewWriter(file, schema, parquet.MaxRowsPerRowGroup(ps.RowGroupSize)),
+		opened: now,
+	}
+	return &part, nil
+}
+
+// finalize writes the buffered rows and the footer, syncs the file and gives it its final name
+func (pf *partFile) finalize() error {
+	writeErr := pf.writer.Close()
+	syncErr := pf.file.Sync()
+	closeErr := pf.file.Close()
+	for _, err := range []error{writeErr, syncErr, closeErr} {
+		if err != nil {
+			return err
+		}
+	}
+
+	return os.Rename(inProgress(pf.path), pf.path)
+}
+
+// recoverOrphans renames the in progress files under Root which were finalized, deleting the others
+func (ps *ParquetSink) recoverOrphans() error {
+	err := filepath.WalkDir(ps.Root, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasPrefix(name, ".") || !strings.HasSuffix(name, ".inprogress") {
+			return nil
+		}
+
+		final := filepath.Join(filepath.Dir(path), strings.TrimSuffix(strings.TrimPrefix(name, "."), ".inprogress"))
+		if readable(path) && !exists(final) {
+			log.Printf("Recovering the Parquet file %s", final)
+			return os.Rename(path, final)
+		}
+		log.Printf("Deleting the incomplete Parquet file %s", path)
+		return os.Remove(path)
+	})
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+// readable tells whether a Parquet file was completely written
+func readable(path string) bool {
+	file, err := os.Open(path)
+	if err != nil {
+		return false
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return false
+	}
+	_, err = parquet.OpenFile(file, info.Size())
+	return err == nil
+}
+
+// inProgress returns the hidden name of a file being written, ignored by Spark, DuckDB and Hive
+func inProgress(path string) string {
+	return filepath.Join(filepath.Dir(path), "."+filepath.Base(path)+".inprogress")
+}
+
+func exists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
@@ -0,0 +1,139 @@
+package parquetsink
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/parquet-go/parquet-go"
+	"github.com/renajohn/pac_collector/api"
+)
+
+type temperatureRow struct {
+	Timestamp       int64   `parquet:"timestamp"`
+	Device          string  `parquet:"device"`
+	TankTemperature float64 `parquet:"TankTemperature"`
+}
+
+type derivedRow struct {
+	Timestamp           int64    `parquet:"timestamp"`
+	HeatingDeltaT       *float64 `parquet:"HeatingDeltaT,optional"`
+	HeatingThermalPower *float64 `parquet:"HeatingThermalPower,optional"`
+}
+
+type genericRow struct {
+	Device string `parquet:"device"`
+	Value  string `parquet:"value"`
+}
+
+func TestPut(t *testing.T) {
+	t.Run("Measurements are partitioned by type, device and date", func(t *testing.T) {
+		root := t.TempDir()
+		sink := NewParquetSink(root)
+		sink.now = func() time.Time { return time.Date(2020, 9, 13, 23, 59, 0, 0, time.UTC) }
+
+		sink.Put(api.Measurement{MeasurementType: api.SWCTemperature, Device: "swc", Timestamp: 1600041540, Value: []byte(`{"TankTemperature":52.3}`)})
+		sink.Put(api.Measurement{MeasurementType: api.SWCTemperature, Device: "swc", Timestamp: 1600041600, Value: []byte(`{"TankTemperature":52.5}`)})
+
+		first := filepath.Join(root, "SWCTemperature", "device=swc", "date=2020-09-13", "part-20200913T235900.parquet")
+		rows, err := parquet.ReadFile[temperatureRow](first)
+		if err != nil {
+			t.Fatalf("Expected the first partition to be finalized on date change, got %v", err)
+		}
+		if !reflect.DeepEqual([]temperatureRow{{1600041540000, "swc", 52.3}}, rows) {
+			t.Errorf("Unexpected rows %+v", rows)
+		}
+
+		second := filepath.Join(root, "SWCTemperature", "device=swc", "date=2020-09-14", "part-20200913T235900.parquet")
+		if _, err := os.Stat(inProgress(second)); err != nil {
+			t.Errorf("Expected the second partition to be in progress, got %v", err)
+		}
+
+		sink.Close()
+
+		rows, err = parquet.ReadFile[temperatureRow](second)
+		if err != nil || !reflect.DeepEqual([]temperatureRow{{1600041600000, "swc", 52.5}}, rows) {
+			t.Errorf("Expected the second partition to be finalized on close, got %+v and %v", rows, err)
+		}
+		if _, err := os.Stat(inProgress(second)); !os.IsNotExist(err) {
+			t.Errorf("Expected the in progress file to be gone, got %v", err)
+		}
+	})
+
+	t.Run("Files left in progress are recovered when finalized, deleted otherwise", func(t *testing.T) {
+		root := t.TempDir()
+		dir := filepath.Join(root, "SWCTemperature", "device=swc", "date=2020-09-12")
+		finalized := filepath.Join(dir, "part-20200912T000000.parquet")
+		interrupted := filepath.Join(dir, "part-20200912T120000.parquet")
+		os.MkdirAll(dir, 0755)
+		if err := parquet.WriteFile(inProgress(finalized), []temperatureRow{{1599868800000, "swc", 51}}); err != nil {
+			t.Fatal(err)
+		}
+		os.WriteFile(inProgress(interrupted), []byte("PAR1"), 0644)
+
+		sink := NewParquetSink(root)
+		sink.now = func() time.Time { return time.Date(2020, 9, 13, 12, 0, 0, 0, time.UTC) }
+		if err := sink.Put(api.Measurement{MeasurementType: api.SWCTemperature, Device: "swc", Timestamp: 1600000000, Value: []byte(`{"TankTemperature":52.3}`)}); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		sink.Close()
+
+		if rows, err := parquet.ReadFile[temperatureRow](finalized); err != nil || len(rows) != 1 {
+			t.Errorf("Expected the finalized file to be recovered, got %+v and %v", rows, err)
+		}
+		for _, path := range []string{inProgress(finalized), inProgress(interrupted), interrupted} {
+			if _, err := os.Stat(path); !os.IsNotExist(err) {
+				t.Errorf("Expected %s to be gone, got %v", path, err)
+			}
+		}
+	})
+
+	t.Run("Optional fields are nullable columns", func(t *testing.T) {
+		root := t.TempDir()
+		sink := NewParquetSink(root)
+
+		sink.Put(api.Measurement{MeasurementType: api.SWCDerived, Device: "swc", Timestamp: 1600041540, Value: []byte(`{"HeatingDeltaT":5}`)})
+		sink.Close()
+
+		files, _ := filepath.Glob(filepath.Join(root, "SWCDerived", "device=swc", "date=2020-09-13", "*.parquet"))
+		if len(files) != 1 {
+			t.Fatalf("Expected a single file, got %v", files)
+		}
+		rows, err := parquet.ReadFile[derivedRow](files[0])
+		if err != nil || len(rows) != 1 || *rows[0].HeatingDeltaT != 5 || rows[0].HeatingThermalPower != nil {
+			t.Errorf("Unexpected rows %+v, %v", rows, err)
+		}
+	})
+
+	t.Run("Types without a model are written as JSON", func(t *testing.T) {
+		root := t.TempDir()
+		sink := NewParquetSink(root)
+
+		sink.Put(api.Measurement{MeasurementType: "SWCTemperatureAggregate", Device: "swc", Timestamp: 1600041540, Value: []byte(`{"TankTemperatureMean":52.4}`)})
+		sink.Close()
+
+		files, _ := filepath.Glob(filepath.Join(root, "SWCTemperatureAggregate", "device=swc", "date=2020-09-13", "*.parquet"))
+		if len(files) != 1 {
+			t.Fatalf("Expected a single file, got %v", files)
+		}
+		rows, err := parquet.ReadFile[genericRow](files[0])
+		if err != nil || !reflect.DeepEqual([]genericRow{{"swc", `{"TankTemperatureMean":52.4}`}}, rows) {
+			t.Errorf("Unexpected rows %+v, %v", rows, err)
+		}
+	})
+}
+
+func TestNewRowType(t *testing.T) {
+	rows := newRowType(api.Alert)
+
+	for _, column := range []string{"timestamp", "device", "Rule", "FiringSince"} {
+		if _, found := rows.schema.Lookup(column); !found {
+			t.Errorf("Expected column %s in %v", column, rows.schema)
+		}
+	}
+	if _, found := rows.schema.Lookup("Device"); found {
+		t.Errorf("Expected the alert device not to be duplicated in %v", rows.schema)
+	}
+}
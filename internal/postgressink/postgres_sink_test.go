@@ -0,0 +1,216 @@
+package postgressink
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/renajohn/pac_collector/api"
+)
+
+type copied struct {
+	table   string
+	columns []string
+	rows    [][]interface{}
+}
+
+// fakeDatabase records the statements and copies, failing them while err is set
+// and failing the copies to the tables of tableErrs
+type fakeDatabase struct {
+	statements []string
+	copies     []copied
+	err        error
+	tableErrs  map[string]error
+	closed     bool
+}
+
+func (fd *fakeDatabase) Exec(ctx context.Context, sql string, arguments ...interface{}) (pgconn.CommandTag, error) {
+	if fd.err != nil {
+		return pgconn.CommandTag{}, fd.err
+	}
+	fd.statements = append(fd.statements, sql)
+	return pgconn.CommandTag{}, nil
+}
+
+func (fd *fakeDatabase) CopyFrom(ctx context.Context, table pgx.Identifier, columns []string, rows pgx.CopyFromSource) (int64, error) {
+	if fd.err != nil {
+		return 0, fd.err
+	}
+	if err := fd.tableErrs[table.Sanitize()]; err != nil {
+		return 0, err
+	}
+	c := copied{table: table.Sanitize(), columns: columns}
+	for rows.Next() {
+		values, _ := rows.Values()
+		c.rows = append(c.rows, values)
+	}
+	fd.copies = append(fd.copies, c)
+	return int64(len(c.rows)), nil
+}
+
+func (fd *fakeDatabase) Close() {
+	fd.closed = true
+}
+
+func newSink(t *testing.T, postgresURL string) (*PostgresSink, *fakeDatabase) {
+	t.Helper()
+	sink, err := NewPostgresSink(postgresURL)
+	if err != nil {
+		t.Fatalf("No error was expected but got %v", err)
+	}
+
+	db := &fakeDatabase{}
+	sink.connect = func(ctx context.Context, dsn string) (database, error) { return db, nil }
+	return sink, db
+}
+
+func temperature(timestamp int64) api.Measurement {
+	return api.Measurement{
+		MeasurementType: api.SWCTemperature,
+		Device:          "swc",
+		Timestamp:       timestamp,
+		Value:           []byte(`{"TankTemperature":52.3,"OutsideTemperature":4.7}`),
+	}
+}
+
+func TestNewPostgresSink(t *testing.T) {
+	t.Run("Sink parameters are not passed to the driver", func(t *testing.T) {
+		sink, err := NewPostgresSink("postgres://user:password@db:5432/pac?sslmode=disable&layout=wide&hypertable=true&batch=10&flushInterval=5")
+
+		if err != nil {
+			t.Fatalf("No error was expected but got %v", err)
+		}
+		if sink.dsn != "postgres://user:password@db:5432/pac?sslmode=disable" {
+			t.Errorf("Unexpected DSN %s", sink.dsn)
+		}
+		if sink.Layout != Wide || !sink.Hypertable || sink.BatchSize != 10 || sink.FlushInterval != 5*time.Second {
+			t.Errorf("Unexpected sink %+v", sink)
+		}
+	})
+
+	t.Run("should error out when the layout is unknown", func(t *testing.T) {
+		if _, err := NewPostgresSink("postgres://db/pac?layout=tall"); err == nil {
+			t.Error("An error was expected and none was returned")
+		}
+	})
+}
+
+func TestPut(t *testing.T) {
+	t.Run("Narrow layout writes a row per numeric field", func(t *testing.T) {
+		sink, db := newSink(t, "postgres://db/pac?batch=2&table=pac.readings")
+
+		sink.Put(api.Measurement{MeasurementType: api.SWCStatus, Device: "swc", Timestamp: 60, Value: []byte(`{"OperatingState":"Chauffage","HeatOutput":8.2}`),
+			Tags: map[string]string{"quality": "suspect"}})
+		if len(db.copies) != 0 {
+			t.Fatalf("Expected rows to be batched, got %v", db.copies)
+		}
+		if err := sink.Put(temperature(60)); err != nil {
+			t.Fatalf("No error was expected but got %v", err)
+		}
+		sink.Close()
+
+		if len(db.statements) != 2 || !strings.HasPrefix(db.statements[0], `CREATE TABLE IF NOT EXISTS "pac"."readings"`) {
+			t.Errorf("Expected the table to be created, got %v", db.statements)
+		}
+		timestamp := time.Unix(60, 0).UTC()
+		expected := []copied{{
+			table:   `"pac"."readings"`,
+			columns: narrowColumns,
+			rows: [][]interface{}{
+				{timestamp, "swc", "SWCStatus", "HeatOutput", 8.2, `{"quality":"suspect"}`},
+				{timestamp, "swc", "SWCTemperature", "OutsideTemperature", 4.7, nil},
+				{timestamp, "swc", "SWCTemperature", "TankTemperature", 52.3, nil},
+			},
+		}}
+		if !reflect.DeepEqual(expected, db.copies) {
+			t.Errorf("Expected %v got %v", expected, db.copies)
+		}
+	})
+
+	t.Run("Wide layout writes a row per measurement in a table per type", func(t *testing.T) {
+		sink, db := newSink(t, "postgres://db/pac?layout=wide&hypertable=true")
+
+		sink.Put(temperature(60))
+		sink.Put(api.Measurement{MeasurementType: api.SWCTemperature, Device: "swc", Timestamp: 120, Value: []byte(`{"TankTemperature":52.1}`)})
+		sink.Put(api.Measurement{MeasurementType: api.SWCOutputs, Device: "swc", Timestamp: 120, Value: []byte(`{"Compressor":true}`)})
+		if err := sink.Close(); err != nil {
+			t.Fatalf("No error was expected but got %v", err)
+		}
+
+		expectedStatements := []string{
+			`CREATE TABLE IF NOT EXISTS "swc_temperature" (time TIMESTAMPTZ NOT NULL, device TEXT NOT NULL, tags JSONB)`,
+			`CREATE INDEX IF NOT EXISTS "swc_temperature_device_time" ON "swc_temperature" (device, time DESC)`,
+			`SELECT create_hypertable('"swc_temperature"', 'time', if_not_exists => TRUE, migrate_data => TRUE)`,
+			`ALTER TABLE "swc_temperature" ADD COLUMN IF NOT EXISTS "outside_temperature" DOUBLE PRECISION`,
+			`ALTER TABLE "swc_temperature" ADD COLUMN IF NOT EXISTS "tank_temperature" DOUBLE PRECISION`,
+			`CREATE TABLE IF NOT EXISTS "swc_outputs" (time TIMESTAMPTZ NOT NULL, device TEXT NOT NULL, tags JSONB)`,
+			`CREATE INDEX IF NOT EXISTS "swc_outputs_device_time" ON "swc_outputs" (device, time DESC)`,
+			`SELECT create_hypertable('"swc_outputs"', 'time', if_not_exists => TRUE, migrate_data => TRUE)`,
+			`ALTER TABLE "swc_outputs" ADD COLUMN IF NOT EXISTS "compressor" BOOLEAN`,
+		}
+		if !reflect.DeepEqual(expectedStatements, db.statements) {
+			t.Errorf("Expected\n%s\ngot\n%s", strings.Join(expectedStatements, "\n"), strings.Join(db.statements, "\n"))
+		}
+
+		if len(db.copies) != 3 || db.copies[0].table != `"swc_temperature"` || len(db.copies[1].columns) != 4 || db.copies[2].table != `"swc_outputs"` {
+			t.Errorf("Expected a copy per table and columns, got %v", db.copies)
+		}
+		if !db.closed {
+			t.Error("Expected the database to be closed")
+		}
+	})
+
+	t.Run("Rows are kept and the schema checked again when the write fails", func(t *testing.T) {
+		sink, db := newSink(t, "postgres://db/pac")
+
+		sink.Put(temperature(60))
+		sink.Flush()
+		db.err = errors.New("connection refused")
+		sink.Put(temperature(120))
+		if err := sink.Flush(); err == nil {
+			t.Fatal("An error was expected and none was returned")
+		}
+
+		db.err = nil
+		db.statements = nil
+		sink.Put(temperature(180))
+		if err := sink.Flush(); err != nil {
+			t.Fatalf("No error was expected but got %v", err)
+		}
+
+		if len(db.statements) != 2 {
+			t.Errorf("Expected the schema to be checked again, got %v", db.statements)
+		}
+		if len(db.copies) != 2 || len(db.copies[1].rows) != 4 {
+			t.Errorf("Expected the failed rows to be retried, got %v", db.copies)
+		}
+	})
+
+	t.Run("A failing table does not hold back the others and rejected rows are dropped", func(t *testing.T) {
+		sink, db := newSink(t, "postgres://db/pac?layout=wide")
+		db.tableErrs = map[string]error{
+			`"swc_temperature"`: &pgconn.PgError{Code: "42804", Message: "column is of type boolean"},
+			`"swc_outputs"`:     errors.New("connection reset"),
+		}
+
+		sink.Put(temperature(60))
+		sink.Put(api.Measurement{MeasurementType: api.SWCOutputs, Device: "swc", Timestamp: 60, Value: []byte(`{"Compressor":true}`)})
+		sink.Put(api.Measurement{MeasurementType: api.SWCStatus, Device: "swc", Timestamp: 60, Value: []byte(`{"HeatOutput":8.2}`)})
+		err := sink.Flush()
+
+		if err == nil {
+			t.Fatal("An error was expected and none was returned")
+		}
+		if len(db.copies) != 1 || db.copies[0].table != `"swc_status"` {
+			t.Errorf("Expected the status to be written, got %v", db.copies)
+		}
+		if sink.Pending() != 1 {
+			t.Errorf("Expected only the outputs to be kept, got %d pending rows", sink.Pending())
+		}
+	})
+}
@@ -0,0 +1,149 @@
+package powermetersource
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/renajohn/pac_collector/api"
+)
+
+// DefaultDevice is the device name attached to measurements when none is configured
+const DefaultDevice = "powermeter"
+
+// Defaults matching the /status endpoint of a Shelly EM, which reports its energy counter in Wh
+const (
+	DefaultPowerPath   = "emeters.0.power"
+	DefaultEnergyPath  = "emeters.0.total"
+	DefaultEnergyScale = 0.001
+)
+
+// PowerMeterMeasurement represents the readings of an electrical power meter
+type PowerMeterMeasurement struct {
+	Power  float64 // W
+	Energy float64 // kWh
+}
+
+// PowerMeterSource polls an HTTP endpoint returning JSON, such as a Shelly or a generic REST power meter
+type PowerMeterSource struct {
+	URL            string
+	PollIntervalMs time.Duration // defaults to 1 min
+	Device         string        // defaults to DefaultDevice
+	PowerPath      string        // dotted path to the instantaneous power in W, e.g. "emeters.0.power"
+	EnergyPath     string        // dotted path to the energy counter
+	EnergyScale    float64       // factor converting the energy counter to kWh
+
+	client              *http.Client
+	measurementsChannel chan api.Measurement
+}
+
+// NewPowerMeterSource creates a new PowerMeterSource, reading a Shelly EM by default
+func NewPowerMeterSource(URL string, pollingInterval time.Duration) *PowerMeterSource {
+	if pollingInterval == 0 {
+		pollingInterval = time.Minute
+	}
+
+	source := PowerMeterSource{
+		URL:                 URL,
+		PollIntervalMs:      pollingInterval,
+		Device:              DefaultDevice,
+		PowerPath:           DefaultPowerPath,
+		EnergyPath:          DefaultEnergyPath,
+		EnergyScale:         DefaultEnergyScale,
+		client:              &http.Client{Timeout: 10 * time.Second},
+		measurementsChannel: make(chan api.Measurement, 10),
+	}
+
+	return &source
+}
+
+// MeasurementsChannel satisfies the api.Source interface
+func (pm *PowerMeterSource) MeasurementsChannel() <-chan api.Measurement {
+	return pm.measurementsChannel
+}
+
+// Start satisfies the api.Source interface. Failed readings are logged and retried on the next poll.
+func (pm *PowerMeterSource) Start() {
+	for {
+		measurement, err := pm.read()
+		if err != nil {
+			log.Printf("Failed to read power meter %s: %v", pm.URL, err)
+		} else {
+			pm.measurementsChannel <- measurement
+		}
+
+		time.Sleep(pm.PollIntervalMs)
+	}
+}
+
+func (pm *PowerMeterSource) read() (api.Measurement, error) {
+	response, err := pm.client.Get(pm.URL)
+	if err != nil {
+		return api.Measurement{}, err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return api.Measurement{}, fmt.Errorf("unexpected status %s", response.Status)
+	}
+
+	var document interface{}
+	err = json.NewDecoder(response.Body).Decode(&document)
+	if err != nil {
+		return api.Measurement{}, err
+	}
+
+	power, err := lookupNumber(document, pm.PowerPath)
+	if err != nil {
+		return api.Measurement{}, err
+	}
+	energy, err := lookupNumber(document, pm.EnergyPath)
+	if err != nil {
+		return api.Measurement{}, err
+	}
+
+	data, _ := json.Marshal(PowerMeterMeasurement{
+		Power:  power,
+		Energy: energy * pm.EnergyScale,
+	})
+
+	return api.Measurement{
+		MeasurementType: api.ElectricalPower,
+		Device:          pm.Device,
+		Timestamp:       time.Now().Unix(),
+		Value:           data,
+	}, nil
+}
+
+// lookupNumber walks a decoded JSON document along a dotted path, where numeric elements index arrays
+func lookupNumber(document interface{}, path string) (float64, error) {
+	current := document
+	for _, element := range strings.Split(path, ".") {
+		switch node := current.(type) {
+		case map[string]interface{}:
+			value, found := node[element]
+			if !found {
+				return 0, fmt.Errorf("%s: %q not found", path, element)
+			}
+			current = value
+		case []interface{}:
+			index, err := strconv.Atoi(element)
+			if err != nil || index < 0 || index >= len(node) {
+				return 0, fmt.Errorf("%s: invalid index %q", path, element)
+			}
+			current = node[index]
+		default:
+			return 0, fmt.Errorf("%s: cannot look up %q in a scalar", path, element)
+		}
+	}
+
+	number, ok := current.(float64)
+	if !ok {
+		return 0, fmt.Errorf("%s: %v is not a number", path, current)
+	}
+	return number, nil
+}
@@ -0,0 +1,84 @@
+package powermetersource
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/renajohn/pac_collector/api"
+)
+
+// shellyStatus is a trimmed down reply of the /status endpoint of a Shelly EM
+const shellyStatus = `{"emeters":[{"power":1523.4,"reactive":0,"voltage":231.2,"is_valid":true,"total":1234567.8}]}`
+
+func makeServer(status int, body string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+		response.WriteHeader(status)
+		response.Write([]byte(body))
+	}))
+}
+
+func TestStart(t *testing.T) {
+	t.Run("Happy case", func(t *testing.T) {
+		server := makeServer(http.StatusOK, shellyStatus)
+		defer server.Close()
+		source := NewPowerMeterSource(server.URL, 1000)
+
+		go source.Start()
+		measurement := <-source.MeasurementsChannel()
+
+		if measurement.MeasurementType != api.ElectricalPower || measurement.Device != DefaultDevice {
+			t.Errorf("Unexpected measurement %+v", measurement)
+		}
+		var value PowerMeterMeasurement
+		json.Unmarshal(measurement.Value, &value)
+		expected := PowerMeterMeasurement{Power: 1523.4, Energy: 1234567.8 * DefaultEnergyScale}
+		if !reflect.DeepEqual(expected, value) {
+			t.Errorf("Expected value of %+v got %+v", expected, value)
+		}
+	})
+}
+
+func TestRead(t *testing.T) {
+	t.Run("Paths can be configured for generic endpoints", func(t *testing.T) {
+		server := makeServer(http.StatusOK, `{"power":{"w":800},"energy_kwh":12.5}`)
+		defer server.Close()
+		source := NewPowerMeterSource(server.URL, 1000)
+		source.PowerPath = "power.w"
+		source.EnergyPath = "energy_kwh"
+		source.EnergyScale = 1
+
+		measurement, err := source.read()
+
+		if err != nil {
+			t.Fatalf("No error was expected but got %v", err)
+		}
+		if string(measurement.Value) != `{"Power":800,"Energy":12.5}` {
+			t.Errorf("Unexpected value %s", string(measurement.Value))
+		}
+	})
+
+	t.Run("When the endpoint fails, an error should be returned", func(t *testing.T) {
+		server := makeServer(http.StatusInternalServerError, "")
+		defer server.Close()
+
+		_, err := NewPowerMeterSource(server.URL, 1000).read()
+
+		if err == nil {
+			t.Error("An error was expected and none was returned")
+		}
+	})
+
+	t.Run("When a path does not exist, an error should be returned", func(t *testing.T) {
+		server := makeServer(http.StatusOK, `{"emeters":[]}`)
+		defer server.Close()
+
+		_, err := NewPowerMeterSource(server.URL, 1000).read()
+
+		if err == nil {
+			t.Error("An error was expected and none was returned")
+		}
+	})
+}
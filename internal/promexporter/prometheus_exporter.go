@@ -0,0 +1,170 @@
+package promexporter
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/renajohn/pac_collector/api"
+	"github.com/renajohn/pac_collector/internal/fields"
+)
+
+// Namespace prefixes every metric name
+const Namespace = "pacmon"
+
+// Exporter exposes the latest value of every numeric field as Prometheus gauges, along with the
+// collector self-metrics. It observes the measurements as a pass-through api.Processor.
+type Exporter struct {
+	Restarts func() int64 // reports the session restarts, optional
+
+	mutex      sync.Mutex
+	latest     map[seriesKey]float64
+	lastPoll   map[string]int64
+	produced   map[api.MeasurementType]float64
+	sinkErrors map[api.MeasurementType]float64
+}
+
+type seriesKey struct {
+	name   string
+	field  string
+	device string
+}
+
+// NewExporter creates a new Exporter
+func NewExporter() *Exporter {
+	exporter := Exporter{
+		latest:     make(map[seriesKey]float64),
+		lastPoll:   make(map[string]int64),
+		produced:   make(map[api.MeasurementType]float64),
+		sinkErrors: make(map[api.MeasurementType]float64),
+	}
+
+	return &exporter
+}
+
+// Process satisfies the api.Processor interface, recording the numeric fields of the measurement
+func (e *Exporter) Process(m api.Measurement) []api.Measurement {
+	values, err := fields.Numeric(m)
+	if err != nil {
+		return []api.Measurement{m}
+	}
+
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	for field, value := range values {
+		e.latest[seriesKey{name: MetricName(m.MeasurementType, field), field: field, device: m.Device}] = value
+	}
+	if m.MeasurementType == api.SWCTemperature {
+		e.lastPoll[m.Device] = m.Timestamp
+	}
+
+	return []api.Measurement{m}
+}
+
+// Instrument wraps a sink so that the produced messages and sink errors are counted
+func (e *Exporter) Instrument(sink api.Sink) api.Sink {
+	return &instrumentedSink{sink: sink, exporter: e}
+}
+
+type instrumentedSink struct {
+	sink     api.Sink
+	exporter *Exporter
+}
+
+func (is *instrumentedSink) Put(m api.Measurement) error {
+	err := is.sink.Put(m)
+
+	is.exporter.mutex.Lock()
+	if err != nil {
+		is.exporter.sinkErrors[m.MeasurementType]++
+	} else {
+		is.exporter.produced[m.MeasurementType]++
+	}
+	is.exporter.mutex.Unlock()
+
+	return err
+}
+
+// ServeHTTP writes the metrics in the Prometheus text exposition format
+func (e *Exporter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	e.write(w)
+}
+
+// write writes the metrics in the Prometheus text exposition format
+func (e *Exporter) write(w io.Writer) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	keys := make([]seriesKey, 0, len(e.latest))
+	for key := range e.latest {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].name != keys[j].name {
+			return keys[i].name < keys[j].name
+		}
+		return keys[i].device < keys[j].device
+	})
+
+	for i, key := range keys {
+		if i == 0 || keys[i-1].name != key.name {
+			fmt.Fprintf(w, "# HELP %s Latest %s reading\n# TYPE %s gauge\n", key.name, key.field, key.name)
+		}
+		fmt.Fprintf(w, "%s{device=%s,unit=%s} %s\n", key.name, quote(key.device), quote(fields.Unit(key.field)), format(e.latest[key]))
+	}
+
+	writeByType(w, Namespace+"_messages_produced_total", "Measurements successfully sent to the sinks", e.produced)
+	writeByType(w, Namespace+"_sink_errors_total", "Measurements the sinks failed to write", e.sinkErrors)
+
+	if e.Restarts != nil {
+		name := Namespace + "_session_restarts_total"
+		fmt.Fprintf(w, "# HELP %s Sessions restarted after a failure\n# TYPE %s counter\n%s %d\n", name, name, name, e.Restarts())
+	}
+
+	if len(e.lastPoll) > 0 {
+		name := Namespace + "_last_successful_poll_timestamp_seconds"
+		fmt.Fprintf(w, "# HELP %s Time of the last temperatures read out of the device\n# TYPE %s gauge\n", name, name)
+		devices := make([]string, 0, len(e.lastPoll))
+		for device := range e.lastPoll {
+			devices = append(devices, device)
+		}
+		sort.Strings(devices)
+		for _, device := range devices {
+			fmt.Fprintf(w, "%s{device=%s} %d\n", name, quote(device), e.lastPoll[device])
+		}
+	}
+}
+
+func writeByType(w io.Writer, name string, help string, counts map[api.MeasurementType]float64) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s counter\n", name, help, name)
+
+	types := make([]string, 0, len(counts))
+	for measurementType := range counts {
+		types = append(types, string(measurementType))
+	}
+	sort.Strings(types)
+	for _, measurementType := range types {
+		fmt.Fprintf(w, "%s{type=%s} %s\n", name, quote(measurementType), format(counts[api.MeasurementType(measurementType)]))
+	}
+}
+
+// MetricName returns the name of the gauge of a field, e.g. pacmon_swc_temperature_tank_temperature
+func MetricName(measurementType api.MeasurementType, field string) string {
+	return fmt.Sprintf("%s_%s_%s", Namespace, fields.SnakeCase(string(measurementType)), fields.SnakeCase(field))
+}
+
+func format(value float64) string {
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
+
+// quote escapes a label value
+func quote(value string) string {
+	replacer := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+	return `"` + replacer.Replace(value) + `"`
+}
@@ -0,0 +1,61 @@
+package promexporter
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/renajohn/pac_collector/api"
+	"github.com/renajohn/pac_collector/internal/mocksink"
+)
+
+type failingSink struct{}
+
+func (fs *failingSink) Put(m api.Measurement) error {
+	return errors.New("boom")
+}
+
+func TestServeHTTP(t *testing.T) {
+	exporter := NewExporter()
+	exporter.Restarts = func() int64 { return 2 }
+
+	exporter.Process(api.Measurement{MeasurementType: api.SWCTemperature, Device: "swc", Timestamp: 42, Value: []byte(`{"TankTemperature":52.3,"OutsideTemperature":-4}`)})
+	exporter.Process(api.Measurement{MeasurementType: api.SWCTemperature, Device: "swc", Timestamp: 60, Value: []byte(`{"TankTemperature":52.5,"OutsideTemperature":-4}`)})
+	exporter.Process(api.Measurement{MeasurementType: api.SWCDerived, Device: "swc", Timestamp: 60, Value: []byte(`{"HeatingDeltaT":5}`)})
+	exporter.Instrument(&mocksink.MockSink{}).Put(api.Measurement{MeasurementType: api.SWCTemperature})
+	exporter.Instrument(&failingSink{}).Put(api.Measurement{MeasurementType: api.Alert})
+
+	recorder := httptest.NewRecorder()
+	exporter.ServeHTTP(recorder, httptest.NewRequest("GET", "/metrics", nil))
+	body, _ := ioutil.ReadAll(recorder.Body)
+
+	expected := `# HELP pacmon_swc_derived_heating_delta_t Latest HeatingDeltaT reading
+# TYPE pacmon_swc_derived_heating_delta_t gauge
+pacmon_swc_derived_heating_delta_t{device="swc",unit="°C"} 5
+# HELP pacmon_swc_temperature_outside_temperature Latest OutsideTemperature reading
+# TYPE pacmon_swc_temperature_outside_temperature gauge
+pacmon_swc_temperature_outside_temperature{device="swc",unit="°C"} -4
+# HELP pacmon_swc_temperature_tank_temperature Latest TankTemperature reading
+# TYPE pacmon_swc_temperature_tank_temperature gauge
+pacmon_swc_temperature_tank_temperature{device="swc",unit="°C"} 52.5
+# HELP pacmon_messages_produced_total Measurements successfully sent to the sinks
+# TYPE pacmon_messages_produced_total counter
+pacmon_messages_produced_total{type="SWCTemperature"} 1
+# HELP pacmon_sink_errors_total Measurements the sinks failed to write
+# TYPE pacmon_sink_errors_total counter
+pacmon_sink_errors_total{type="Alert"} 1
+# HELP pacmon_session_restarts_total Sessions restarted after a failure
+# TYPE pacmon_session_restarts_total counter
+pacmon_session_restarts_total 2
+# HELP pacmon_last_successful_poll_timestamp_seconds Time of the last temperatures read out of the device
+# TYPE pacmon_last_successful_poll_timestamp_seconds gauge
+pacmon_last_successful_poll_timestamp_seconds{device="swc"} 60
+`
+	if string(body) != expected {
+		t.Errorf("Expected\n%s\ngot\n%s", expected, body)
+	}
+	if recorder.Header().Get("Content-Type") != "text/plain; version=0.0.4; charset=utf-8" {
+		t.Errorf("Unexpected content type %s", recorder.Header().Get("Content-Type"))
+	}
+}
@@ -0,0 +1,119 @@
+package pushnotifier
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/renajohn/pac_collector/internal/alerting"
+)
+
+// Supported push services
+const (
+	Ntfy   = "ntfy"
+	Gotify = "gotify"
+)
+
+// PushNotifier pushes alerts to a ntfy topic or a Gotify server
+type PushNotifier struct {
+	Service string // Ntfy or Gotify
+	URL     string // ntfy: topic URL, e.g. https://ntfy.sh/my-heat-pump; Gotify: server URL
+	Token   string // ntfy: optional access token; Gotify: application token
+
+	client *http.Client
+}
+
+// NewPushNotifier creates a new PushNotifier
+func NewPushNotifier(service string, URL string, token string) (*PushNotifier, error) {
+	if service != Ntfy && service != Gotify {
+		return nil, fmt.Errorf("unknown push service %q", service)
+	}
+
+	notifier := PushNotifier{
+		Service: service,
+		URL:     URL,
+		Token:   token,
+		client:  &http.Client{Timeout: 10 * time.Second},
+	}
+
+	return &notifier, nil
+}
+
+// Notify satisfies the alerting.Notifier interface
+func (pn *PushNotifier) Notify(alert alerting.Alert) error {
+	var request *http.Request
+	var err error
+	if pn.Service == Ntfy {
+		request, err = pn.ntfyRequest(alert)
+	} else {
+		request, err = pn.gotifyRequest(alert)
+	}
+	if err != nil {
+		return err
+	}
+
+	response, err := pn.client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode < 200 || response.StatusCode >= 300 {
+		return fmt.Errorf("%s replied %s", pn.Service, response.Status)
+	}
+	return nil
+}
+
+func (pn *PushNotifier) ntfyRequest(alert alerting.Alert) (*http.Request, error) {
+	request, err := http.NewRequest(http.MethodPost, pn.URL, strings.NewReader(message(alert)))
+	if err != nil {
+		return nil, err
+	}
+
+	request.Header.Set("Title", title(alert))
+	if alert.State == alerting.Firing {
+		request.Header.Set("Priority", "high")
+		request.Header.Set("Tags", "warning")
+	} else {
+		request.Header.Set("Priority", "default")
+		request.Header.Set("Tags", "white_check_mark")
+	}
+	if len(pn.Token) > 0 {
+		request.Header.Set("Authorization", "Bearer "+pn.Token)
+	}
+
+	return request, nil
+}
+
+func (pn *PushNotifier) gotifyRequest(alert alerting.Alert) (*http.Request, error) {
+	priority := 4
+	if alert.State == alerting.Firing {
+		priority = 8
+	}
+
+	body, _ := json.Marshal(map[string]interface{}{
+		"title":    title(alert),
+		"message":  message(alert),
+		"priority": priority,
+	})
+
+	request, err := http.NewRequest(http.MethodPost, strings.TrimSuffix(pn.URL, "/")+"/message", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set("X-Gotify-Key", pn.Token)
+
+	return request, nil
+}
+
+func title(alert alerting.Alert) string {
+	return fmt.Sprintf("%s %s", alert.Rule, alert.State)
+}
+
+func message(alert alerting.Alert) string {
+	return alert.Summary()
+}
@@ -0,0 +1,74 @@
+package pushnotifier
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/renajohn/pac_collector/internal/alerting"
+)
+
+func makeServer(requests chan *http.Request, bodies chan []byte) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+		body, _ := io.ReadAll(request.Body)
+		requests <- request
+		bodies <- body
+	}))
+}
+
+func TestNotify(t *testing.T) {
+	alert := alerting.Alert{Rule: "tank", State: alerting.Firing, Device: "swc", Field: "TankTemperature", Value: 39, Threshold: 40}
+
+	t.Run("ntfy", func(t *testing.T) {
+		requests, bodies := make(chan *http.Request, 1), make(chan []byte, 1)
+		server := makeServer(requests, bodies)
+		defer server.Close()
+		notifier, _ := NewPushNotifier(Ntfy, server.URL+"/heat-pump", "tk_123")
+
+		err := notifier.Notify(alert)
+
+		if err != nil {
+			t.Fatalf("No error was expected but got %v", err)
+		}
+		request, body := <-requests, <-bodies
+		if request.URL.Path != "/heat-pump" || request.Header.Get("Title") != "tank firing" ||
+			request.Header.Get("Priority") != "high" || request.Header.Get("Authorization") != "Bearer tk_123" {
+			t.Errorf("Unexpected request %s %v", request.URL, request.Header)
+		}
+		if string(body) != alert.Summary() {
+			t.Errorf("Expected message %s got %s", alert.Summary(), string(body))
+		}
+	})
+
+	t.Run("Gotify", func(t *testing.T) {
+		requests, bodies := make(chan *http.Request, 1), make(chan []byte, 1)
+		server := makeServer(requests, bodies)
+		defer server.Close()
+		notifier, _ := NewPushNotifier(Gotify, server.URL+"/", "app-token")
+
+		err := notifier.Notify(alert)
+
+		if err != nil {
+			t.Fatalf("No error was expected but got %v", err)
+		}
+		request, body := <-requests, <-bodies
+		if request.URL.Path != "/message" || request.Header.Get("X-Gotify-Key") != "app-token" {
+			t.Errorf("Unexpected request %s %v", request.URL, request.Header)
+		}
+		var message map[string]interface{}
+		json.Unmarshal(body, &message)
+		if message["title"] != "tank firing" || message["priority"] != float64(8) {
+			t.Errorf("Unexpected message %v", message)
+		}
+	})
+
+	t.Run("When service is unknown, an error should be returned", func(t *testing.T) {
+		_, err := NewPushNotifier("pigeon", "http://test", "")
+
+		if err == nil {
+			t.Error("An error was expected and none was returned")
+		}
+	})
+}
@@ -0,0 +1,183 @@
+package qualityprocessor
+
+import (
+	"encoding/json"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/renajohn/pac_collector/api"
+	"github.com/renajohn/pac_collector/internal/fields"
+)
+
+// Quality flags
+const (
+	OutOfRange = "out_of_range" // value outside of the plausible range, e.g. a disconnected sensor
+	Stuck      = "stuck"        // identical value for too long
+	Spike      = "spike"        // value jumped too much since the previous sample
+)
+
+// QualityTag is set to "suspect" when any field is flagged, the flags of a field being in QualityTag.<Field>
+const QualityTag = "quality"
+
+// FieldRule describes what a plausible field looks like. Zero values disable the related check.
+type FieldRule struct {
+	Min        float64
+	Max        float64 // the range is only checked when Min < Max
+	MaxStep    float64
+	StuckAfter time.Duration
+}
+
+// AnomalyEvent is emitted when a field gets flagged, and when out of range or stuck flags clear.
+// Spikes are momentary and never clear.
+type AnomalyEvent struct {
+	MeasurementType api.MeasurementType
+	Field           string
+	Kind            string
+	Value           float64
+	Active          bool
+}
+
+// QualityProcessor validates the fields of the measurements against per field rules, tags the
+// measurements with quality flags and emits Anomaly measurements.
+type QualityProcessor struct {
+	Rules map[string]FieldRule
+
+	states map[fieldKey]*fieldState
+}
+
+type fieldKey struct {
+	measurementType api.MeasurementType
+	device          string
+	field           string
+}
+
+type fieldState struct {
+	last           float64
+	unchangedSince int64
+	outOfRange     bool
+	stuck          bool
+}
+
+// NewQualityProcessor creates a new QualityProcessor
+func NewQualityProcessor(rules map[string]FieldRule) *QualityProcessor {
+	processor := QualityProcessor{
+		Rules:  rules,
+		states: make(map[fieldKey]*fieldState),
+	}
+
+	return &processor
+}
+
+// DefaultRules returns plausible ranges for the SWC temperatures. Measured temperatures are
+// expected to move within 6h, set points are not.
+func DefaultRules() map[string]FieldRule {
+	measured := func(min float64, max float64) FieldRule {
+		return FieldRule{Min: min, Max: max, MaxStep: 15, StuckAfter: 6 * time.Hour}
+	}
+
+	return map[string]FieldRule{
+		"HeatingOutboundTemperature":     measured(5, 80),
+		"HeatingInboundTemperature":      measured(5, 80),
+		"OutsideTemperature":             measured(-40, 50),
+		"TankTemperature":                measured(5, 90),
+		"TargetTankTemperature":          {Min: 5, Max: 90},
+		"DrillInboundTemperature":        measured(-15, 40),
+		"DrillOutboundTemperature":       measured(-15, 40),
+		"AmbiantIndoorTemperature":       measured(5, 40),
+		"AmbiantIndoorTargetTemperature": {Min: 5, Max: 40},
+		"RemoteControlTemperature":       measured(5, 40),
+		"SolarCollectorTemperature":      measured(-40, 140),
+		"SolarTankTemperature":           measured(0, 100),
+	}
+}
+
+// Process satisfies the api.Processor interface
+func (qp *QualityProcessor) Process(m api.Measurement) []api.Measurement {
+	values, err := fields.Numeric(m)
+	if err != nil {
+		return []api.Measurement{m}
+	}
+
+	tags := make(map[string]string, len(m.Tags))
+	for key, value := range m.Tags {
+		tags[key] = value
+	}
+
+	var events []AnomalyEvent
+	for _, name := range fields.Names(values) {
+		rule, found := qp.Rules[name]
+		if !found {
+			continue
+		}
+
+		flags, fieldEvents := qp.check(fieldKey{measurementType: m.MeasurementType, device: m.Device, field: name}, rule, values[name], m.Timestamp)
+		events = append(events, fieldEvents...)
+		if len(flags) > 0 {
+			tags[QualityTag+"."+name] = strings.Join(flags, ",")
+			tags[QualityTag] = "suspect"
+		}
+	}
+
+	tagged := m
+	if len(tags) > 0 {
+		tagged.Tags = tags
+	}
+
+	processed := []api.Measurement{tagged}
+	for _, event := range events {
+		data, _ := json.Marshal(event)
+		processed = append(processed, api.Measurement{
+			MeasurementType: api.Anomaly,
+			Device:          m.Device,
+			Timestamp:       m.Timestamp,
+			Value:           data,
+		})
+	}
+
+	return processed
+}
+
+// check returns the flags of a field and the anomaly events caused by their changes
+func (qp *QualityProcessor) check(key fieldKey, rule FieldRule, value float64, timestamp int64) ([]string, []AnomalyEvent) {
+	state, known := qp.states[key]
+	if !known {
+		state = &fieldState{last: value, unchangedSince: timestamp}
+		qp.states[key] = state
+	}
+
+	var flags []string
+	var events []AnomalyEvent
+	event := func(kind string, active bool) {
+		events = append(events, AnomalyEvent{MeasurementType: key.measurementType, Field: key.field, Kind: kind, Value: value, Active: active})
+	}
+
+	outOfRange := rule.Min < rule.Max && (value < rule.Min || value > rule.Max)
+	if outOfRange {
+		flags = append(flags, OutOfRange)
+	}
+	if outOfRange != state.outOfRange {
+		event(OutOfRange, outOfRange)
+		state.outOfRange = outOfRange
+	}
+
+	if value != state.last {
+		state.unchangedSince = timestamp
+	}
+	stuck := rule.StuckAfter > 0 && time.Duration(timestamp-state.unchangedSince)*time.Second >= rule.StuckAfter
+	if stuck {
+		flags = append(flags, Stuck)
+	}
+	if stuck != state.stuck {
+		event(Stuck, stuck)
+		state.stuck = stuck
+	}
+
+	if known && rule.MaxStep > 0 && math.Abs(value-state.last) > rule.MaxStep {
+		flags = append(flags, Spike)
+		event(Spike, true)
+	}
+
+	state.last = value
+	return flags, events
+}
@@ -0,0 +1,104 @@
+package qualityprocessor
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/renajohn/pac_collector/api"
+)
+
+func makeMeasurement(timestamp int64, value float64) api.Measurement {
+	return api.Measurement{
+		MeasurementType: api.SWCTemperature,
+		Device:          "swc",
+		Timestamp:       timestamp,
+		Value:           []byte(fmt.Sprintf(`{"SolarTankTemperature":%v,"Other":1}`, value)),
+	}
+}
+
+func decodeEvents(t *testing.T, measurements []api.Measurement) []AnomalyEvent {
+	t.Helper()
+	var events []AnomalyEvent
+	for _, m := range measurements[1:] {
+		if m.MeasurementType != api.Anomaly {
+			t.Fatalf("Expected an anomaly, got %+v", m)
+		}
+		var event AnomalyEvent
+		json.Unmarshal(m.Value, &event)
+		events = append(events, event)
+	}
+	return events
+}
+
+func TestProcess(t *testing.T) {
+	t.Run("Plausible values are not tagged", func(t *testing.T) {
+		processor := NewQualityProcessor(DefaultRules())
+		measurement := makeMeasurement(0, 52.3)
+
+		processed := processor.Process(measurement)
+
+		if !reflect.DeepEqual([]api.Measurement{measurement}, processed) {
+			t.Errorf("Expected measurement to be forwarded untouched, got %+v", processed)
+		}
+	})
+
+	t.Run("Out of range values are tagged and reported once", func(t *testing.T) {
+		processor := NewQualityProcessor(DefaultRules())
+
+		first := processor.Process(makeMeasurement(0, 150))
+		second := processor.Process(makeMeasurement(60, 150))
+		cleared := processor.Process(makeMeasurement(120, 52))
+
+		expectedTags := map[string]string{"quality": "suspect", "quality.SolarTankTemperature": OutOfRange}
+		if !reflect.DeepEqual(expectedTags, first[0].Tags) || !reflect.DeepEqual(expectedTags, second[0].Tags) {
+			t.Errorf("Expected tags %v got %v and %v", expectedTags, first[0].Tags, second[0].Tags)
+		}
+		expected := []AnomalyEvent{{MeasurementType: api.SWCTemperature, Field: "SolarTankTemperature", Kind: OutOfRange, Value: 150, Active: true}}
+		if !reflect.DeepEqual(expected, decodeEvents(t, first)) || len(second) != 1 {
+			t.Errorf("Expected a single anomaly event, got %+v then %+v", first, second)
+		}
+		events := decodeEvents(t, cleared)
+		if len(events) != 2 || events[0].Kind != OutOfRange || events[0].Active || events[1].Kind != Spike {
+			t.Errorf("Expected out of range to clear along with a spike, got %+v", events)
+		}
+	})
+
+	t.Run("Identical values are reported as stuck", func(t *testing.T) {
+		rules := map[string]FieldRule{"SolarTankTemperature": {StuckAfter: time.Hour}}
+		processor := NewQualityProcessor(rules)
+
+		processor.Process(makeMeasurement(0, 20))
+		notYet := processor.Process(makeMeasurement(3599, 20))
+		stuck := processor.Process(makeMeasurement(3600, 20))
+		moving := processor.Process(makeMeasurement(3660, 20.1))
+
+		if len(notYet) != 1 {
+			t.Errorf("Expected no anomaly before an hour, got %+v", notYet)
+		}
+		if events := decodeEvents(t, stuck); len(events) != 1 || events[0].Kind != Stuck || !events[0].Active {
+			t.Errorf("Expected a stuck anomaly, got %+v", events)
+		}
+		if events := decodeEvents(t, moving); len(events) != 1 || events[0].Kind != Stuck || events[0].Active {
+			t.Errorf("Expected the stuck anomaly to clear, got %+v", events)
+		}
+	})
+
+	t.Run("Spikes are reported on every occurrence", func(t *testing.T) {
+		rules := map[string]FieldRule{"SolarTankTemperature": {MaxStep: 5}}
+		processor := NewQualityProcessor(rules)
+
+		processor.Process(makeMeasurement(0, 20))
+		spike := processor.Process(makeMeasurement(60, 30))
+		steady := processor.Process(makeMeasurement(120, 31))
+
+		if spike[0].Tags["quality.SolarTankTemperature"] != Spike || len(decodeEvents(t, spike)) != 1 {
+			t.Errorf("Expected a spike, got %+v", spike)
+		}
+		if len(steady) != 1 || steady[0].Tags != nil {
+			t.Errorf("Expected no anomaly once steady, got %+v", steady)
+		}
+	})
+}
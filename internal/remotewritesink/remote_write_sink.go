@@ -0,0 +1,227 @@
+package remotewritesink
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/golang/snappy"
+	"github.com/renajohn/pac_collector/api"
+	"github.com/renajohn/pac_collector/internal/batcher"
+	"github.com/renajohn/pac_collector/internal/fields"
+	"github.com/renajohn/pac_collector/internal/promexporter"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// Defaults of the batching options
+const (
+	DefaultBatchSize     = 500
+	DefaultFlushInterval = 30 * time.Second
+	DefaultMaxPending    = 50000
+)
+
+// ReservedLabels are set on every series by the sink and cannot be used as external labels
+var ReservedLabels = []string{"__name__", "device", "unit"}
+
+// RemoteWriteSink pushes the numeric fields of the measurements to a Prometheus remote-write endpoint,
+// e.g. Mimir or Thanos. Series are named like the gauges of the promexporter package. Samples are
+// pushed by requests of BatchSize samples, in the background.
+type RemoteWriteSink struct {
+	*batcher.Batcher
+	URL            string
+	Username       string // basic authentication
+	Password       string
+	BearerToken    string
+	ExternalLabels map[string]string // added to every series, e.g. site=home
+
+	client *http.Client
+}
+
+type label struct {
+	name  string
+	value string
+}
+
+type sample struct {
+	labels    []label // sorted by name
+	value     float64
+	timestamp int64 // ms
+}
+
+// NewRemoteWriteSink creates a new sink pushing to the remote-write URL, credentials of the URL being used for basic authentication
+func NewRemoteWriteSink(remoteWriteURL string) (*RemoteWriteSink, error) {
+	parsed, err := url.Parse(remoteWriteURL)
+	if err != nil {
+		return nil, err
+	}
+
+	sink := RemoteWriteSink{
+		ExternalLabels: make(map[string]string),
+		client:         &http.Client{Timeout: 30 * time.Second},
+	}
+	sink.Batcher = batcher.NewBatcher(DefaultBatchSize, DefaultFlushInterval, DefaultMaxPending, sink.writeBatch)
+	if parsed.User != nil {
+		sink.Username = parsed.User.Username()
+		sink.Password, _ = parsed.User.Password()
+		parsed.User = nil
+	}
+	sink.URL = parsed.String()
+
+	return &sink, nil
+}
+
+// CheckLabels returns an error when an external label is one of the ReservedLabels
+func CheckLabels(labels map[string]string) error {
+	for _, name := range ReservedLabels {
+		if _, found := labels[name]; found {
+			return fmt.Errorf("label %q is set by the remote-write sink", name)
+		}
+	}
+	return nil
+}
+
+// Put satisfies the api.Sink interface. Measurements without numeric fields are ignored.
+func (rw *RemoteWriteSink) Put(m api.Measurement) error {
+	values, err := fields.Numeric(m)
+	if err != nil {
+		return nil
+	}
+
+	samples := make([]interface{}, 0, len(values))
+	for _, field := range fields.Names(values) {
+		samples = append(samples, sample{
+			labels:    rw.labels(m, field),
+			value:     values[field],
+			timestamp: m.Timestamp * 1000,
+		})
+	}
+	return rw.Add(samples...)
+}
+
+func (rw *RemoteWriteSink) labels(m api.Measurement, field string) []label {
+	labels := []label{
+		{"__name__", promexporter.MetricName(m.MeasurementType, field)},
+		{"device", m.Device},
+	}
+	if unit := fields.Unit(field); len(unit) > 0 {
+		labels = append(labels, label{"unit", unit})
+	}
+	for name, value := range rw.ExternalLabels {
+		labels = append(labels, label{name, value})
+	}
+
+	sort.Slice(labels, func(i, j int) bool { return labels[i].name < labels[j].name })
+	return labels
+}
+
+// writeBatch pushes the pending samples by requests of BatchSize samples. Requests rejected by the endpoint
+// are dropped, the samples being kept for the next flush when the endpoint cannot be reached or replies
+// with a 5xx or 429 status.
+func (rw *RemoteWriteSink) writeBatch(items []interface{}) ([]interface{}, error) {
+	var firstErr error
+	for len(items) > 0 {
+		size := rw.BatchSize
+		if size > len(items) {
+			size = len(items)
+		}
+
+		samples := make([]sample, size)
+		for i, item := range items[:size] {
+			samples[i] = item.(sample)
+		}
+
+		recoverable, err := rw.post(snappy.Encode(nil, encode(samples)))
+		if err != nil && recoverable {
+			return items, err
+		}
+		if err != nil && firstErr == nil {
+			firstErr = err
+		}
+		items = items[size:]
+	}
+
+	return nil, firstErr
+}
+
+func (rw *RemoteWriteSink) post(body []byte) (bool, error) {
+	request, err := http.NewRequest(http.MethodPost, rw.URL, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	request.Header.Set("Content-Encoding", "snappy")
+	request.Header.Set("Content-Type", "application/x-protobuf")
+	request.Header.Set("User-Agent", "pacmon")
+	request.Header.Set("X-Prometheus-Remote-Write-Version", "0.1.0")
+	if len(rw.BearerToken) > 0 {
+		request.Header.Set("Authorization", "Bearer "+rw.BearerToken)
+	} else if len(rw.Username) > 0 {
+		request.SetBasicAuth(rw.Username, rw.Password)
+	}
+
+	response, err := rw.client.Do(request)
+	if err != nil {
+		return true, err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode/100 == 2 {
+		return false, nil
+	}
+	message, _ := io.ReadAll(response.Body)
+	err = fmt.Errorf("remote write failed with %s: %s", response.Status, strings.TrimSpace(string(message)))
+	return response.StatusCode/100 == 5 || response.StatusCode == http.StatusTooManyRequests, err
+}
+
+// encode marshals the samples as a prometheus.WriteRequest, samples of a same series being grouped
+func encode(samples []sample) []byte {
+	var keys []string
+	series := make(map[string][]sample)
+	for _, s := range samples {
+		var key strings.Builder
+		for _, l := range s.labels {
+			key.WriteString(l.name + "\xff" + l.value + "\xff")
+		}
+		if _, found := series[key.String()]; !found {
+			keys = append(keys, key.String())
+		}
+		series[key.String()] = append(series[key.String()], s)
+	}
+
+	var request []byte
+	for _, key := range keys {
+		var timeSeries []byte
+		for _, l := range series[key][0].labels {
+			var encoded []byte
+			encoded = protowire.AppendTag(encoded, 1, protowire.BytesType)
+			encoded = protowire.AppendString(encoded, l.name)
+			encoded = protowire.AppendTag(encoded, 2, protowire.BytesType)
+			encoded = protowire.AppendString(encoded, l.value)
+
+			timeSeries = protowire.AppendTag(timeSeries, 1, protowire.BytesType)
+			timeSeries = protowire.AppendBytes(timeSeries, encoded)
+		}
+
+		sort.SliceStable(series[key], func(i, j int) bool { return series[key][i].timestamp < series[key][j].timestamp })
+		for _, s := range series[key] {
+			var encoded []byte
+			encoded = protowire.AppendTag(encoded, 1, protowire.Fixed64Type)
+			encoded = protowire.AppendFixed64(encoded, math.Float64bits(s.value))
+			encoded = protowire.AppendTag(encoded, 2, protowire.VarintType)
+			encoded = protowire.AppendVarint(encoded, uint64(s.timestamp))
+
+			timeSeries = protowire.AppendTag(timeSeries, 2, protowire.BytesType)
+			timeSeries = protowire.AppendBytes(timeSeries, encoded)
+		}
+
+		request = protowire.AppendTag(request, 1, protowire.BytesType)
+		request = protowire.AppendBytes(request, timeSeries)
+	}
+
+	return request
+}
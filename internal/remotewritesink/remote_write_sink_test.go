@@ -0,0 +1,161 @@
+package remotewritesink
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/golang/snappy"
+	"github.com/renajohn/pac_collector/api"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// decode returns the series of a WriteRequest as "labels: value@timestamp ..." strings
+func decode(t *testing.T, request []byte) []string {
+	t.Helper()
+
+	var series []string
+	for len(request) > 0 {
+		_, _, n := protowire.ConsumeTag(request)
+		timeSeries, m := protowire.ConsumeBytes(request[n:])
+		request = request[n+m:]
+
+		var labels, samples []string
+		for len(timeSeries) > 0 {
+			number, _, n := protowire.ConsumeTag(timeSeries)
+			message, m := protowire.ConsumeBytes(timeSeries[n:])
+			timeSeries = timeSeries[n+m:]
+			if m < 0 {
+				t.Fatalf("Malformed time series")
+			}
+
+			_, _, n = protowire.ConsumeTag(message)
+			if number == 1 {
+				name, m := protowire.ConsumeString(message[n:])
+				_, _, o := protowire.ConsumeTag(message[n+m:])
+				value, _ := protowire.ConsumeString(message[n+m+o:])
+				labels = append(labels, name+"="+value)
+			} else {
+				bits, m := protowire.ConsumeFixed64(message[n:])
+				_, _, o := protowire.ConsumeTag(message[n+m:])
+				timestamp, _ := protowire.ConsumeVarint(message[n+m+o:])
+				samples = append(samples, fmt.Sprintf("%v@%d", math.Float64frombits(bits), timestamp))
+			}
+		}
+		series = append(series, strings.Join(labels, ",")+": "+strings.Join(samples, " "))
+	}
+
+	return series
+}
+
+type received struct {
+	headers http.Header
+	series  []string
+}
+
+func startReceiver(t *testing.T, statuses ...int) (*httptest.Server, chan received) {
+	requests := make(chan received, 10)
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		compressed, _ := io.ReadAll(r.Body)
+		body, err := snappy.Decode(nil, compressed)
+		if err != nil {
+			t.Errorf("Failed to decode the body: %v", err)
+		}
+		requests <- received{r.Header, decode(t, body)}
+
+		status := http.StatusNoContent
+		if calls < len(statuses) {
+			status = statuses[calls]
+		}
+		calls++
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(server.Close)
+
+	return server, requests
+}
+
+func TestPut(t *testing.T) {
+	first := api.Measurement{MeasurementType: api.SWCTemperature, Device: "swc", Timestamp: 60, Value: []byte(`{"TankTemperature":52.3,"Label":"foo"}`)}
+	second := api.Measurement{MeasurementType: api.SWCTemperature, Device: "swc", Timestamp: 120, Value: []byte(`{"TankTemperature":52.5}`)}
+
+	t.Run("Samples are batched by series with external labels", func(t *testing.T) {
+		server, requests := startReceiver(t)
+		sink, _ := NewRemoteWriteSink(strings.Replace(server.URL, "http://", "http://user:secret@", 1))
+		sink.ExternalLabels["site"] = "home"
+		sink.BatchSize = 2
+
+		sink.Put(first)
+		if len(requests) != 0 {
+			t.Fatalf("No request was expected before the batch is full")
+		}
+		err := sink.Put(second)
+
+		if err != nil {
+			t.Fatalf("No error was expected but got %v", err)
+		}
+		request := <-requests
+		expected := []string{`__name__=pacmon_swc_temperature_tank_temperature,device=swc,site=home,unit=°C: 52.3@60000 52.5@120000`}
+		if !reflect.DeepEqual(expected, request.series) {
+			t.Errorf("Expected %v got %v", expected, request.series)
+		}
+		if user, password, _ := (&http.Request{Header: request.headers}).BasicAuth(); user != "user" || password != "secret" {
+			t.Errorf("Expected basic authentication, got %v", request.headers)
+		}
+		if request.headers.Get("Content-Encoding") != "snappy" || request.headers.Get("X-Prometheus-Remote-Write-Version") != "0.1.0" {
+			t.Errorf("Unexpected headers %v", request.headers)
+		}
+	})
+
+	t.Run("Server errors are retried with the next flush", func(t *testing.T) {
+		server, requests := startReceiver(t, http.StatusServiceUnavailable)
+		sink, _ := NewRemoteWriteSink(server.URL)
+		sink.BearerToken = "token"
+
+		sink.Put(first)
+		err := sink.Flush()
+		if err == nil || sink.Pending() != 1 {
+			t.Fatalf("Expected an error and the samples to be kept, got %v and %d pending", err, sink.Pending())
+		}
+		err = sink.Close()
+
+		if err != nil || len(requests) != 2 {
+			t.Fatalf("Expected the request to succeed on retry, got %v after %d requests", err, len(requests))
+		}
+		if request := <-requests; request.headers.Get("Authorization") != "Bearer token" {
+			t.Errorf("Expected bearer authentication, got %v", request.headers)
+		}
+	})
+
+	t.Run("Rejected batches are dropped", func(t *testing.T) {
+		server, requests := startReceiver(t, http.StatusBadRequest)
+		sink, _ := NewRemoteWriteSink(server.URL)
+
+		sink.Put(first)
+		err := sink.Close()
+
+		if err == nil || len(requests) != 1 || sink.Pending() != 0 {
+			t.Errorf("Expected a single failed request and the samples to be dropped, got %v and %d pending", err, sink.Pending())
+		}
+	})
+}
+
+func TestCheckLabels(t *testing.T) {
+	t.Run("Labels set by the sink are rejected", func(t *testing.T) {
+		if err := CheckLabels(map[string]string{"site": "home", "device": "swc"}); err == nil {
+			t.Error("Expected an error")
+		}
+	})
+
+	t.Run("Other labels are accepted", func(t *testing.T) {
+		if err := CheckLabels(map[string]string{"site": "home"}); err != nil {
+			t.Errorf("Unexpected error %v", err)
+		}
+	})
+}
@@ -0,0 +1,118 @@
+package smtpnotifier
+
+import (
+	"bytes"
+	"crypto/tls"
+	"fmt"
+	"mime"
+	"net"
+	"net/smtp"
+	"strings"
+	"time"
+
+	"github.com/renajohn/pac_collector/internal/alerting"
+)
+
+// DefaultTimeout bounds the delivery of a mail, from dialing the server to quitting
+const DefaultTimeout = 30 * time.Second
+
+// SMTPNotifier emails alerts. STARTTLS is used when the server offers it.
+type SMTPNotifier struct {
+	Address  string // host:port
+	Username string // no authentication when empty
+	Password string
+	From     string
+	To       []string
+	Timeout  time.Duration
+}
+
+// NewSMTPNotifier creates a new SMTPNotifier
+func NewSMTPNotifier(address string, username string, password string, from string, to []string) *SMTPNotifier {
+	notifier := SMTPNotifier{
+		Address:  address,
+		Username: username,
+		Password: password,
+		From:     from,
+		To:       to,
+		Timeout:  DefaultTimeout,
+	}
+
+	return &notifier
+}
+
+// Notify satisfies the alerting.Notifier interface
+func (sn *SMTPNotifier) Notify(alert alerting.Alert) error {
+	host, _, err := net.SplitHostPort(sn.Address)
+	if err != nil {
+		return err
+	}
+
+	// smtp.SendMail has no timeout, a hung server would block the delivery for good
+	connection, err := (&net.Dialer{Timeout: sn.Timeout}).Dial("tcp", sn.Address)
+	if err != nil {
+		return err
+	}
+	defer connection.Close()
+	if err := connection.SetDeadline(time.Now().Add(sn.Timeout)); err != nil {
+		return err
+	}
+
+	client, err := smtp.NewClient(connection, host)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	if ok, _ := client.Extension("STARTTLS"); ok {
+		if err := client.StartTLS(&tls.Config{ServerName: host}); err != nil {
+			return err
+		}
+	}
+	if len(sn.Username) > 0 {
+		if err := client.Auth(smtp.PlainAuth("", sn.Username, sn.Password, host)); err != nil {
+			return err
+		}
+	}
+
+	if err := client.Mail(sn.From); err != nil {
+		return err
+	}
+	for _, to := range sn.To {
+		if err := client.Rcpt(to); err != nil {
+			return err
+		}
+	}
+	writer, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := writer.Write(sn.message(alert)); err != nil {
+		return err
+	}
+	if err := writer.Close(); err != nil {
+		return err
+	}
+
+	return client.Quit()
+}
+
+func (sn *SMTPNotifier) message(alert alerting.Alert) []byte {
+	var message bytes.Buffer
+
+	fmt.Fprintf(&message, "From: %s\r\n", sn.From)
+	fmt.Fprintf(&message, "To: %s\r\n", strings.Join(sn.To, ", "))
+	fmt.Fprintf(&message, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", alert.Summary()))
+	fmt.Fprintf(&message, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	fmt.Fprintf(&message, "Content-Type: text/plain; charset=utf-8\r\n")
+	fmt.Fprintf(&message, "\r\n")
+	fmt.Fprintf(&message, "Rule: %s\r\n", alert.Expression)
+	fmt.Fprintf(&message, "State: %s\r\n", alert.State)
+	fmt.Fprintf(&message, "Device: %s\r\n", alert.Device)
+	fmt.Fprintf(&message, "%s: %v (threshold %v)\r\n", alert.Field, alert.Value, alert.Threshold)
+	fmt.Fprintf(&message, "Since: %s\r\n", time.Unix(alert.FiringSince, 0).Format(time.RFC1123Z))
+	if alert.Repeats > 0 {
+		fmt.Fprintf(&message, "Repeated: %d times\r\n", alert.Repeats)
+	}
+
+	return message.Bytes()
+}
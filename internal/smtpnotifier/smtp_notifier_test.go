@@ -0,0 +1,153 @@
+package smtpnotifier
+
+import (
+	"bufio"
+	"mime"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/renajohn/pac_collector/internal/alerting"
+)
+
+type mail struct {
+	from string
+	to   []string
+	data string
+}
+
+// startSMTPServer runs a bare SMTP stand-in accepting a single mail, without STARTTLS nor authentication
+func startSMTPServer(t *testing.T, mails chan mail) string {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	go func() {
+		defer listener.Close()
+		connection, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer connection.Close()
+
+		reader := bufio.NewReader(connection)
+		reply := func(line string) { connection.Write([]byte(line + "\r\n")) }
+		received := mail{}
+
+		reply("220 localhost ESMTP")
+		for {
+			line, err := reader.ReadString('\n')
+			if err != nil {
+				return
+			}
+			command := strings.ToUpper(strings.TrimSpace(line))
+			switch {
+			case strings.HasPrefix(command, "EHLO"), strings.HasPrefix(command, "HELO"):
+				reply("250 localhost")
+			case strings.HasPrefix(command, "MAIL FROM:"):
+				received.from = strings.Trim(strings.TrimSpace(line)[10:], "<>")
+				reply("250 OK")
+			case strings.HasPrefix(command, "RCPT TO:"):
+				received.to = append(received.to, strings.Trim(strings.TrimSpace(line)[8:], "<>"))
+				reply("250 OK")
+			case command == "DATA":
+				reply("354 Go ahead")
+				var data strings.Builder
+				for {
+					dataLine, err := reader.ReadString('\n')
+					if err != nil || dataLine == ".\r\n" {
+						break
+					}
+					data.WriteString(dataLine)
+				}
+				received.data = data.String()
+				mails <- received
+				reply("250 OK")
+			case command == "QUIT":
+				reply("221 Bye")
+				return
+			default:
+				reply("250 OK")
+			}
+		}
+	}()
+
+	return listener.Addr().String()
+}
+
+func TestNotify(t *testing.T) {
+	t.Run("Alert is emailed to every recipient", func(t *testing.T) {
+		mails := make(chan mail, 1)
+		address := startSMTPServer(t, mails)
+		notifier := NewSMTPNotifier(address, "", "", "pacmon@example.com", []string{"me@example.com", "you@example.com"})
+		alert := alerting.Alert{Rule: "tank", Expression: "TankTemperature < 40", State: alerting.Firing, Device: "swc", Field: "TankTemperature", Value: 39, Threshold: 40}
+
+		err := notifier.Notify(alert)
+
+		if err != nil {
+			t.Fatalf("No error was expected but got %v", err)
+		}
+		received := <-mails
+		if received.from != "pacmon@example.com" || len(received.to) != 2 {
+			t.Errorf("Unexpected envelope %+v", received)
+		}
+		if !strings.Contains(received.data, "Subject: "+alert.Summary()+"\r\n") {
+			t.Errorf("Expected subject to hold the alert summary, got %s", received.data)
+		}
+		if !strings.Contains(received.data, "Rule: TankTemperature < 40\r\n") {
+			t.Errorf("Expected body to hold the rule, got %s", received.data)
+		}
+	})
+
+	t.Run("A subject out of ASCII is encoded", func(t *testing.T) {
+		mails := make(chan mail, 1)
+		address := startSMTPServer(t, mails)
+		notifier := NewSMTPNotifier(address, "", "", "pacmon@example.com", []string{"me@example.com"})
+		alert := alerting.Alert{Rule: "Wärmepumpe", State: alerting.Firing, Device: "swc", Field: "TankTemperature", Value: 39, Threshold: 40}
+
+		if err := notifier.Notify(alert); err != nil {
+			t.Fatalf("No error was expected but got %v", err)
+		}
+		received := <-mails
+		subject := "Subject: " + mime.QEncoding.Encode("utf-8", alert.Summary()) + "\r\n"
+		if !strings.HasPrefix(subject, "Subject: =?utf-8?q?") || !strings.Contains(received.data, subject) {
+			t.Errorf("Expected an encoded subject, got %s", received.data)
+		}
+	})
+
+	t.Run("A server which does not reply times out", func(t *testing.T) {
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { listener.Close() })
+		go func() {
+			connection, err := listener.Accept()
+			if err == nil {
+				t.Cleanup(func() { connection.Close() })
+			}
+		}()
+		notifier := NewSMTPNotifier(listener.Addr().String(), "", "", "pacmon@example.com", []string{"me@example.com"})
+		notifier.Timeout = 50 * time.Millisecond
+
+		start := time.Now()
+		err = notifier.Notify(alerting.Alert{})
+
+		if err == nil || time.Since(start) > time.Second {
+			t.Errorf("Expected a timeout, got %v after %v", err, time.Since(start))
+		}
+	})
+
+	t.Run("When server is unreachable, an error should be returned", func(t *testing.T) {
+		notifier := NewSMTPNotifier("127.0.0.1:1", "", "", "pacmon@example.com", []string{"me@example.com"})
+
+		err := notifier.Notify(alerting.Alert{})
+
+		if err == nil {
+			t.Error("An error was expected and none was returned")
+		}
+	})
+}
@@ -0,0 +1,137 @@
+package sqlitesink
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// MaxRows limits the number of samples returned by a query
+const MaxRows = 100000
+
+// aggregates are the functions samples can be aggregated with when a step is given
+var aggregates = map[string]string{
+	"avg":   "AVG(value)",
+	"min":   "MIN(value)",
+	"max":   "MAX(value)",
+	"sum":   "SUM(value)",
+	"count": "COUNT(value)",
+}
+
+// Sample is a value of a field at a given time
+type Sample struct {
+	Device string  `json:"device"`
+	Type   string  `json:"type"`
+	Field  string  `json:"field"`
+	TS     int64   `json:"ts"`
+	Value  float64 `json:"value"`
+}
+
+// ServeHTTP queries the samples. The device, type and field parameters filter the series, from and to
+// (unix seconds or RFC 3339) the range, the last 24h by default. A step in seconds aggregates the samples
+// with agg (avg, min, max, sum or count). The samples are returned as JSON, or CSV with format=csv.
+func (ss *SQLiteSink) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "only GET is supported", http.StatusMethodNotAllowed)
+		return
+	}
+
+	samples, err := ss.query(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if r.URL.Query().Get("format") == "csv" {
+		w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+		writer := csv.NewWriter(w)
+		writer.Write([]string{"device", "type", "field", "ts", "value"})
+		for _, sample := range samples {
+			writer.Write([]string{sample.Device, sample.Type, sample.Field, strconv.FormatInt(sample.TS, 10), strconv.FormatFloat(sample.Value, 'f', -1, 64)})
+		}
+		writer.Flush()
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(samples)
+}
+
+func (ss *SQLiteSink) query(r *http.Request) ([]Sample, error) {
+	params := r.URL.Query()
+
+	now := time.Now()
+	from, err := parseTime(params.Get("from"), now.Add(-24*time.Hour))
+	if err != nil {
+		return nil, err
+	}
+	to, err := parseTime(params.Get("to"), now)
+	if err != nil {
+		return nil, err
+	}
+
+	conditions := []string{"ts >= ?", "ts <= ?"}
+	args := []interface{}{from, to}
+	for _, column := range []string{"device", "type", "field"} {
+		if value := params.Get(column); len(value) > 0 {
+			conditions = append(conditions, column+" = ?")
+			args = append(args, value)
+		}
+	}
+
+	query := "SELECT device, type, field, ts, value FROM measurements WHERE " + strings.Join(conditions, " AND ") + " ORDER BY device, type, field, ts"
+	if step := params.Get("step"); len(step) > 0 {
+		seconds, err := strconv.ParseInt(step, 10, 64)
+		if err != nil || seconds < 1 {
+			return nil, fmt.Errorf("invalid step %q", step)
+		}
+		agg := params.Get("agg")
+		if len(agg) == 0 {
+			agg = "avg"
+		}
+		function, found := aggregates[agg]
+		if !found {
+			return nil, fmt.Errorf("invalid aggregate %q", agg)
+		}
+
+		query = fmt.Sprintf("SELECT device, type, field, ts / %d * %d AS bucket, %s FROM measurements WHERE %s "+
+			"GROUP BY device, type, field, bucket ORDER BY device, type, field, bucket", seconds, seconds, function, strings.Join(conditions, " AND "))
+	}
+
+	rows, err := ss.db.Query(query+fmt.Sprintf(" LIMIT %d", MaxRows), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	samples := []Sample{}
+	for rows.Next() {
+		var sample Sample
+		if err := rows.Scan(&sample.Device, &sample.Type, &sample.Field, &sample.TS, &sample.Value); err != nil {
+			return nil, err
+		}
+		samples = append(samples, sample)
+	}
+
+	return samples, rows.Err()
+}
+
+// parseTime parses unix seconds or RFC 3339 times
+func parseTime(value string, defaultTime time.Time) (int64, error) {
+	if len(value) == 0 {
+		return defaultTime.Unix(), nil
+	}
+	if seconds, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return seconds, nil
+	}
+
+	parsed, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time %q, expecting unix seconds or RFC 3339", value)
+	}
+	return parsed.Unix(), nil
+}
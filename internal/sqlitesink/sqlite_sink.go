@@ -0,0 +1,220 @@
+package sqlitesink
+
+import (
+	"database/sql"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/renajohn/pac_collector/api"
+	"github.com/renajohn/pac_collector/internal/fields"
+	_ "modernc.org/sqlite" // pure Go driver
+)
+
+// Defaults of the maintenance jobs
+const (
+	DefaultDownsampleInterval = 5 * time.Minute
+	DefaultJobInterval        = time.Hour
+)
+
+const schema = `
+CREATE TABLE IF NOT EXISTS series (
+	id     INTEGER PRIMARY KEY,
+	device TEXT NOT NULL,
+	type   TEXT NOT NULL,
+	field  TEXT NOT NULL,
+	UNIQUE (device, type, field)
+);
+CREATE TABLE IF NOT EXISTS samples (
+	series_id INTEGER NOT NULL REFERENCES series (id),
+	ts        INTEGER NOT NULL,
+	value     REAL NOT NULL,
+	PRIMARY KEY (series_id, ts)
+) WITHOUT ROWID;
+CREATE INDEX IF NOT EXISTS samples_ts ON samples (ts);
+CREATE TABLE IF NOT EXISTS meta (
+	key   TEXT PRIMARY KEY,
+	value INTEGER NOT NULL
+);
+CREATE VIEW IF NOT EXISTS measurements AS
+	SELECT device, type, field, ts, value FROM samples JOIN series ON series.id = samples.series_id;
+`
+
+// SQLiteSink keeps the numeric fields of the measurements in an embedded SQLite database, one row
+// per field and timestamp. Old samples are periodically averaged into coarser buckets, then deleted.
+type SQLiteSink struct {
+	Retention          time.Duration // age after which samples are deleted, 0 to keep them
+	DownsampleAfter    time.Duration // age after which samples are averaged, 0 to disable
+	DownsampleInterval time.Duration // size of the buckets samples are averaged into
+
+	db     *sql.DB
+	mutex  sync.Mutex
+	series map[seriesKey]int64
+	stop   chan struct{}
+}
+
+type seriesKey struct {
+	device          string
+	measurementType api.MeasurementType
+	field           string
+}
+
+// NewSQLiteSink opens or creates the database at path
+func NewSQLiteSink(path string) (*SQLiteSink, error) {
+	db, err := sql.Open("sqlite", path+"?_pragma=journal_mode(WAL)&_pragma=busy_timeout(5000)")
+	if err != nil {
+		return nil, err
+	}
+	// a single connection serializes the writes, readers of the HTTP API waiting on the busy timeout
+	db.SetMaxOpenConns(1)
+
+	if _, err := db.Exec(schema); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	sink := SQLiteSink{
+		DownsampleInterval: DefaultDownsampleInterval,
+		db:                 db,
+		series:             make(map[seriesKey]int64),
+	}
+	return &sink, nil
+}
+
+// Put satisfies the api.Sink interface. Measurements without numeric fields are ignored.
+func (ss *SQLiteSink) Put(m api.Measurement) error {
+	values, err := fields.Numeric(m)
+	if err != nil || len(values) == 0 {
+		return nil
+	}
+
+	ss.mutex.Lock()
+	defer ss.mutex.Unlock()
+
+	tx, err := ss.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, field := range fields.Names(values) {
+		id, err := ss.seriesID(tx, seriesKey{m.Device, m.MeasurementType, field})
+		if err != nil {
+			return err
+		}
+		_, err = tx.Exec("INSERT OR REPLACE INTO samples (series_id, ts, value) VALUES (?, ?, ?)", id, m.Timestamp, values[field])
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (ss *SQLiteSink) seriesID(tx *sql.Tx, key seriesKey) (int64, error) {
+	if id, found := ss.series[key]; found {
+		return id, nil
+	}
+
+	_, err := tx.Exec("INSERT OR IGNORE INTO series (device, type, field) VALUES (?, ?, ?)", key.device, key.measurementType, key.field)
+	if err != nil {
+		return 0, err
+	}
+
+	var id int64
+	err = tx.QueryRow("SELECT id FROM series WHERE device = ? AND type = ? AND field = ?", key.device, key.measurementType, key.field).Scan(&id)
+	if err != nil {
+		return 0, err
+	}
+
+	ss.series[key] = id
+	return id, nil
+}
+
+// StartJobs runs the retention and downsampling jobs every interval until Close
+func (ss *SQLiteSink) StartJobs(interval time.Duration) {
+	ss.mutex.Lock()
+	defer ss.mutex.Unlock()
+
+	if ss.stop != nil {
+		return
+	}
+	ss.stop = make(chan struct{})
+
+	go func(stop chan struct{}) {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			if err := ss.RunJobs(time.Now()); err != nil {
+				log.Printf("SQLite maintenance failed: %v", err)
+			}
+
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+			}
+		}
+	}(ss.stop)
+}
+
+// RunJobs deletes the samples beyond the retention and averages the ones older than DownsampleAfter.
+// Only the samples since the previous run are averaged, the cutoff being kept in the meta table.
+func (ss *SQLiteSink) RunJobs(now time.Time) error {
+	ss.mutex.Lock()
+	defer ss.mutex.Unlock()
+
+	tx, err := ss.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if ss.Retention > 0 {
+		if _, err := tx.Exec("DELETE FROM samples WHERE ts < ?", now.Add(-ss.Retention).Unix()); err != nil {
+			return err
+		}
+	}
+
+	if ss.DownsampleAfter > 0 && ss.DownsampleInterval > 0 {
+		bucket := int64(ss.DownsampleInterval / time.Second)
+		// only complete buckets are averaged, so that running the job again does not change them
+		cutoff := now.Add(-ss.DownsampleAfter).Unix() / bucket * bucket
+
+		// the samples before the previous cutoff were already averaged, the history is not read again
+		var since int64
+		err := tx.QueryRow("SELECT value FROM meta WHERE key = 'downsampled_until'").Scan(&since)
+		if err != nil && err != sql.ErrNoRows {
+			return err
+		}
+
+		if cutoff > since {
+			_, err = tx.Exec(`INSERT OR REPLACE INTO samples (series_id, ts, value)
+				SELECT series_id, ts / ?1 * ?1, AVG(value) FROM samples WHERE ts >= ?2 AND ts < ?3 GROUP BY series_id, ts / ?1`, bucket, since, cutoff)
+			if err != nil {
+				return err
+			}
+			if _, err := tx.Exec("DELETE FROM samples WHERE ts >= ? AND ts < ? AND ts % ? != 0", since, cutoff, bucket); err != nil {
+				return err
+			}
+			if _, err := tx.Exec("INSERT OR REPLACE INTO meta (key, value) VALUES ('downsampled_until', ?)", cutoff); err != nil {
+				return err
+			}
+		}
+	}
+
+	return tx.Commit()
+}
+
+// Close stops the jobs and closes the database
+func (ss *SQLiteSink) Close() error {
+	ss.mutex.Lock()
+	if ss.stop != nil {
+		close(ss.stop)
+		ss.stop = nil
+	}
+	ss.mutex.Unlock()
+
+	return ss.db.Close()
+}
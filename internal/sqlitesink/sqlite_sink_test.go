@@ -0,0 +1,154 @@
+package sqlitesink
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/renajohn/pac_collector/api"
+)
+
+func newSink(t *testing.T) *SQLiteSink {
+	t.Helper()
+	sink, err := NewSQLiteSink(filepath.Join(t.TempDir(), "pacmon.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { sink.Close() })
+	return sink
+}
+
+func temperature(timestamp int64, value float64) api.Measurement {
+	return api.Measurement{
+		MeasurementType: api.SWCTemperature,
+		Device:          "swc",
+		Timestamp:       timestamp,
+		Value:           []byte(fmt.Sprintf(`{"TankTemperature":%v,"Label":"foo"}`, value)),
+	}
+}
+
+func get(t *testing.T, sink *SQLiteSink, query string) ([]Sample, int) {
+	t.Helper()
+	recorder := httptest.NewRecorder()
+	sink.ServeHTTP(recorder, httptest.NewRequest("GET", "/history?"+query, nil))
+
+	var samples []Sample
+	if recorder.Code == 200 {
+		if err := json.Unmarshal(recorder.Body.Bytes(), &samples); err != nil {
+			t.Fatalf("Failed to decode %s: %v", recorder.Body, err)
+		}
+	}
+	return samples, recorder.Code
+}
+
+func TestServeHTTP(t *testing.T) {
+	sink := newSink(t)
+	for _, m := range []api.Measurement{temperature(0, 50), temperature(60, 52), temperature(300, 54)} {
+		if err := sink.Put(m); err != nil {
+			t.Fatalf("No error was expected but got %v", err)
+		}
+	}
+	sink.Put(api.Measurement{MeasurementType: api.SWCOutputs, Device: "swc", Timestamp: 60, Value: []byte(`{"Compressor":true}`)})
+
+	t.Run("Samples are returned as JSON", func(t *testing.T) {
+		samples, _ := get(t, sink, "from=0&to=1000&field=TankTemperature")
+
+		expected := []Sample{
+			{"swc", "SWCTemperature", "TankTemperature", 0, 50},
+			{"swc", "SWCTemperature", "TankTemperature", 60, 52},
+			{"swc", "SWCTemperature", "TankTemperature", 300, 54},
+		}
+		if !reflect.DeepEqual(expected, samples) {
+			t.Errorf("Expected %v got %v", expected, samples)
+		}
+	})
+
+	t.Run("Samples are aggregated by step", func(t *testing.T) {
+		samples, _ := get(t, sink, "from=0&to=1000&type=SWCTemperature&step=300&agg=max")
+
+		expected := []Sample{
+			{"swc", "SWCTemperature", "TankTemperature", 0, 52},
+			{"swc", "SWCTemperature", "TankTemperature", 300, 54},
+		}
+		if !reflect.DeepEqual(expected, samples) {
+			t.Errorf("Expected %v got %v", expected, samples)
+		}
+	})
+
+	t.Run("Samples are returned as CSV", func(t *testing.T) {
+		recorder := httptest.NewRecorder()
+		sink.ServeHTTP(recorder, httptest.NewRequest("GET", "/history?from=1970-01-01T00:01:00Z&to=60&format=csv", nil))
+
+		expected := "device,type,field,ts,value\nswc,SWCOutputs,Compressor,60,1\nswc,SWCTemperature,TankTemperature,60,52\n"
+		if recorder.Body.String() != expected {
+			t.Errorf("Expected\n%s\ngot\n%s", expected, recorder.Body)
+		}
+	})
+
+	t.Run("should error out when the aggregate is unknown", func(t *testing.T) {
+		if _, code := get(t, sink, "step=60&agg=median"); code != 400 {
+			t.Errorf("Expected a bad request, got %d", code)
+		}
+	})
+}
+
+func TestRunJobs(t *testing.T) {
+	t.Run("Old samples are averaged, then deleted", func(t *testing.T) {
+		sink := newSink(t)
+		sink.DownsampleAfter = time.Hour
+		sink.DownsampleInterval = 5 * time.Minute
+		sink.Retention = 24 * time.Hour
+		for _, m := range []api.Measurement{temperature(0, 50), temperature(60, 52), temperature(300, 54), temperature(7200, 56)} {
+			sink.Put(m)
+		}
+
+		for i := 0; i < 2; i++ {
+			if err := sink.RunJobs(time.Unix(7200, 0)); err != nil {
+				t.Fatalf("No error was expected but got %v", err)
+			}
+		}
+
+		samples, _ := get(t, sink, "from=0&to=10000")
+		expected := []Sample{
+			{"swc", "SWCTemperature", "TankTemperature", 0, 51},
+			{"swc", "SWCTemperature", "TankTemperature", 300, 54},
+			{"swc", "SWCTemperature", "TankTemperature", 7200, 56},
+		}
+		if !reflect.DeepEqual(expected, samples) {
+			t.Errorf("Expected %v got %v", expected, samples)
+		}
+
+		sink.RunJobs(time.Unix(7200+24*3600, 0))
+		if samples, _ := get(t, sink, "from=0&to=10000"); len(samples) != 1 {
+			t.Errorf("Expected samples beyond the retention to be deleted, got %v", samples)
+		}
+	})
+
+	t.Run("Samples averaged by a previous run are not read again", func(t *testing.T) {
+		sink := newSink(t)
+		sink.DownsampleAfter = time.Hour
+		sink.DownsampleInterval = 5 * time.Minute
+		for _, m := range []api.Measurement{temperature(0, 50), temperature(60, 52), temperature(3600, 54), temperature(3660, 56)} {
+			sink.Put(m)
+		}
+
+		sink.RunJobs(time.Unix(7200, 0))
+		// a late sample before the previous cutoff is left as it is
+		sink.Put(temperature(120, 60))
+		sink.RunJobs(time.Unix(7800, 0))
+
+		samples, _ := get(t, sink, "from=0&to=10000")
+		expected := []Sample{
+			{"swc", "SWCTemperature", "TankTemperature", 0, 51},
+			{"swc", "SWCTemperature", "TankTemperature", 120, 60},
+			{"swc", "SWCTemperature", "TankTemperature", 3600, 55},
+		}
+		if !reflect.DeepEqual(expected, samples) {
+			t.Errorf("Expected %v got %v", expected, samples)
+		}
+	})
+}
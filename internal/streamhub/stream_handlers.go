@@ -0,0 +1,120 @@
+package streamhub
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// keepAliveInterval is the interval at which idle streams are kept alive
+const keepAliveInterval = 15 * time.Second
+
+// writeTimeout bounds the time a WebSocket client has to accept a message
+const writeTimeout = 10 * time.Second
+
+var upgrader = websocket.Upgrader{ReadBufferSize: 1024, WriteBufferSize: 4096}
+
+// serveEvents streams the events as Server-Sent Events until the client goes away or falls behind
+func (h *Hub) serveEvents(w http.ResponseWriter, r *http.Request, filter Filter, lastID uint64, resume bool) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming is not supported", http.StatusInternalServerError)
+		return
+	}
+
+	s, backlog := h.Subscribe(filter, lastID, resume)
+	defer h.Unsubscribe(s)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	for _, event := range backlog {
+		writeEvent(w, event)
+	}
+	flusher.Flush()
+
+	keepAlive := time.NewTicker(keepAliveInterval)
+	defer keepAlive.Stop()
+
+	for {
+		select {
+		case event, ok := <-s.events:
+			if !ok {
+				return
+			}
+			writeEvent(w, event)
+			flusher.Flush()
+		case <-keepAlive.C:
+			fmt.Fprint(w, ": keep-alive\n\n")
+			flusher.Flush()
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+func writeEvent(w http.ResponseWriter, event Event) {
+	data, _ := json.Marshal(event)
+	fmt.Fprintf(w, "id: %d\nevent: measurement\ndata: %s\n\n", event.ID, data)
+}
+
+// serveWebSocket sends the events as JSON text messages until the client goes away or falls behind,
+// slow clients being closed with the try again later code
+func (h *Hub) serveWebSocket(w http.ResponseWriter, r *http.Request, filter Filter, lastID uint64, resume bool) {
+	ws, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+	defer ws.Close()
+
+	s, backlog := h.Subscribe(filter, lastID, resume)
+	defer h.Unsubscribe(s)
+
+	// the messages of the client are discarded, reading being required to notice it went away
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		for {
+			if _, _, err := ws.NextReader(); err != nil {
+				return
+			}
+		}
+	}()
+
+	for _, event := range backlog {
+		if err := writeMessage(ws, event); err != nil {
+			return
+		}
+	}
+
+	keepAlive := time.NewTicker(keepAliveInterval)
+	defer keepAlive.Stop()
+
+	for {
+		select {
+		case event, ok := <-s.events:
+			if !ok {
+				ws.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseTryAgainLater, "client too slow"),
+					time.Now().Add(writeTimeout))
+				return
+			}
+			if err := writeMessage(ws, event); err != nil {
+				return
+			}
+		case <-keepAlive.C:
+			if err := ws.WriteControl(websocket.PingMessage, nil, time.Now().Add(writeTimeout)); err != nil {
+				return
+			}
+		case <-closed:
+			return
+		}
+	}
+}
+
+func writeMessage(ws *websocket.Conn, event Event) error {
+	ws.SetWriteDeadline(time.Now().Add(writeTimeout))
+	return ws.WriteJSON(event)
+}
@@ -0,0 +1,136 @@
+package streamhub
+
+import (
+	"bufio"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/renajohn/pac_collector/api"
+)
+
+// waitForSubscribers waits until the clients are subscribed
+func waitForSubscribers(hub *Hub, count int) {
+	for {
+		hub.mutex.Lock()
+		subscribed := len(hub.subscribers)
+		hub.mutex.Unlock()
+		if subscribed >= count {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestServeEvents(t *testing.T) {
+	t.Run("Events are resumed after the last event ID, then streamed", func(t *testing.T) {
+		hub := NewHub(10)
+		server := httptest.NewServer(hub)
+		defer server.Close()
+
+		hub.Process(measurement(api.SWCTemperature, "swc", 1))
+		hub.Process(measurement(api.SWCTemperature, "swc", 2))
+
+		request, _ := http.NewRequest("GET", server.URL+"/stream?type=SWCTemperature,SWCDerived&device=swc", nil)
+		request.Header.Set("Last-Event-ID", "1")
+		response, err := server.Client().Do(request)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer response.Body.Close()
+
+		waitForSubscribers(hub, 1)
+		hub.Process(measurement(api.SWCTemperature, "other", 3))
+		hub.Process(measurement(api.SWCTemperature, "swc", 4))
+
+		reader := bufio.NewReader(response.Body)
+		var lines []string
+		for len(lines) < 8 {
+			line, err := reader.ReadString('\n')
+			if err != nil {
+				t.Fatal(err)
+			}
+			lines = append(lines, line)
+		}
+
+		expected := "id: 2\nevent: measurement\n" +
+			`data: {"id":2,"type":"SWCTemperature","device":"swc","timestamp":2,"value":{"TankTemperature":2}}` + "\n\n" +
+			"id: 4\nevent: measurement\n" +
+			`data: {"id":4,"type":"SWCTemperature","device":"swc","timestamp":4,"value":{"TankTemperature":4}}` + "\n\n"
+		if strings.Join(lines, "") != expected {
+			t.Errorf("Expected\n%s\ngot\n%s", expected, strings.Join(lines, ""))
+		}
+	})
+
+	t.Run("should error out when the last event ID is invalid", func(t *testing.T) {
+		recorder := httptest.NewRecorder()
+		NewHub(10).ServeHTTP(recorder, httptest.NewRequest("GET", "/stream?lastEventId=foo", nil))
+
+		if recorder.Code != http.StatusBadRequest {
+			t.Errorf("Expected a bad request, got %d", recorder.Code)
+		}
+	})
+}
+
+func TestServeWebSocket(t *testing.T) {
+	t.Run("Events are sent as JSON messages", func(t *testing.T) {
+		hub := NewHub(10)
+		server := httptest.NewServer(hub)
+		defer server.Close()
+
+		hub.Process(measurement(api.SWCTemperature, "swc", 1))
+
+		ws, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http")+"/stream/ws?lastEventId=0&type=SWCTemperature", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer ws.Close()
+
+		waitForSubscribers(hub, 1)
+		hub.Process(measurement(api.SWCOutputs, "swc", 2))
+		hub.Process(measurement(api.SWCTemperature, "swc", 3))
+
+		for _, id := range []uint64{1, 3} {
+			var event Event
+			if err := ws.ReadJSON(&event); err != nil {
+				t.Fatal(err)
+			}
+			if event.ID != id || event.Type != api.SWCTemperature {
+				t.Errorf("Expected event %d, got %+v", id, event)
+			}
+		}
+	})
+
+	t.Run("Slow clients are closed", func(t *testing.T) {
+		hub := NewHub(10)
+		server := httptest.NewServer(hub)
+		defer server.Close()
+
+		ws, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http")+"/stream/ws", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer ws.Close()
+
+		waitForSubscribers(hub, 1)
+		// drops the subscriber as if its buffer was full
+		hub.mutex.Lock()
+		for s := range hub.subscribers {
+			delete(hub.subscribers, s)
+			close(s.events)
+		}
+		hub.mutex.Unlock()
+
+		for {
+			if _, _, err = ws.ReadMessage(); err != nil {
+				break
+			}
+		}
+		if !websocket.IsCloseError(err, websocket.CloseTryAgainLater) {
+			t.Errorf("Expected the client to be closed as too slow, got %v", err)
+		}
+	})
+}
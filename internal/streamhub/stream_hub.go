@@ -0,0 +1,236 @@
+package streamhub
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/renajohn/pac_collector/api"
+)
+
+// Defaults of the buffers
+const (
+	DefaultBufferSize       = 1000
+	DefaultSubscriberBuffer = 256
+)
+
+// Event is a measurement as streamed to the subscribers, identified by a sequence number
+type Event struct {
+	ID        uint64              `json:"id"`
+	Type      api.MeasurementType `json:"type"`
+	Device    string              `json:"device"`
+	Timestamp int64               `json:"timestamp"`
+	Value     json.RawMessage     `json:"value"`
+	Tags      map[string]string   `json:"tags,omitempty"`
+}
+
+// Filter selects the events of a subscriber, an empty set matching everything
+type Filter struct {
+	Types   map[api.MeasurementType]bool
+	Devices map[string]bool
+}
+
+func (f Filter) matches(e Event) bool {
+	return (len(f.Types) == 0 || f.Types[e.Type]) && (len(f.Devices) == 0 || f.Devices[e.Device])
+}
+
+// Hub streams the measurements to HTTP clients as Server-Sent Events and over WebSocket. The latest events
+// are kept in a ring buffer so that clients can resume after a disconnection. Clients not reading fast enough
+// are disconnected rather than slowing the collector down. It observes the measurements as a pass-through api.Processor.
+type Hub struct {
+	SubscriberBuffer int // events queued per client before it is disconnected
+
+	mutex       sync.Mutex
+	lastID      uint64
+	ring        []Event
+	next        int // index of the ring slot written next
+	full        bool
+	latest      map[latestKey]Event
+	subscribers map[*Subscription]bool
+}
+
+type latestKey struct {
+	measurementType api.MeasurementType
+	device          string
+}
+
+// Subscription receives the events matching its filter
+type Subscription struct {
+	filter Filter
+	events chan Event
+}
+
+// Events returns the events of the subscription, the channel being closed when the subscriber is too slow
+func (s *Subscription) Events() <-chan Event {
+	return s.events
+}
+
+// NewHub creates a new Hub keeping the latest bufferSize events
+func NewHub(bufferSize int) *Hub {
+	if bufferSize < 1 {
+		bufferSize = DefaultBufferSize
+	}
+
+	hub := Hub{
+		SubscriberBuffer: DefaultSubscriberBuffer,
+		ring:             make([]Event, bufferSize),
+		latest:           make(map[latestKey]Event),
+		subscribers:      make(map[*Subscription]bool),
+	}
+
+	return &hub
+}
+
+// Process satisfies the api.Processor interface, streaming the measurement to the subscribers
+func (h *Hub) Process(m api.Measurement) []api.Measurement {
+	if !json.Valid(m.Value) {
+		return []api.Measurement{m}
+	}
+
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	h.lastID++
+	event := Event{ID: h.lastID, Type: m.MeasurementType, Device: m.Device, Timestamp: m.Timestamp, Value: m.Value, Tags: m.Tags}
+
+	h.ring[h.next] = event
+	h.next = (h.next + 1) % len(h.ring)
+	h.full = h.full || h.next == 0
+	h.latest[latestKey{m.MeasurementType, m.Device}] = event
+
+	for s := range h.subscribers {
+		if !s.filter.matches(event) {
+			continue
+		}
+		select {
+		case s.events <- event:
+		default:
+			// too slow, the client is expected to reconnect and resume from its last event
+			delete(h.subscribers, s)
+			close(s.events)
+		}
+	}
+
+	return []api.Measurement{m}
+}
+
+// Subscribe registers a subscriber, returning the buffered events following lastID when resuming
+func (h *Hub) Subscribe(filter Filter, lastID uint64, resume bool) (*Subscription, []Event) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	var backlog []Event
+	if resume {
+		for _, event := range h.buffered() {
+			if event.ID > lastID && filter.matches(event) {
+				backlog = append(backlog, event)
+			}
+		}
+	}
+
+	s := &Subscription{filter: filter, events: make(chan Event, h.SubscriberBuffer)}
+	h.subscribers[s] = true
+
+	return s, backlog
+}
+
+// Unsubscribe stops sending events to the subscriber
+func (h *Hub) Unsubscribe(s *Subscription) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	if h.subscribers[s] {
+		delete(h.subscribers, s)
+		close(s.events)
+	}
+}
+
+// Latest returns the latest event of every type and device matching the filter
+func (h *Hub) Latest(filter Filter) []Event {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	latest := []Event{}
+	for _, event := range h.latest {
+		if filter.matches(event) {
+			latest = append(latest, event)
+		}
+	}
+	sort.Slice(latest, func(i, j int) bool {
+		if latest[i].Type != latest[j].Type {
+			return latest[i].Type < latest[j].Type
+		}
+		return latest[i].Device < latest[j].Device
+	})
+
+	return latest
+}
+
+// buffered returns the events of the ring buffer, oldest first
+func (h *Hub) buffered() []Event {
+	if !h.full {
+		return append([]Event(nil), h.ring[:h.next]...)
+	}
+	return append(append([]Event(nil), h.ring[h.next:]...), h.ring[:h.next]...)
+}
+
+// ServeHTTP streams the events over WebSocket when the path ends with /ws, as Server-Sent Events otherwise.
+// The type and device parameters, comma separated or repeated, filter the events. The lastEventId parameter,
+// or the Last-Event-ID header, resumes the stream after the given event.
+func (h *Hub) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "only GET is supported", http.StatusMethodNotAllowed)
+		return
+	}
+
+	query := r.URL.Query()
+	filter := ParseFilter(query)
+
+	lastEventID := r.Header.Get("Last-Event-ID")
+	if len(lastEventID) == 0 {
+		lastEventID = query.Get("lastEventId")
+	}
+	var lastID uint64
+	if len(lastEventID) > 0 {
+		var err error
+		lastID, err = strconv.ParseUint(lastEventID, 10, 64)
+		if err != nil {
+			http.Error(w, "invalid last event ID "+strconv.Quote(lastEventID), http.StatusBadRequest)
+			return
+		}
+	}
+
+	if strings.HasSuffix(r.URL.Path, "/ws") {
+		h.serveWebSocket(w, r, filter, lastID, len(lastEventID) > 0)
+		return
+	}
+	h.serveEvents(w, r, filter, lastID, len(lastEventID) > 0)
+}
+
+// ParseFilter returns the filter of the type and device query parameters, comma separated or repeated
+func ParseFilter(query url.Values) Filter {
+	filter := Filter{Types: make(map[api.MeasurementType]bool), Devices: make(map[string]bool)}
+	for _, name := range splitValues(query["type"]) {
+		filter.Types[api.MeasurementType(name)] = true
+	}
+	for _, name := range splitValues(query["device"]) {
+		filter.Devices[name] = true
+	}
+	return filter
+}
+
+func splitValues(values []string) []string {
+	var split []string
+	for _, value := range values {
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); len(item) > 0 {
+				split = append(split, item)
+			}
+		}
+	}
+	return split
+}
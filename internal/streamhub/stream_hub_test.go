@@ -0,0 +1,97 @@
+package streamhub
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/renajohn/pac_collector/api"
+)
+
+func measurement(measurementType api.MeasurementType, device string, timestamp int64) api.Measurement {
+	return api.Measurement{
+		MeasurementType: measurementType,
+		Device:          device,
+		Timestamp:       timestamp,
+		Value:           []byte(fmt.Sprintf(`{"TankTemperature":%d}`, timestamp)),
+	}
+}
+
+func ids(events []Event) []uint64 {
+	var ids []uint64
+	for _, event := range events {
+		ids = append(ids, event.ID)
+	}
+	return ids
+}
+
+func TestProcess(t *testing.T) {
+	t.Run("Matching events are sent to the subscribers", func(t *testing.T) {
+		hub := NewHub(10)
+		s, _ := hub.Subscribe(Filter{Types: map[api.MeasurementType]bool{api.SWCTemperature: true}}, 0, false)
+
+		hub.Process(measurement(api.SWCOutputs, "swc", 1))
+		if result := hub.Process(measurement(api.SWCTemperature, "swc", 2)); len(result) != 1 {
+			t.Errorf("Expected the measurement to be passed through, got %v", result)
+		}
+
+		event := <-s.events
+		if event.ID != 2 || event.Type != api.SWCTemperature || string(event.Value) != `{"TankTemperature":2}` {
+			t.Errorf("Unexpected event %+v", event)
+		}
+		if len(s.events) != 0 {
+			t.Errorf("Expected the outputs to be filtered out, got %v", <-s.events)
+		}
+	})
+
+	t.Run("Subscribers resume from the ring buffer", func(t *testing.T) {
+		hub := NewHub(3)
+		for timestamp := int64(1); timestamp <= 5; timestamp++ {
+			hub.Process(measurement(api.SWCTemperature, fmt.Sprint("swc", timestamp%2), timestamp))
+		}
+
+		if _, backlog := hub.Subscribe(Filter{}, 3, true); fmt.Sprint(ids(backlog)) != "[4 5]" {
+			t.Errorf("Expected the events following 3, got %v", ids(backlog))
+		}
+		if _, backlog := hub.Subscribe(Filter{}, 0, true); fmt.Sprint(ids(backlog)) != "[3 4 5]" {
+			t.Errorf("Expected the buffered events, got %v", ids(backlog))
+		}
+		if _, backlog := hub.Subscribe(Filter{Devices: map[string]bool{"swc1": true}}, 0, true); fmt.Sprint(ids(backlog)) != "[3 5]" {
+			t.Errorf("Expected the buffered events of the device, got %v", ids(backlog))
+		}
+		if _, backlog := hub.Subscribe(Filter{}, 0, false); len(backlog) != 0 {
+			t.Errorf("Expected no backlog without resuming, got %v", ids(backlog))
+		}
+	})
+
+	t.Run("Slow subscribers are disconnected", func(t *testing.T) {
+		hub := NewHub(10)
+		hub.SubscriberBuffer = 2
+		s, _ := hub.Subscribe(Filter{}, 0, false)
+
+		for timestamp := int64(1); timestamp <= 3; timestamp++ {
+			hub.Process(measurement(api.SWCTemperature, "swc", timestamp))
+		}
+
+		<-s.events
+		<-s.events
+		if _, ok := <-s.events; ok {
+			t.Error("Expected the subscriber to be disconnected")
+		}
+		hub.Unsubscribe(s)
+	})
+}
+
+func TestLatest(t *testing.T) {
+	hub := NewHub(2)
+	for timestamp := int64(1); timestamp <= 4; timestamp++ {
+		hub.Process(measurement(api.SWCTemperature, fmt.Sprint("swc", timestamp%2), timestamp))
+	}
+	hub.Process(measurement(api.SWCOutputs, "swc0", 5))
+
+	if latest := hub.Latest(Filter{}); fmt.Sprint(ids(latest)) != "[5 4 3]" {
+		t.Errorf("Expected the latest event of every type and device, got %v", ids(latest))
+	}
+	if latest := hub.Latest(Filter{Types: map[api.MeasurementType]bool{api.SWCTemperature: true}}); fmt.Sprint(ids(latest)) != "[4 3]" {
+		t.Errorf("Expected the latest temperatures, got %v", ids(latest))
+	}
+}
@@ -0,0 +1,250 @@
+package swcsource
+
+import (
+	"encoding/xml"
+	"errors"
+	"sync"
+	"time"
+)
+
+// States of the session
+const (
+	Connecting   = "connecting"
+	Connected    = "connected"
+	Paused       = "paused"
+	Disconnected = "disconnected"
+)
+
+// MinPollInterval is the shortest polling interval which can be set at runtime
+const MinPollInterval = time.Second
+
+// maxRestartDelay bounds the back-off between the restarts of a session which keeps failing
+const maxRestartDelay = time.Minute
+
+// SessionStatus describes the current session, times being unix seconds
+type SessionStatus struct {
+	State         string `json:"state"`
+	PollInterval  int64  `json:"pollInterval"` // seconds
+	LastPoll      int64  `json:"lastPoll,omitempty"`
+	Restarts      int64  `json:"restarts"`
+	LastError     string `json:"lastError,omitempty"`
+	LastErrorTime int64  `json:"lastErrorTime,omitempty"`
+	DownSince     int64  `json:"downSince,omitempty"` // first failure since the session last logged in
+}
+
+// NavigationItem is an entry of the menu of the SWC, pages being the leaves
+type NavigationItem struct {
+	ID    string           `json:"id"`
+	Name  string           `json:"name"`
+	Items []NavigationItem `json:"items,omitempty"`
+}
+
+// control is shared by a source and its sessions, so that the polling can be changed at runtime
+// and the state of the session survives its restarts
+type control struct {
+	mutex         sync.Mutex
+	pollInterval  time.Duration // overrides the interval of the sessions when set
+	paused        bool
+	connected     bool
+	lastPoll      time.Time
+	lastError     error
+	lastErrorTime time.Time
+	downSince     time.Time // zero while logged in
+	navigation    []NavigationItem
+
+	refreshes   chan struct{} // immediate refresh requests
+	rescheduled chan struct{} // polling interval or pause changes
+	reconnects  chan struct{} // settings changes requiring a new session
+}
+
+func newControl() *control {
+	return &control{
+		refreshes:   make(chan struct{}, 1),
+		rescheduled: make(chan struct{}, 1),
+		reconnects:  make(chan struct{}, 1),
+	}
+}
+
+func (c *control) interval(sessionInterval time.Duration) time.Duration {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.pollInterval > 0 {
+		return c.pollInterval
+	}
+	return sessionInterval
+}
+
+func (c *control) isPaused() bool {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	return c.paused
+}
+
+func (c *control) loggedIn(navigation []NavigationItem) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.connected = true
+	c.downSince = time.Time{}
+	c.navigation = navigation
+}
+
+func (c *control) polled() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.lastPoll = time.Now()
+}
+
+func (c *control) failed(err error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.connected = false
+	c.lastError = err
+	c.lastErrorTime = time.Now()
+	if c.downSince.IsZero() {
+		c.downSince = c.lastErrorTime
+	}
+}
+
+// restartDelay backs off the restarts of a failing session, waiting as long as the session has been
+// down, up to maxRestartDelay. The first failure after a login is retried right away.
+func (c *control) restartDelay() time.Duration {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.downSince.IsZero() {
+		return 0
+	}
+	return min(time.Since(c.downSince), maxRestartDelay)
+}
+
+// notify sends a signal without blocking, a pending signal being enough
+func notify(signals chan struct{}) {
+	select {
+	case signals <- struct{}{}:
+	default:
+	}
+}
+
+// parseNavigation returns the menu returned on login
+func parseNavigation(byteXML []byte) ([]NavigationItem, error) {
+	var navigation _Navigation
+
+	err := xml.Unmarshal(byteXML, &navigation)
+	if err != nil {
+		return nil, err
+	}
+
+	return convertNavigation(navigation.Items), nil
+}
+
+func convertNavigation(items []_NavigationItem) []NavigationItem {
+	var converted []NavigationItem
+	for _, item := range items {
+		converted = append(converted, NavigationItem{ID: item.ID, Name: item.Name, Items: convertNavigation(item.Items)})
+	}
+	return converted
+}
+
+// Status returns the state of the current session
+func (swc *SWCSource) Status() SessionStatus {
+	c := swc.control
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	status := SessionStatus{State: Connecting, PollInterval: int64(swc.PollIntervalMs / time.Second), Restarts: swc.Restarts()}
+	if c.pollInterval > 0 {
+		status.PollInterval = int64(c.pollInterval / time.Second)
+	}
+	switch {
+	case c.connected && c.paused:
+		status.State = Paused
+	case c.connected:
+		status.State = Connected
+	case c.lastError != nil:
+		status.State = Disconnected
+	}
+	if !c.lastPoll.IsZero() {
+		status.LastPoll = c.lastPoll.Unix()
+	}
+	if c.lastError != nil {
+		status.LastError = c.lastError.Error()
+		status.LastErrorTime = c.lastErrorTime.Unix()
+	}
+	if !c.downSince.IsZero() {
+		status.DownSince = c.downSince.Unix()
+	}
+
+	return status
+}
+
+// Navigation returns the menu of the SWC received when the current session logged in
+func (swc *SWCSource) Navigation() []NavigationItem {
+	swc.control.mutex.Lock()
+	defer swc.control.mutex.Unlock()
+
+	return swc.control.navigation
+}
+
+// Refresh requests the session to poll the SWC immediately, even when paused
+func (swc *SWCSource) Refresh() error {
+	swc.control.mutex.Lock()
+	connected := swc.control.connected
+	swc.control.mutex.Unlock()
+	if !connected {
+		return errors.New("the session is not connected")
+	}
+
+	notify(swc.control.refreshes)
+	return nil
+}
+
+// SetPollInterval changes the polling interval, the next poll being scheduled from now
+func (swc *SWCSource) SetPollInterval(interval time.Duration) error {
+	if interval < MinPollInterval {
+		return errors.New("the polling interval must be at least 1s")
+	}
+
+	swc.control.mutex.Lock()
+	swc.control.pollInterval = interval
+	swc.control.mutex.Unlock()
+
+	notify(swc.control.rescheduled)
+	return nil
+}
+
+// Reconfigure changes the settings of the session, the current session being replaced by a new one using them
+func (swc *SWCSource) Reconfigure(URL string, device string, pages []string, faults *FaultTracker) {
+	if device == "" {
+		device = DefaultDevice
+	}
+
+	swc.control.mutex.Lock()
+	swc.WebSocketURL = URL
+	swc.Device = device
+	swc.Pages = pages
+	swc.Faults = faults
+	swc.control.mutex.Unlock()
+
+	notify(swc.control.reconnects)
+}
+
+// Pause stops polling the SWC until Resume is called, the session being kept open
+func (swc *SWCSource) Pause() {
+	swc.control.mutex.Lock()
+	swc.control.paused = true
+	swc.control.mutex.Unlock()
+}
+
+// Resume restarts polling the SWC
+func (swc *SWCSource) Resume() {
+	swc.control.mutex.Lock()
+	swc.control.paused = false
+	swc.control.mutex.Unlock()
+
+	notify(swc.control.rescheduled)
+}
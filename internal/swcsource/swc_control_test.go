@@ -0,0 +1,190 @@
+package swcsource
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func startSource(t *testing.T, pollingInterval time.Duration) (*SWCSource, *wsSpy) {
+	t.Helper()
+	spy := makeSpy()
+	server := httptest.NewServer(http.HandlerFunc(generateHTTPHandler(t, spy)))
+	t.Cleanup(func() {
+		spy.stop()
+		server.Close()
+	})
+
+	source := NewSWCSource(toWs(server.URL), pollingInterval)
+	source.restartOnSessionFailure = false
+	go source.Start()
+
+	for source.Status().State != Connected {
+		time.Sleep(time.Millisecond)
+	}
+	return source, spy
+}
+
+// noMeasurement fails when a measurement is received within the given duration
+func noMeasurement(t *testing.T, source *SWCSource, duration time.Duration) {
+	t.Helper()
+	select {
+	case m := <-source.MeasurementsChannel():
+		t.Errorf("No measurement was expected, got %v", m)
+	case <-time.After(duration):
+	}
+}
+
+func TestStatus(t *testing.T) {
+	t.Run("Status and navigation of the session are reported", func(t *testing.T) {
+		source, _ := startSource(t, time.Hour)
+		source.Refresh()
+		<-source.MeasurementsChannel()
+
+		status := source.Status()
+		if status.State != Connected || status.PollInterval != 3600 || status.LastPoll == 0 || status.Restarts != 0 {
+			t.Errorf("Unexpected status %+v", status)
+		}
+
+		navigation := source.Navigation()
+		if len(navigation) < 2 || navigation[0].Name != "Informations" || navigation[0].Items[0].Name != "Températures" || navigation[0].Items[0].ID != "0x46bd50" {
+			t.Errorf("Unexpected navigation %+v", navigation)
+		}
+	})
+
+	t.Run("Session is connecting until logged in", func(t *testing.T) {
+		source := NewSWCSource("ws://localhost:1", time.Minute)
+
+		if status := source.Status(); status.State != Connecting || status.PollInterval != 60 {
+			t.Errorf("Unexpected status %+v", status)
+		}
+		if err := source.Refresh(); err == nil {
+			t.Error("An error was expected and none was returned")
+		}
+	})
+
+	t.Run("Downtime starts with the first failure since the last login", func(t *testing.T) {
+		source := NewSWCSource("ws://localhost:1", time.Minute)
+		source.control.failed(errors.New("connection refused"))
+		source.control.downSince = time.Unix(1000, 0)
+		source.control.failed(errors.New("connection refused"))
+
+		if status := source.Status(); status.DownSince != 1000 || status.LastErrorTime == 1000 {
+			t.Errorf("Expected the first failure to be kept, got %+v", status)
+		}
+
+		source.control.loggedIn(nil)
+		if status := source.Status(); status.DownSince != 0 {
+			t.Errorf("Expected the downtime to end on login, got %+v", status)
+		}
+	})
+}
+
+func TestRefresh(t *testing.T) {
+	t.Run("A refresh polls immediately, even when paused", func(t *testing.T) {
+		source, _ := startSource(t, time.Hour)
+		source.Pause()
+
+		if status := source.Status(); status.State != Paused {
+			t.Errorf("Expected the session to be paused, got %+v", status)
+		}
+		if err := source.Refresh(); err != nil {
+			t.Fatalf("No error was expected but got %v", err)
+		}
+
+		select {
+		case <-source.MeasurementsChannel():
+		case <-time.After(5 * time.Second):
+			t.Error("Expected the temperatures to be refreshed")
+		}
+	})
+}
+
+func TestPause(t *testing.T) {
+	t.Run("Polling stops while paused", func(t *testing.T) {
+		source, _ := startSource(t, 20*time.Millisecond)
+		source.Pause()
+
+		// drains the measurements polled before pausing
+		for drained := false; !drained; {
+			select {
+			case <-source.MeasurementsChannel():
+			case <-time.After(200 * time.Millisecond):
+				drained = true
+			}
+		}
+		noMeasurement(t, source, 200*time.Millisecond)
+
+		source.Resume()
+		select {
+		case <-source.MeasurementsChannel():
+		case <-time.After(5 * time.Second):
+			t.Error("Expected polling to resume")
+		}
+	})
+}
+
+func TestSetPollInterval(t *testing.T) {
+	t.Run("The next poll is scheduled with the new interval", func(t *testing.T) {
+		source, _ := startSource(t, time.Hour)
+
+		if err := source.SetPollInterval(time.Second); err != nil {
+			t.Fatalf("No error was expected but got %v", err)
+		}
+		if status := source.Status(); status.PollInterval != 1 {
+			t.Errorf("Expected the new interval to be reported, got %+v", status)
+		}
+
+		select {
+		case <-source.MeasurementsChannel():
+		case <-time.After(5 * time.Second):
+			t.Error("Expected the temperatures to be polled with the new interval")
+		}
+	})
+
+	t.Run("should error out when the interval is below 1s", func(t *testing.T) {
+		source := NewSWCSource("ws://localhost:1", time.Minute)
+
+		if err := source.SetPollInterval(time.Millisecond); err == nil {
+			t.Error("An error was expected and none was returned")
+		}
+	})
+}
+
+func TestReconfigure(t *testing.T) {
+	t.Run("The session is replaced by one using the new settings", func(t *testing.T) {
+		spy := makeSpy()
+		server := httptest.NewServer(http.HandlerFunc(generateHTTPHandler(t, spy)))
+		t.Cleanup(server.Close)
+
+		source := NewSWCSource(toWs(server.URL), time.Hour)
+		go source.Start()
+		for source.Status().State != Connected {
+			time.Sleep(time.Millisecond)
+		}
+
+		source.Reconfigure(source.WebSocketURL, "heatpump", nil, nil)
+
+		// the measurements of the new session use the new device, a refresh of the old one being lost
+		deadline := time.After(5 * time.Second)
+		ticker := time.NewTicker(10 * time.Millisecond)
+		defer ticker.Stop()
+		for device := ""; device != "heatpump"; {
+			select {
+			case m := <-source.MeasurementsChannel():
+				device = m.Device
+			case <-ticker.C:
+				source.Refresh()
+			case <-deadline:
+				t.Fatalf("Expected the session to reconnect, got %+v", source.Status())
+			}
+		}
+
+		status := source.Status()
+		if status.Restarts != 0 || status.LastError != "" || status.DownSince != 0 {
+			t.Errorf("Expected the reconnection not to be reported as a failure, got %+v", status)
+		}
+	})
+}
@@ -0,0 +1,167 @@
+package swcsource
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Names of the pages listing the last errors and shutdowns of the controller
+const (
+	FaultsPage    = "Défauts"
+	ShutdownsPage = "Arrêts"
+)
+
+// Fault kinds
+const (
+	FaultKind    = "fault"
+	ShutdownKind = "shutdown"
+)
+
+var faultPages = map[string]string{
+	FaultsPage:    FaultKind,
+	ShutdownsPage: ShutdownKind,
+}
+
+// deviceTimeLayout is the layout of the timestamps listed by the controller, in its local time
+const deviceTimeLayout = "02.01.06 15:04:05"
+
+var faultRegexp = regexp.MustCompile(`^(\d+)\s*(.*)$`)
+
+// SWCFault represents an entry of the error or shutdown history of the SWC heating system
+type SWCFault struct {
+	Kind        string // FaultKind or ShutdownKind
+	Code        int
+	Description string
+	DeviceTime  int64 // when the controller recorded the entry
+}
+
+// FaultTracker keeps a high-water mark per kind of fault so that each entry is emitted once.
+// The marks are persisted to a file, if any, to survive restarts.
+//
+// Delivery is at most once: the mark moves as soon as the history is parsed, before the sinks
+// received the entries, so entries lost to a failing sink or a crash in between are not emitted again.
+// The history pages remain the reference, the controller keeping its last entries.
+type FaultTracker struct {
+	Path     string         // empty to keep the marks in memory only
+	Location *time.Location // time zone of the controller, defaults to local time
+
+	mutex sync.Mutex
+	marks map[string]highWaterMark
+}
+
+type highWaterMark struct {
+	DeviceTime int64
+	Codes      []int // codes recorded at DeviceTime, as several entries can share a timestamp
+}
+
+// NewFaultTracker creates a new FaultTracker, loading the marks persisted at path when it exists
+func NewFaultTracker(path string) (*FaultTracker, error) {
+	tracker := FaultTracker{
+		Path:     path,
+		Location: time.Local,
+		marks:    make(map[string]highWaterMark),
+	}
+
+	if len(path) == 0 {
+		return &tracker, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return &tracker, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	err = json.Unmarshal(data, &tracker.marks)
+	if err != nil {
+		return nil, err
+	}
+	return &tracker, nil
+}
+
+// parseFaults returns the valid entries of a fault page, oldest first. Empty slots are listed as "---".
+func (ft *FaultTracker) parseFaults(kind string, content _Content) []SWCFault {
+	var faults []SWCFault
+
+	for _, item := range content.Items {
+		deviceTime, err := time.ParseInLocation(deviceTimeLayout, strings.TrimSpace(item.Name), ft.Location)
+		if err != nil {
+			continue
+		}
+
+		submatch := faultRegexp.FindStringSubmatch(strings.TrimSpace(item.Value))
+		if submatch == nil {
+			continue
+		}
+		code, _ := strconv.Atoi(submatch[1])
+
+		faults = append(faults, SWCFault{
+			Kind:        kind,
+			Code:        code,
+			Description: submatch[2],
+			DeviceTime:  deviceTime.Unix(),
+		})
+	}
+
+	sort.SliceStable(faults, func(i, j int) bool { return faults[i].DeviceTime < faults[j].DeviceTime })
+	return faults
+}
+
+// newFaults filters out the faults which were already emitted and moves the high-water mark,
+// whether or not the returned faults reach the sinks
+func (ft *FaultTracker) newFaults(kind string, faults []SWCFault) ([]SWCFault, error) {
+	ft.mutex.Lock()
+	defer ft.mutex.Unlock()
+
+	mark := ft.marks[kind]
+	var newFaults []SWCFault
+	for _, fault := range faults {
+		if fault.DeviceTime < mark.DeviceTime || (fault.DeviceTime == mark.DeviceTime && containsCode(mark.Codes, fault.Code)) {
+			continue
+		}
+
+		if fault.DeviceTime > mark.DeviceTime {
+			mark = highWaterMark{DeviceTime: fault.DeviceTime}
+		}
+		mark.Codes = append(mark.Codes, fault.Code)
+		newFaults = append(newFaults, fault)
+	}
+
+	if len(newFaults) == 0 {
+		return nil, nil
+	}
+
+	ft.marks[kind] = mark
+	return newFaults, ft.persist()
+}
+
+func (ft *FaultTracker) persist() error {
+	if len(ft.Path) == 0 {
+		return nil
+	}
+
+	data, _ := json.Marshal(ft.marks)
+	temporaryPath := ft.Path + ".tmp"
+	err := os.WriteFile(temporaryPath, data, 0644)
+	if err != nil {
+		return err
+	}
+	return os.Rename(temporaryPath, ft.Path)
+}
+
+func containsCode(codes []int, code int) bool {
+	for _, c := range codes {
+		if c == code {
+			return true
+		}
+	}
+	return false
+}
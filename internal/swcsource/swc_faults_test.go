@@ -0,0 +1,89 @@
+package swcsource
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func makeTracker(t *testing.T, path string) *FaultTracker {
+	t.Helper()
+	tracker, err := NewFaultTracker(path)
+	if err != nil {
+		t.Fatalf("Failed to create fault tracker: %v", err)
+	}
+	tracker.Location = time.UTC
+	return tracker
+}
+
+func readFaults(t *testing.T, tracker *FaultTracker, kind string, fileName string) []SWCFault {
+	t.Helper()
+	content, err := parseXMLContent(readFixture(t, fileName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tracker.parseFaults(kind, content)
+}
+
+func TestParseFaults(t *testing.T) {
+	t.Run("Entries are parsed oldest first, empty slots are skipped", func(t *testing.T) {
+		tracker := makeTracker(t, "")
+
+		faults := readFaults(t, tracker, FaultKind, "testdata/GET;0x4922a0.xml")
+
+		expected := []SWCFault{
+			{Kind: FaultKind, Code: 705, Description: "Débit source", DeviceTime: time.Date(2020, 12, 2, 22, 14, 51, 0, time.UTC).Unix()},
+			{Kind: FaultKind, Code: 715, Description: "Haute pression", DeviceTime: time.Date(2021, 1, 14, 6, 32, 10, 0, time.UTC).Unix()},
+		}
+		if !reflect.DeepEqual(expected, faults) {
+			t.Errorf("Expected %+v got %+v", expected, faults)
+		}
+	})
+}
+
+func TestNewFaults(t *testing.T) {
+	t.Run("Faults are only emitted once", func(t *testing.T) {
+		tracker := makeTracker(t, "")
+		faults := readFaults(t, tracker, ShutdownKind, "testdata/GET;0x494f08.xml")
+
+		first, _ := tracker.newFaults(ShutdownKind, faults)
+		second, _ := tracker.newFaults(ShutdownKind, faults)
+
+		if len(first) != 3 || len(second) != 0 {
+			t.Errorf("Expected 3 then 0 new faults, got %d then %d", len(first), len(second))
+		}
+	})
+
+	t.Run("Entries sharing the high-water mark timestamp are told apart by code", func(t *testing.T) {
+		tracker := makeTracker(t, "")
+		tracker.newFaults(FaultKind, []SWCFault{{Kind: FaultKind, Code: 715, DeviceTime: 100}})
+
+		faults, _ := tracker.newFaults(FaultKind, []SWCFault{
+			{Kind: FaultKind, Code: 715, DeviceTime: 100},
+			{Kind: FaultKind, Code: 705, DeviceTime: 100},
+			{Kind: FaultKind, Code: 705, DeviceTime: 50},
+		})
+
+		if len(faults) != 1 || faults[0].Code != 705 || faults[0].DeviceTime != 100 {
+			t.Errorf("Expected only the new fault sharing the mark, got %+v", faults)
+		}
+	})
+
+	t.Run("High-water mark survives restarts", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "faults.json")
+		tracker := makeTracker(t, path)
+		faults := readFaults(t, tracker, FaultKind, "testdata/GET;0x4922a0.xml")
+
+		_, err := tracker.newFaults(FaultKind, faults)
+		if err != nil {
+			t.Fatalf("Failed to persist the high-water mark: %v", err)
+		}
+
+		restarted := makeTracker(t, path)
+		newFaults, _ := restarted.newFaults(FaultKind, faults)
+		if len(newFaults) != 0 {
+			t.Errorf("Expected no new faults after restart, got %+v", newFaults)
+		}
+	})
+}
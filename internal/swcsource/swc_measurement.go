@@ -2,6 +2,7 @@ package swcsource
 
 import (
 	"encoding/xml"
+	"fmt"
 	"log"
 	"strconv"
 	"strings"
@@ -17,6 +18,9 @@ const drillInboundTemperature = 8
 const drillOutboundTemperature = 9
 const ambiantIndoorTemperature = 20
 const ambiantIndoorTargetTemperature = 21
+const remoteControlTemperature = 10
+const solarCollectorTemperature = 13
+const solarTankTemperature = 14
 
 // SWCMeasurement represents all monitored temperatures out of the SWC heating system
 type SWCMeasurement struct {
@@ -29,8 +33,37 @@ type SWCMeasurement struct {
 	DrillOutboundTemperature       float64
 	AmbiantIndoorTemperature       float64
 	AmbiantIndoorTargetTemperature float64
+	RemoteControlTemperature       float64
+	SolarCollectorTemperature      float64
+	SolarTankTemperature           float64
 }
 
+// SWCHeatQuantity represents the heat meter counters of the SWC heating system, in kWh
+type SWCHeatQuantity struct {
+	HeatingEnergy  float64
+	HotWaterEnergy float64
+	TotalEnergy    float64
+}
+
+// SWCOutputs represents the state of the outputs driven by the SWC heating system
+type SWCOutputs struct {
+	Compressor      bool
+	DefrostValve    bool
+	HeatingPump     bool
+	HotWaterPump    bool
+	DrillPump       bool
+	AuxiliaryHeater bool
+}
+
+// SWCStatus represents the operating status of the SWC heating system
+type SWCStatus struct {
+	OperatingState string  // e.g. "Chauffage", "ECS", "Dégivrage"
+	HeatOutput     float64 // kW
+}
+
+// DefrostState is the operating state reported while defrosting
+const DefrostState = "Dégivrage"
+
 type _Values struct {
 	XMLName xml.Name `xml:"values"`
 	Items   []_Item  `xml:"item"`
@@ -61,13 +94,82 @@ func parseXMLMeasurement(byteXML []byte) (SWCMeasurement, error) {
 		DrillOutboundTemperature:       convertToFloat64(values.Items[drillOutboundTemperature].Value),
 		AmbiantIndoorTemperature:       convertToFloat64(values.Items[ambiantIndoorTemperature].Value),
 		AmbiantIndoorTargetTemperature: convertToFloat64(values.Items[ambiantIndoorTargetTemperature].Value),
+		RemoteControlTemperature:       convertToFloat64(values.Items[remoteControlTemperature].Value),
+		SolarCollectorTemperature:      convertToFloat64(values.Items[solarCollectorTemperature].Value),
+		SolarTankTemperature:           convertToFloat64(values.Items[solarTankTemperature].Value),
 	}
 
 	return swcMeasurement, nil
 }
 
+func parseHeatQuantity(items map[string]string) (interface{}, error) {
+	heating, foundHeating := items["Chauffage"]
+	hotWater, foundHotWater := items["ECS"]
+	if !foundHeating || !foundHotWater {
+		return nil, fmt.Errorf("heat quantity page is inconsistent: %v", items)
+	}
+
+	heatQuantity := SWCHeatQuantity{
+		HeatingEnergy:  convertToFloat64(heating),
+		HotWaterEnergy: convertToFloat64(hotWater),
+	}
+	if total, found := items["Total"]; found {
+		heatQuantity.TotalEnergy = convertToFloat64(total)
+	} else {
+		heatQuantity.TotalEnergy = heatQuantity.HeatingEnergy + heatQuantity.HotWaterEnergy
+	}
+
+	return heatQuantity, nil
+}
+
+func parseOutputs(items map[string]string) (interface{}, error) {
+	compressor, found := items["Compresseur 1"]
+	if !found {
+		return nil, fmt.Errorf("outputs page is inconsistent: %v", items)
+	}
+
+	outputs := SWCOutputs{
+		Compressor:      isOn(compressor) || isOn(items["Compresseur 2"]),
+		DefrostValve:    isOn(items["Vanne dégivrage"]),
+		HeatingPump:     isOn(items["Pompe circ. chauff."]),
+		HotWaterPump:    isOn(items["Pompe ECS"]),
+		DrillPump:       isOn(items["Pompe source chal."]),
+		AuxiliaryHeater: isOn(items["Appoint 1"]),
+	}
+
+	return outputs, nil
+}
+
+func parseStatus(items map[string]string) (interface{}, error) {
+	state, found := items["Etat de fonctionnement"]
+	if !found {
+		return nil, fmt.Errorf("status page is inconsistent: %v", items)
+	}
+
+	status := SWCStatus{
+		OperatingState: state,
+	}
+	if heatOutput, found := items["Puissance calorifique"]; found {
+		status.HeatOutput = convertToFloat64(heatOutput)
+	}
+
+	return status, nil
+}
+
+func isOn(value string) bool {
+	switch strings.TrimSpace(value) {
+	case "Marche", "On", "Ein", "1":
+		return true
+	}
+	return false
+}
+
+// convertToFloat64 parses values such as "33.8°C", "18.9 K" or "1234.5 kWh", dropping the unit
 func convertToFloat64(value string) float64 {
 	measureAsString := strings.Replace(value, "°C", "", 1)
+	if parts := strings.Fields(measureAsString); len(parts) > 0 {
+		measureAsString = parts[0]
+	}
 	measure, err := strconv.ParseFloat(measureAsString, 64)
 
 	if err != nil {
@@ -0,0 +1,98 @@
+package swcsource
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+
+	"github.com/renajohn/pac_collector/api"
+)
+
+// Names of the information pages which can be collected in addition to the temperatures
+const (
+	HeatQuantityPage = "Compteur de chaleur"
+	OutputsPage      = "Sorties"
+	StatusPage       = "Status de l'installation"
+)
+
+// pageParser turns the items of an information page, indexed by name, into a measurement value
+type pageParser struct {
+	measurementType api.MeasurementType
+	parse           func(items map[string]string) (interface{}, error)
+}
+
+var pageParsers = map[string]pageParser{
+	HeatQuantityPage: {measurementType: api.SWCHeatQuantity, parse: parseHeatQuantity},
+	OutputsPage:      {measurementType: api.SWCOutputs, parse: parseOutputs},
+	StatusPage:       {measurementType: api.SWCStatus, parse: parseStatus},
+}
+
+type _Navigation struct {
+	XMLName xml.Name          `xml:"Navigation"`
+	Items   []_NavigationItem `xml:"item"`
+}
+
+type _NavigationItem struct {
+	ID    string            `xml:"id,attr"`
+	Name  string            `xml:"name"`
+	Items []_NavigationItem `xml:"item"`
+}
+
+type _Content struct {
+	XMLName xml.Name       `xml:"Content"`
+	Name    string         `xml:"name"`
+	Items   []_ContentItem `xml:"item"`
+}
+
+type _ContentItem struct {
+	ID    string `xml:"id,attr"`
+	Name  string `xml:"name"`
+	Value string `xml:"value"`
+}
+
+// findInformationPages resolves the ID of the given pages out of the navigation returned on login.
+// Pages are looked up in the first menu (Informations) only, as some names are reused in other menus.
+func findInformationPages(byteXML []byte, names []string) (map[string]string, error) {
+	var navigation _Navigation
+
+	err := xml.Unmarshal(byteXML, &navigation)
+	if err != nil {
+		return nil, err
+	}
+	if len(navigation.Items) == 0 {
+		return nil, errors.New("navigation is empty")
+	}
+
+	pages := make(map[string]string, len(names))
+	for _, name := range names {
+		for _, item := range navigation.Items[0].Items {
+			if item.Name == name {
+				pages[name] = item.ID
+			}
+		}
+
+		if _, found := pages[name]; !found {
+			return nil, fmt.Errorf("page %q not found in navigation", name)
+		}
+	}
+
+	return pages, nil
+}
+
+func parseXMLContent(byteXML []byte) (_Content, error) {
+	var content _Content
+
+	err := xml.Unmarshal(byteXML, &content)
+
+	return content, err
+}
+
+// indexByName returns the value of the items of a page, indexed by name
+func (content _Content) indexByName() map[string]string {
+	items := make(map[string]string, len(content.Items))
+	for _, item := range content.Items {
+		items[item.Name] = item.Value
+	}
+
+	return items
+}
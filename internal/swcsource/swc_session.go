@@ -1,6 +1,7 @@
 package swcsource
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -11,6 +12,9 @@ import (
 
 	"github.com/gorilla/websocket"
 	"github.com/renajohn/pac_collector/api"
+	"github.com/renajohn/pac_collector/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // SWCSession interfaces with the SWC heat pump.
@@ -18,14 +22,26 @@ import (
 type SWCSession struct {
 	WebSocketURL   string
 	PollIntervalMs time.Duration // defaults to 1 min
+	Device         string
+	Pages          []string // information pages to collect in addition to the temperatures
+	Faults         *FaultTracker
 
 	MeasurementsChannel chan api.Measurement
 	ErrorsChannel       chan error
 
 	ws             *websocket.Conn
+	ctx            context.Context // within the span of the session, from its start to its termination
+	span           trace.Span
 	temperatureCmd string
+	pageCmds       []string
+	control        *control
+	done           chan struct{} // closed once the messages are not read anymore, stopping the polling
+	closed         chan error    // reason of closing the connection, reported once reading fails
 }
 
+// errReconnect terminates a session on purpose, to apply new settings, which is not a failure
+var errReconnect = errors.New("reconnecting to apply the new settings")
+
 // Session represent a Web Socket session. If the connection is broken, the session is destroyed
 type Session interface {
 	StartSession()
@@ -37,6 +53,9 @@ func newSWCSession(URL string, pollingInterval time.Duration, measurementsChanne
 		WebSocketURL:        URL,
 		MeasurementsChannel: measurementsChannel,
 		ErrorsChannel:       errorsChannel,
+		control:             newControl(),
+		done:                make(chan struct{}),
+		closed:              make(chan error, 1),
 	}
 
 	if pollingInterval == 0 {
@@ -61,14 +80,15 @@ func (swc *SWCSession) validateConfig() error {
 // StartSession satisfies Session interface
 func (swc *SWCSession) StartSession() {
 	var err error
+	swc.ctx, swc.span = tracing.Tracer().Start(context.Background(), "swc.session", trace.WithAttributes(attribute.String("swc.device", swc.Device)))
 
-	err = swc.connect()
+	err = tracing.Run(swc.ctx, "swc.connect", func(ctx context.Context) error { return swc.connect() })
 	if err != nil {
 		swc.terminate(err)
 		return
 	}
 
-	err = swc.login()
+	err = tracing.Run(swc.ctx, "swc.login", func(ctx context.Context) error { return swc.login() })
 	if err != nil {
 		swc.terminate(err)
 		return
@@ -117,6 +137,24 @@ func (swc *SWCSession) login() error {
 		return errors.New("login XML message is inconsistent - aborting")
 	}
 
+	if len(swc.Pages) > 0 {
+		pages, err := findInformationPages(message, swc.Pages)
+		if err != nil {
+			return fmt.Errorf("login XML message is inconsistent: %v - aborting", err)
+		}
+
+		swc.pageCmds = nil
+		for _, name := range swc.Pages {
+			swc.pageCmds = append(swc.pageCmds, "GET;"+pages[name])
+		}
+	}
+
+	navigation, err := parseNavigation(message)
+	if err != nil {
+		log.Printf("Failed to parse the navigation: %v", err)
+	}
+	swc.control.loggedIn(navigation)
+
 	return nil
 }
 
@@ -124,55 +162,216 @@ func (swc *SWCSession) getTemperatures() error {
 	return swc.ws.WriteMessage(websocket.TextMessage, []byte(swc.temperatureCmd))
 }
 
+// readMessages forwards the measurements until reading fails, which terminates the session
 func (swc *SWCSession) readMessages() {
+	defer close(swc.done)
+
 	for {
 		// receive message
 		messageType, message, err := swc.ws.ReadMessage()
 
 		if err != nil {
-			readError := fmt.Sprintf("Error while reading WS message: %v - aborting", err)
-			log.Println(readError)
+			select {
+			case reason := <-swc.closed:
+				err = reason
+			default:
+				log.Printf("Error while reading WS message: %v - aborting", err)
+			}
 			swc.terminate(err)
 			return
 		} else if messageType == websocket.TextMessage {
-			swc.parseMessage(message)
+			var measurements []api.Measurement
+			err = tracing.Run(swc.ctx, "swc.parse", func(ctx context.Context) error {
+				measurements, err = swc.parseMessage(ctx, message)
+				return err
+			})
+			if err != nil {
+				log.Println(err)
+			}
+
+			// sent once the span ended, as the collector may be busy
+			for _, measurement := range measurements {
+				swc.MeasurementsChannel <- measurement
+			}
 		}
 	}
 }
 
-func (swc *SWCSession) parseMessage(byteXML []byte) {
-	if strings.HasPrefix(string(byteXML), "<values>") {
+// parseMessage returns the measurements of a message, which carry the context of its parsing
+func (swc *SWCSession) parseMessage(ctx context.Context, byteXML []byte) ([]api.Measurement, error) {
+	if strings.HasPrefix(string(byteXML), "<Content>") {
+		return swc.parseContent(ctx, byteXML)
+	} else if strings.HasPrefix(string(byteXML), "<values>") {
 		values, err := parseXMLMeasurement(byteXML)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse XML message: %w", err)
+		}
+
+		swc.control.polled()
+		data, _ := json.Marshal(values)
+		return []api.Measurement{{
+			MeasurementType: api.SWCTemperature,
+			Device:          swc.Device,
+			Timestamp:       time.Now().Unix(),
+			Value:           data,
+			Context:         ctx}}, nil
+	}
+
+	return nil, nil
+}
+
+// parseContent returns a measurement for the pages which were requested, other pages are ignored
+func (swc *SWCSession) parseContent(ctx context.Context, byteXML []byte) ([]api.Measurement, error) {
+	content, err := parseXMLContent(byteXML)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse XML content: %w", err)
+	}
+
+	if !swc.collects(content.Name) {
+		return nil, nil
+	}
+
+	if kind, found := faultPages[content.Name]; found {
+		return swc.parseFaults(ctx, kind, content), nil
+	}
+
+	parser, found := pageParsers[content.Name]
+	if !found {
+		return nil, nil
+	}
+
+	value, err := parser.parse(content.indexByName())
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse page %s: %w", content.Name, err)
+	}
+
+	data, _ := json.Marshal(value)
+	return []api.Measurement{{
+		MeasurementType: parser.measurementType,
+		Device:          swc.Device,
+		Timestamp:       time.Now().Unix(),
+		Value:           data,
+		Context:         ctx}}, nil
+}
+
+// parseFaults returns the entries of a fault page which were not emitted yet
+func (swc *SWCSession) parseFaults(ctx context.Context, kind string, content _Content) []api.Measurement {
+	if swc.Faults == nil {
+		swc.Faults, _ = NewFaultTracker("")
+	}
+
+	faults, err := swc.Faults.newFaults(kind, swc.Faults.parseFaults(kind, content))
+	if err != nil {
+		log.Printf("Failed to persist the %s high-water mark: %v", kind, err)
+	}
+
+	var measurements []api.Measurement
+	for _, fault := range faults {
+		data, _ := json.Marshal(fault)
+		measurements = append(measurements, api.Measurement{
+			MeasurementType: api.SWCFault,
+			Device:          swc.Device,
+			Timestamp:       time.Now().Unix(),
+			Value:           data,
+			Context:         ctx})
+	}
+	return measurements
+}
+
+func (swc *SWCSession) collects(page string) bool {
+	for _, name := range swc.Pages {
+		if name == page {
+			return true
+		}
+	}
+	return false
+}
 
-		if err == nil {
-			data, _ := json.Marshal(values)
-			swc.MeasurementsChannel <- api.Measurement{
-				MeasurementType: api.SWCTemperature,
-				Timestamp:       time.Now().Unix(),
-				Value:           data}
-		} else {
-			log.Printf("Failed to parse XML message %v", err)
+// refresh requests the additional pages, then navigates back to the temperatures to refresh them
+func (swc *SWCSession) refresh() error {
+	if len(swc.pageCmds) > 0 {
+		for _, cmd := range append(swc.pageCmds, swc.temperatureCmd) {
+			err := swc.ws.WriteMessage(websocket.TextMessage, []byte(cmd))
+			if err != nil {
+				return err
+			}
 		}
 	}
+
+	return swc.ws.WriteMessage(websocket.TextMessage, []byte("REFRESH"))
 }
 
+// poll refreshes the measurements at the polling interval unless paused, or immediately when requested,
+// until the session is terminated
 func (swc *SWCSession) poll() {
-	var err error
 	for {
-		time.Sleep(swc.PollIntervalMs)
-		err = swc.ws.WriteMessage(websocket.TextMessage, []byte("REFRESH"))
+		timer := time.NewTimer(swc.control.interval(swc.PollIntervalMs))
+		select {
+		case <-swc.done:
+			timer.Stop()
+			return
+		case <-timer.C:
+			if swc.control.isPaused() {
+				continue
+			}
+		case <-swc.control.refreshes:
+			timer.Stop()
+			if swc.handOver(swc.control.refreshes) {
+				return
+			}
+		case <-swc.control.rescheduled:
+			timer.Stop()
+			if swc.handOver(swc.control.rescheduled) {
+				return
+			}
+			continue
+		case <-swc.control.reconnects:
+			timer.Stop()
+			if swc.handOver(swc.control.reconnects) {
+				return
+			}
+			log.Println("Reconnecting to apply the new settings")
+			swc.close(errReconnect)
+			return
+		}
+
+		err := tracing.Run(swc.ctx, "swc.poll", func(ctx context.Context) error { return swc.refresh() },
+			attribute.Int("swc.pages", len(swc.pageCmds)))
 		if err != nil {
 			pollError := fmt.Sprintf("Error while polling for data %v, aborting", err)
 			log.Println(pollError)
-			swc.terminate(errors.New(pollError))
+			swc.close(errors.New(pollError))
 			return
 		}
 	}
 }
 
+// handOver tells whether the session is terminated, passing the signal it received on to the next session
+func (swc *SWCSession) handOver(signals chan struct{}) bool {
+	select {
+	case <-swc.done:
+		notify(signals)
+		return true
+	default:
+		return false
+	}
+}
+
+// close closes the connection, reading then failing and terminating the session with the given reason
+func (swc *SWCSession) close(reason error) {
+	swc.closed <- reason
+	swc.ws.Close()
+}
+
 func (swc *SWCSession) terminate(err error) {
+	if err != errReconnect {
+		tracing.RecordError(swc.span, err)
+		swc.control.failed(err)
+	}
+	swc.span.End()
 	if swc.ws != nil {
 		swc.ws.Close()
-		swc.ErrorsChannel <- err
 	}
+	// reported even when connecting failed, for the source to try again
+	swc.ErrorsChannel <- err
 }
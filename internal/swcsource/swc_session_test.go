@@ -8,6 +8,10 @@ import (
 	"time"
 
 	"github.com/renajohn/pac_collector/api"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
 )
 
 func TestStartSession(t *testing.T) {
@@ -36,7 +40,7 @@ func TestStartSession(t *testing.T) {
 			t.Errorf("expected at least 1 message in WS got %d", len(spy.messagesLog))
 		}
 
-		spy.stop = true
+		spy.stop()
 	})
 
 	t.Run("session should be polling for new measurements", func(t *testing.T) {
@@ -62,6 +66,9 @@ func TestStartSession(t *testing.T) {
 			DrillOutboundTemperature:       11.2,
 			AmbiantIndoorTemperature:       21.1,
 			AmbiantIndoorTargetTemperature: 21.0,
+			RemoteControlTemperature:       0.0,
+			SolarCollectorTemperature:      5.0,
+			SolarTankTemperature:           150.0,
 		})
 
 		for index := 0; index < 3; index++ {
@@ -76,24 +83,176 @@ func TestStartSession(t *testing.T) {
 			t.Errorf("Expected error in SWC session: %v", <-session.ErrorsChannel)
 		}
 
-		spy.stop = true
+		spy.stop()
 	})
 
-	t.Run("When connection drops, session propagate an error", func(t *testing.T) {
+	t.Run("session should collect the requested pages", func(t *testing.T) {
 		spy := makeSpy()
 		handler := generateHTTPHandler(t, spy)
-		server := httptest.NewUnstartedServer(http.HandlerFunc(handler))
 
-		go server.Start()
+		server := httptest.NewServer(http.HandlerFunc(handler))
 		defer server.Close()
 
-		for len(server.URL) == 0 {
-			time.Sleep(10)
+		measurementsChannel := make(chan api.Measurement, 10)
+		errorsChannel := make(chan error, 10)
+		session, _ := newSWCSession(toWs(server.URL), 3, measurementsChannel, errorsChannel)
+		session.Pages = []string{HeatQuantityPage}
+
+		go session.StartSession()
+
+		expectedValue, _ := json.Marshal(SWCHeatQuantity{
+			HeatingEnergy:  18254.3,
+			HotWaterEnergy: 4127.9,
+			TotalEnergy:    22382.2,
+		})
+
+		measurement := <-session.MeasurementsChannel
+		if measurement.MeasurementType != api.SWCHeatQuantity {
+			t.Fatalf("Expected measurement type %s, but got %s", api.SWCHeatQuantity, measurement.MeasurementType)
+		}
+		if string(expectedValue) != string(measurement.Value) {
+			t.Errorf("Expected value of %v got %v", string(expectedValue), string(measurement.Value))
+		}
+
+		measurement = <-session.MeasurementsChannel
+		if measurement.MeasurementType != api.SWCTemperature {
+			t.Errorf("Expected measurement type %s, but got %s", api.SWCTemperature, measurement.MeasurementType)
 		}
 
+		spy.stop()
+	})
+
+	t.Run("session should collect the outputs and status pages", func(t *testing.T) {
+		spy := makeSpy()
+		handler := generateHTTPHandler(t, spy)
+
+		server := httptest.NewServer(http.HandlerFunc(handler))
+		defer server.Close()
+
 		measurementsChannel := make(chan api.Measurement, 10)
 		errorsChannel := make(chan error, 10)
-		session, err := newSWCSession(toWs(server.URL), 1000, measurementsChannel, errorsChannel)
+		session, _ := newSWCSession(toWs(server.URL), 3, measurementsChannel, errorsChannel)
+		session.Pages = []string{OutputsPage, StatusPage}
+
+		go session.StartSession()
+
+		expectedOutputs, _ := json.Marshal(SWCOutputs{Compressor: true, HeatingPump: true, DrillPump: true})
+		expectedStatus, _ := json.Marshal(SWCStatus{OperatingState: "Chauffage", HeatOutput: 8.2})
+
+		measurement := <-session.MeasurementsChannel
+		if measurement.MeasurementType != api.SWCOutputs || string(expectedOutputs) != string(measurement.Value) {
+			t.Errorf("Expected outputs %v got %s: %v", string(expectedOutputs), measurement.MeasurementType, string(measurement.Value))
+		}
+
+		measurement = <-session.MeasurementsChannel
+		if measurement.MeasurementType != api.SWCStatus || string(expectedStatus) != string(measurement.Value) {
+			t.Errorf("Expected status %v got %s: %v", string(expectedStatus), measurement.MeasurementType, string(measurement.Value))
+		}
+
+		spy.stop()
+	})
+
+	t.Run("session should emit fault history entries once", func(t *testing.T) {
+		spy := makeSpy()
+		handler := generateHTTPHandler(t, spy)
+
+		server := httptest.NewServer(http.HandlerFunc(handler))
+		defer server.Close()
+
+		measurementsChannel := make(chan api.Measurement, 10)
+		errorsChannel := make(chan error, 10)
+		session, _ := newSWCSession(toWs(server.URL), 3, measurementsChannel, errorsChannel)
+		session.Pages = []string{FaultsPage}
+
+		go session.StartSession()
+
+		for index := 0; index < 2; index++ {
+			measurement := <-session.MeasurementsChannel
+			if measurement.MeasurementType != api.SWCFault {
+				t.Fatalf("Expected measurement type %s, but got %s", api.SWCFault, measurement.MeasurementType)
+			}
+		}
+
+		// following polls only refresh the temperatures
+		for index := 0; index < 3; index++ {
+			measurement := <-session.MeasurementsChannel
+			if measurement.MeasurementType != api.SWCTemperature {
+				t.Errorf("Expected measurement type %s, but got %s", api.SWCTemperature, measurement.MeasurementType)
+			}
+		}
+
+		spy.stop()
+	})
+
+	t.Run("session lifecycle should be traced", func(t *testing.T) {
+		recorder := tracetest.NewSpanRecorder()
+		otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+		defer otel.SetTracerProvider(sdktrace.NewTracerProvider())
+
+		spy := makeSpy()
+		handler := generateHTTPHandler(t, spy)
+
+		server := httptest.NewServer(http.HandlerFunc(handler))
+		defer server.Close()
+
+		measurementsChannel := make(chan api.Measurement, 10)
+		errorsChannel := make(chan error, 10)
+		session, _ := newSWCSession(toWs(server.URL), 3, measurementsChannel, errorsChannel)
+
+		go session.StartSession()
+
+		var measurement api.Measurement
+		for index := 0; index < 3; index++ {
+			measurement = <-session.MeasurementsChannel
+		}
+		spy.stop()
+
+		if measurement.Context == nil || !trace.SpanContextFromContext(measurement.Context).IsValid() {
+			t.Fatal("Expected the measurement to carry the context of its parsing")
+		}
+		traceID := trace.SpanContextFromContext(measurement.Context).TraceID()
+
+		// the spans of sessions left by other tests are ignored
+		names := make(map[string]bool)
+		for _, span := range recorder.Ended() {
+			if span.SpanContext().TraceID() == traceID {
+				names[span.Name()] = true
+			}
+		}
+		for _, name := range []string{"swc.connect", "swc.login", "swc.poll", "swc.parse"} {
+			if !names[name] {
+				t.Errorf("Expected a %s span within the session trace, got %v", name, names)
+			}
+		}
+	})
+
+	t.Run("When a requested page does not exist, login should fail", func(t *testing.T) {
+		spy := makeSpy()
+		handler := generateHTTPHandler(t, spy)
+
+		server := httptest.NewServer(http.HandlerFunc(handler))
+		defer server.Close()
+
+		measurementsChannel := make(chan api.Measurement, 10)
+		errorsChannel := make(chan error, 10)
+		session, _ := newSWCSession(toWs(server.URL), 3, measurementsChannel, errorsChannel)
+		session.Pages = []string{"Foo"}
+
+		go session.StartSession()
+
+		<-session.ErrorsChannel
+		spy.stop()
+	})
+
+	t.Run("When connection drops, session propagate an error", func(t *testing.T) {
+		spy := makeSpy()
+		handler := generateHTTPHandler(t, spy)
+		server := httptest.NewServer(http.HandlerFunc(handler))
+		defer server.Close()
+
+		measurementsChannel := make(chan api.Measurement, 10)
+		errorsChannel := make(chan error, 10)
+		session, err := newSWCSession(toWs(server.URL), 10*time.Millisecond, measurementsChannel, errorsChannel)
 		if err != nil {
 			t.Errorf("error should be nil: %v", err)
 		}
@@ -107,12 +266,40 @@ func TestStartSession(t *testing.T) {
 		}
 
 		// stop handler
-		spy.stop = true
+		spy.stop()
 
 		// test will fail if no errors is returned due to the test timeout
 		<-session.ErrorsChannel
 	})
 
+	t.Run("Once terminated, a session stops polling and leaves the signals to the next one", func(t *testing.T) {
+		spy := makeSpy()
+		server := httptest.NewServer(http.HandlerFunc(generateHTTPHandler(t, spy)))
+		defer server.Close()
+
+		errorsChannel := make(chan error, 10)
+		session, _ := newSWCSession(toWs(server.URL), time.Hour, make(chan api.Measurement, 10), errorsChannel)
+		go session.StartSession()
+		<-spy.messageChannel
+
+		spy.stop()
+		<-session.ErrorsChannel
+		select {
+		case <-session.done:
+		case <-time.After(time.Second):
+			t.Fatal("Expected the session to be done")
+		}
+
+		notify(session.control.refreshes)
+		time.Sleep(20 * time.Millisecond)
+		if len(session.control.refreshes) != 1 {
+			t.Error("Expected the refresh request to be left to the next session")
+		}
+		if len(errorsChannel) != 0 {
+			t.Errorf("Expected a single error, got %v", <-errorsChannel)
+		}
+	})
+
 	t.Run("When URL is not valid, an error should be generated", func(t *testing.T) {
 		measurementsChannel := make(chan api.Measurement)
 		errorsChannel := make(chan error)
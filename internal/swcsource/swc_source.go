@@ -2,15 +2,22 @@ package swcsource
 
 import (
 	"fmt"
+	"sync/atomic"
 	"time"
 
 	"github.com/renajohn/pac_collector/api"
 )
 
+// DefaultDevice is the device name attached to measurements when none is configured
+const DefaultDevice = "swc"
+
 // SWCSource interfaces with the SWC heat pump.
 type SWCSource struct {
 	WebSocketURL   string
 	PollIntervalMs time.Duration // defaults to 1 min
+	Device         string        // defaults to DefaultDevice
+	Pages          []string      // information pages to collect in addition to the temperatures, e.g. HeatQuantityPage
+	Faults         *FaultTracker // remembers the entries of FaultsPage and ShutdownsPage already emitted
 
 	sessionFactory       SWCSessionFactory
 	currentSession       Session
@@ -18,6 +25,8 @@ type SWCSource struct {
 	sessionErrorsChannel chan error
 
 	restartOnSessionFailure bool
+	restarts                int64
+	control                 *control
 }
 
 // SWCSessionFactory generate sessions with a layer of abstraction for better testing
@@ -34,6 +43,10 @@ func (factory _SWCSessionFactoryImpl) New(source *SWCSource) Session {
 	if err != nil {
 		panic("Failed to create SWC session")
 	}
+	session.Device = source.Device
+	session.Pages = source.Pages
+	session.Faults = source.Faults
+	session.control = source.control
 
 	return session
 }
@@ -51,12 +64,17 @@ func NewSWCSource(URL string, pollingInterval time.Duration) *SWCSource {
 }
 
 func newSWCSourceWithSessionFactory(URL string, pollingInterval time.Duration, factory SWCSessionFactory) *SWCSource {
+	faults, _ := NewFaultTracker("")
+
 	source := SWCSource{
 		WebSocketURL:         URL,
+		Device:               DefaultDevice,
+		Faults:               faults,
 		measurementsChannel:  make(chan api.Measurement, 10),
 		sessionErrorsChannel: make(chan error, 10),
 
 		sessionFactory:          factory,
+		control:                 newControl(),
 		restartOnSessionFailure: true,
 		PollIntervalMs:          pollingInterval,
 	}
@@ -66,7 +84,14 @@ func newSWCSourceWithSessionFactory(URL string, pollingInterval time.Duration, f
 
 // Start satisfies the Source interface
 func (swc *SWCSource) Start() {
+	swc.control.mutex.Lock()
+	// the new session uses the latest settings, a pending reconnection is not needed anymore
+	select {
+	case <-swc.control.reconnects:
+	default:
+	}
 	session := swc.sessionFactory.New(swc)
+	swc.control.mutex.Unlock()
 	swc.currentSession = session
 
 	go session.StartSession()
@@ -79,8 +104,20 @@ func (swc *SWCSource) Start() {
 // The SWCSession will post an error to the session when ever something bad happens.
 func (swc *SWCSource) monitorSessionError() {
 	err := <-swc.sessionErrorsChannel
-	if err != nil {
-		fmt.Println(fmt.Sprintf("Session was terminited due to an error, restarting: %v", err))
+	switch {
+	case err == errReconnect:
+		// the session was replaced on purpose, not counted as a restart
+		swc.Start()
+	case err != nil:
+		delay := swc.control.restartDelay()
+		fmt.Println(fmt.Sprintf("Session was terminited due to an error, restarting in %v: %v", delay.Round(time.Millisecond), err))
+		atomic.AddInt64(&swc.restarts, 1)
+		time.Sleep(delay)
 		swc.Start()
 	}
 }
+
+// Restarts returns the number of sessions restarted after a failure
+func (swc *SWCSource) Restarts() int64 {
+	return atomic.LoadInt64(&swc.restarts)
+}
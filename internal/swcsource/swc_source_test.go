@@ -7,15 +7,29 @@ import (
 )
 
 type MockSessionFactory struct {
-	nbCalled int
+	created chan *MockSession
+}
+
+func newMockSessionFactory() *MockSessionFactory {
+	return &MockSessionFactory{created: make(chan *MockSession, 10)}
 }
 
 func (sf *MockSessionFactory) New(source *SWCSource) Session {
-	sf.nbCalled++
 	session := MockSession{}
+	sf.created <- &session
 	return &session
 }
 
+// waitForSession fails unless a session is created in time
+func waitForSession(t *testing.T, factory *MockSessionFactory) {
+	t.Helper()
+	select {
+	case <-factory.created:
+	case <-time.After(time.Second):
+		t.Fatal("Expected a session to be created")
+	}
+}
+
 type MockSession struct {
 }
 
@@ -25,34 +39,63 @@ func (ms *MockSession) StartSession() {
 func TestStart(t *testing.T) {
 
 	t.Run("Happy case", func(t *testing.T) {
-		factory := MockSessionFactory{}
-		source := newSWCSourceWithSessionFactory("ws:testurl", 1000, &factory)
+		factory := newMockSessionFactory()
+		source := newSWCSourceWithSessionFactory("ws:testurl", 1000, factory)
 		source.restartOnSessionFailure = false
 
 		source.Start()
 
-		if factory.nbCalled != 1 {
-			t.Errorf("Expected 1 session to be created, got %d", factory.nbCalled)
+		if len(factory.created) != 1 {
+			t.Errorf("Expected 1 session to be created, got %d", len(factory.created))
 		}
 	})
 
 	t.Run("When session fails, a new one is created", func(t *testing.T) {
-		factory := MockSessionFactory{}
-		source := newSWCSourceWithSessionFactory("ws:testurl", 1000, &factory)
+		factory := newMockSessionFactory()
+		source := newSWCSourceWithSessionFactory("ws:testurl", 1000, factory)
 
 		go source.Start()
-
-		// make sure the Start has been executed
-		time.Sleep(100 * time.Microsecond)
+		waitForSession(t, factory)
 
 		source.sessionErrorsChannel <- errors.New("Boom")
+		waitForSession(t, factory)
+
+		if source.Restarts() != 1 {
+			t.Errorf("Expected 1 restart to be counted, got %d", source.Restarts())
+		}
+	})
 
-		// make sure the Start has been executed
-		time.Sleep(100 * time.Microsecond)
+	t.Run("When connecting fails, the session is restarted", func(t *testing.T) {
+		source := NewSWCSource("ws://127.0.0.1:1/", 1000)
+		go source.Start()
 
-		if factory.nbCalled != 2 {
-			t.Errorf("Expected 2 session to be created, got %d", factory.nbCalled)
+		deadline := time.Now().Add(5 * time.Second)
+		for source.Restarts() < 2 {
+			if time.Now().After(deadline) {
+				t.Fatalf("Expected the session to be restarted, got %+v", source.Status())
+			}
+			time.Sleep(time.Millisecond)
+		}
+		if status := source.Status(); status.State != Disconnected || status.DownSince == 0 {
+			t.Errorf("Expected the session to be reported down, got %+v", status)
 		}
 	})
 
+	t.Run("The restarts back off while the session stays down", func(t *testing.T) {
+		source := NewSWCSource("ws:testurl", 1000)
+		if delay := source.control.restartDelay(); delay != 0 {
+			t.Errorf("Expected no delay while logged in, got %v", delay)
+		}
+
+		source.control.failed(errors.New("Boom"))
+		source.control.downSince = time.Now().Add(-10 * time.Second)
+		if delay := source.control.restartDelay(); delay < 10*time.Second || delay > 11*time.Second {
+			t.Errorf("Expected a delay as long as the downtime, got %v", delay)
+		}
+
+		source.control.downSince = time.Now().Add(-time.Hour)
+		if delay := source.control.restartDelay(); delay != maxRestartDelay {
+			t.Errorf("Expected the delay to be bounded, got %v", delay)
+		}
+	})
 }
@@ -4,6 +4,7 @@ import (
 	"net/http"
 	"os"
 	"strings"
+	"sync"
 	"testing"
 
 	"github.com/gorilla/websocket"
@@ -15,17 +16,24 @@ type wsSpy struct {
 	messagesLog    []string
 	messageChannel chan string
 
-	stop bool
+	stopped  chan struct{}
+	stopOnce sync.Once
 }
 
 func makeSpy() *wsSpy {
 	spy := wsSpy{
 		messageChannel: make(chan string, 100),
+		stopped:        make(chan struct{}),
 	}
 
 	return &spy
 }
 
+// stop drops the connections of the handler, as when the SWC goes away
+func (spy *wsSpy) stop() {
+	spy.stopOnce.Do(func() { close(spy.stopped) })
+}
+
 func assertValue(t *testing.T, expect string, got string) {
 	t.Helper()
 	if expect != got {
@@ -39,6 +47,11 @@ func generateHTTPHandler(t *testing.T, spy *wsSpy) func(http.ResponseWriter, *ht
 	expected := map[string][]byte{
 		"LOGIN;000000": readFixture(t, "testdata/LOGIN;123456.xml"),
 		"GET;0x46bd50": readFixture(t, "testdata/GET;0x46bd50.xml"),
+		"GET;0x44f2f8": readFixture(t, "testdata/GET;0x44f2f8.xml"),
+		"GET;0x472a88": readFixture(t, "testdata/GET;0x472a88.xml"),
+		"GET;0x492268": readFixture(t, "testdata/GET;0x492268.xml"),
+		"GET;0x4922a0": readFixture(t, "testdata/GET;0x4922a0.xml"),
+		"GET;0x494f08": readFixture(t, "testdata/GET;0x494f08.xml"),
 		"REFRESH":      readFixture(t, "testdata/GET;0x46bd50-REFRESH.xml"),
 	}
 
@@ -49,7 +62,18 @@ func generateHTTPHandler(t *testing.T, spy *wsSpy) func(http.ResponseWriter, *ht
 			return
 		}
 		defer connection.Close()
-		for !spy.stop {
+
+		done := make(chan struct{})
+		defer close(done)
+		go func() {
+			select {
+			case <-spy.stopped:
+				connection.Close()
+			case <-done:
+			}
+		}()
+
+		for {
 			mt, command, err := connection.ReadMessage()
 			if err != nil {
 				break
@@ -63,7 +87,11 @@ func generateHTTPHandler(t *testing.T, spy *wsSpy) func(http.ResponseWriter, *ht
 				break
 			}
 
-			spy.messageChannel <- stringCommand
+			// the commands are only logged while the tests read them
+			select {
+			case spy.messageChannel <- stringCommand:
+			default:
+			}
 		}
 	}
 }
@@ -0,0 +1,56 @@
+package tracing
+
+import (
+	"context"
+	"sync"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var (
+	mutex    sync.RWMutex
+	provider trace.TracerProvider
+)
+
+// SetTracerProvider sets the provider of the pacmon spans, e.g. the one of the otelsink package.
+// The global OpenTelemetry provider is used when nil.
+func SetTracerProvider(tracerProvider trace.TracerProvider) {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	provider = tracerProvider
+}
+
+// Tracer creates the pacmon spans. They are dropped unless a tracer provider is set.
+func Tracer() trace.Tracer {
+	mutex.RLock()
+	tracerProvider := provider
+	mutex.RUnlock()
+
+	if tracerProvider == nil {
+		tracerProvider = otel.GetTracerProvider()
+	}
+	return tracerProvider.Tracer("github.com/renajohn/pac_collector")
+}
+
+// Run calls fn within a span, the error it returns being recorded on the span
+func Run(ctx context.Context, name string, fn func(ctx context.Context) error, attributes ...attribute.KeyValue) error {
+	ctx, span := Tracer().Start(ctx, name, trace.WithAttributes(attributes...))
+	defer span.End()
+
+	err := fn(ctx)
+	if err != nil {
+		RecordError(span, err)
+	}
+
+	return err
+}
+
+// RecordError records the error on the span and marks it as failed
+func RecordError(span trace.Span, err error) {
+	span.RecordError(err)
+	span.SetStatus(codes.Error, err.Error())
+}
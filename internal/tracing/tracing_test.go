@@ -0,0 +1,49 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestRun(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+
+	Run(context.Background(), "ok", func(ctx context.Context) error { return nil }, attribute.String("page", "Sorties"))
+	err := Run(context.Background(), "failed", func(ctx context.Context) error { return errors.New("boom") })
+
+	spans := recorder.Ended()
+	if err == nil || len(spans) != 2 {
+		t.Fatalf("Expected the error to be returned and 2 spans, got %v and %d spans", err, len(spans))
+	}
+	if spans[0].Name() != "ok" || spans[0].Status().Code != codes.Unset || spans[0].Attributes()[0] != attribute.String("page", "Sorties") {
+		t.Errorf("Unexpected span %v", spans[0])
+	}
+	if spans[1].Name() != "failed" || spans[1].Status().Code != codes.Error || len(spans[1].Events()) != 1 {
+		t.Errorf("Expected the error to be recorded, got %v", spans[1])
+	}
+}
+
+func TestSetTracerProvider(t *testing.T) {
+	t.Run("Spans go to the provider which was set", func(t *testing.T) {
+		recorder := tracetest.NewSpanRecorder()
+		SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+		defer SetTracerProvider(nil)
+
+		Run(context.Background(), "parent", func(ctx context.Context) error {
+			return Run(ctx, "child", func(ctx context.Context) error { return nil })
+		})
+
+		spans := recorder.Ended()
+		if len(spans) != 2 || spans[0].Parent().SpanID() != spans[1].SpanContext().SpanID() {
+			t.Errorf("Expected the child span within its parent, got %v", spans)
+		}
+	})
+}
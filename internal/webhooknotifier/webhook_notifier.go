@@ -0,0 +1,94 @@
+package webhooknotifier
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"text/template"
+	"time"
+
+	"github.com/renajohn/pac_collector/internal/alerting"
+)
+
+// SignatureHeader holds the HMAC-SHA256 of the body, as "sha256=<hex>", when a secret is configured
+const SignatureHeader = "X-Pacmon-Signature"
+
+// WebhookNotifier posts alerts to a generic HTTP webhook
+type WebhookNotifier struct {
+	URL         string
+	Secret      string             // signs the body when set
+	Template    *template.Template // renders the body out of an alerting.Alert, JSON when nil
+	ContentType string
+
+	client *http.Client
+}
+
+// NewWebhookNotifier creates a new WebhookNotifier. The body template is optional and uses the
+// text/template syntax over an alerting.Alert, e.g. {"text": "{{.Summary}}"}.
+func NewWebhookNotifier(URL string, secret string, bodyTemplate string) (*WebhookNotifier, error) {
+	notifier := WebhookNotifier{
+		URL:         URL,
+		Secret:      secret,
+		ContentType: "application/json",
+		client:      &http.Client{Timeout: 10 * time.Second},
+	}
+
+	if len(bodyTemplate) > 0 {
+		parsed, err := template.New("webhook").Parse(bodyTemplate)
+		if err != nil {
+			return nil, fmt.Errorf("invalid webhook template: %v", err)
+		}
+		notifier.Template = parsed
+	}
+
+	return &notifier, nil
+}
+
+// Notify satisfies the alerting.Notifier interface
+func (wn *WebhookNotifier) Notify(alert alerting.Alert) error {
+	body, err := wn.render(alert)
+	if err != nil {
+		return err
+	}
+
+	request, err := http.NewRequest(http.MethodPost, wn.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", wn.ContentType)
+	if len(wn.Secret) > 0 {
+		request.Header.Set(SignatureHeader, "sha256="+Sign(wn.Secret, body))
+	}
+
+	response, err := wn.client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode < 200 || response.StatusCode >= 300 {
+		return fmt.Errorf("webhook replied %s", response.Status)
+	}
+	return nil
+}
+
+func (wn *WebhookNotifier) render(alert alerting.Alert) ([]byte, error) {
+	if wn.Template == nil {
+		return json.Marshal(alert)
+	}
+
+	var body bytes.Buffer
+	err := wn.Template.Execute(&body, alert)
+	return body.Bytes(), err
+}
+
+// Sign returns the hex encoded HMAC-SHA256 of a body
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
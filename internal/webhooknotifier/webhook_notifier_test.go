@@ -0,0 +1,86 @@
+package webhooknotifier
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/renajohn/pac_collector/internal/alerting"
+)
+
+type request struct {
+	body      []byte
+	signature string
+}
+
+func makeServer(status int, requests chan request) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(response http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		requests <- request{body: body, signature: r.Header.Get(SignatureHeader)}
+		response.WriteHeader(status)
+	}))
+}
+
+func TestNotify(t *testing.T) {
+	alert := alerting.Alert{Rule: "tank", State: alerting.Firing, Device: "swc", Field: "TankTemperature", Value: 39, Threshold: 40}
+
+	t.Run("Alert is posted as JSON by default", func(t *testing.T) {
+		requests := make(chan request, 1)
+		server := makeServer(http.StatusOK, requests)
+		defer server.Close()
+		notifier, _ := NewWebhookNotifier(server.URL, "", "")
+
+		err := notifier.Notify(alert)
+
+		if err != nil {
+			t.Fatalf("No error was expected but got %v", err)
+		}
+		received := <-requests
+		var got alerting.Alert
+		json.Unmarshal(received.body, &got)
+		if got != alert || len(received.signature) > 0 {
+			t.Errorf("Expected unsigned alert %+v got %+v (%s)", alert, got, received.signature)
+		}
+	})
+
+	t.Run("Body is rendered out of the template and signed", func(t *testing.T) {
+		requests := make(chan request, 1)
+		server := makeServer(http.StatusOK, requests)
+		defer server.Close()
+		notifier, _ := NewWebhookNotifier(server.URL, "s3cr3t", `{"text":"{{.Rule}} is {{.State}}"}`)
+
+		notifier.Notify(alert)
+
+		received := <-requests
+		expectedBody := `{"text":"tank is firing"}`
+		if string(received.body) != expectedBody {
+			t.Errorf("Expected body %s got %s", expectedBody, string(received.body))
+		}
+		if received.signature != "sha256="+Sign("s3cr3t", []byte(expectedBody)) {
+			t.Errorf("Unexpected signature %s", received.signature)
+		}
+	})
+
+	t.Run("When webhook fails, an error should be returned", func(t *testing.T) {
+		requests := make(chan request, 1)
+		server := makeServer(http.StatusBadGateway, requests)
+		defer server.Close()
+		notifier, _ := NewWebhookNotifier(server.URL, "", "")
+
+		err := notifier.Notify(alert)
+
+		if err == nil {
+			t.Error("An error was expected and none was returned")
+		}
+	})
+
+	t.Run("When template is invalid, an error should be returned", func(t *testing.T) {
+		_, err := NewWebhookNotifier("http://test", "", "{{.Rule")
+
+		if err == nil {
+			t.Error("An error was expected and none was returned")
+		}
+	})
+}